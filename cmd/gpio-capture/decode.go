@@ -0,0 +1,271 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// event is a decoded protocol event.
+type event struct {
+	t time.Duration
+	s string
+}
+
+// decoder decodes the samples of the pins, in the order they were specified
+// on the command line.
+type decoder func(samples [][]gpio.Level) []event
+
+func newDecoder(protocol string, pins, baud, spiMode int, res time.Duration) (decoder, error) {
+	switch protocol {
+	case "i2c":
+		if pins != 2 {
+			return nil, errors.New("i2c requires the pins SDA and SCL")
+		}
+		return func(s [][]gpio.Level) []event {
+			return decodeI2C(s[0], s[1], res)
+		}, nil
+	case "spi":
+		if pins < 2 || pins > 4 {
+			return nil, errors.New("spi requires the pins SCLK and MOSI, optionally followed by MISO and CS")
+		}
+		if spiMode < 0 || spiMode > 3 {
+			return nil, fmt.Errorf("invalid SPI mode %d", spiMode)
+		}
+		return func(s [][]gpio.Level) []event {
+			var miso, cs []gpio.Level
+			if len(s) > 2 {
+				miso = s[2]
+			}
+			if len(s) > 3 {
+				cs = s[3]
+			}
+			return decodeSPI(s[0], s[1], miso, cs, spiMode, res)
+		}, nil
+	case "uart":
+		if pins != 1 {
+			return nil, errors.New("uart requires the pin RX")
+		}
+		if baud <= 0 {
+			return nil, fmt.Errorf("invalid baud rate %d", baud)
+		}
+		bit := time.Second / time.Duration(baud)
+		if bit < 4*res {
+			return nil, errors.New("uart requires a sample rate of at least 4 times the baud rate")
+		}
+		return func(s [][]gpio.Level) []event {
+			return decodeUART(s[0], bit, res)
+		}, nil
+	case "1wire":
+		if pins != 1 {
+			return nil, errors.New("1wire requires the pin DQ")
+		}
+		if res > 5*time.Microsecond {
+			return nil, errors.New("1wire requires a sample rate of at least 200kHz")
+		}
+		return func(s [][]gpio.Level) []event {
+			return decodeOneWire(s[0], res)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid protocol %q", protocol)
+	}
+}
+
+// decodeI2C decodes the START and STOP conditions and the bytes, sampled on
+// the rising edges of SCL.
+func decodeI2C(sda, scl []gpio.Level, res time.Duration) []event {
+	var out []event
+	started := false
+	first := false
+	var v byte
+	nbits := 0
+	var t time.Duration
+	for i := 1; i < len(sda); i++ {
+		if scl[i] && scl[i-1] && sda[i] != sda[i-1] {
+			if sda[i] {
+				out = append(out, event{time.Duration(i) * res, "STOP"})
+				started = false
+			} else {
+				s := "START"
+				if started {
+					s = "REPEATED START"
+				}
+				out = append(out, event{time.Duration(i) * res, s})
+				started, first, nbits = true, true, 0
+			}
+			continue
+		}
+		if !started || !bool(scl[i]) || bool(scl[i-1]) {
+			continue
+		}
+		if nbits < 8 {
+			if nbits == 0 {
+				t = time.Duration(i) * res
+				v = 0
+			}
+			v <<= 1
+			if sda[i] {
+				v |= 1
+			}
+			nbits++
+			continue
+		}
+		// The 9th bit is the acknowledge from the receiver.
+		ack := "ACK"
+		if sda[i] {
+			ack = "NACK"
+		}
+		if first {
+			rw := "write"
+			if v&1 != 0 {
+				rw = "read"
+			}
+			out = append(out, event{t, fmt.Sprintf("address 0x%02x %s %s", v>>1, rw, ack)})
+		} else {
+			out = append(out, event{t, fmt.Sprintf("data 0x%02x %s", v, ack)})
+		}
+		first = false
+		nbits = 0
+	}
+	return out
+}
+
+// decodeSPI decodes the bytes, MSB first, sampled on the clock edge defined
+// by mode.
+//
+// miso and cs are optional. cs is active low.
+func decodeSPI(sclk, mosi, miso, cs []gpio.Level, mode int, res time.Duration) []event {
+	var out []event
+	// Mode 0 and 3 sample on the rising edge; 1 and 2 on the falling edge.
+	rising := gpio.Level(mode == 0 || mode == 3)
+	var vo, vi byte
+	nbits := 0
+	var t time.Duration
+	for i := 1; i < len(sclk); i++ {
+		if cs != nil && bool(cs[i] || cs[i-1]) {
+			// Not selected or just selected.
+			nbits = 0
+			continue
+		}
+		if sclk[i] == sclk[i-1] || sclk[i] != rising {
+			continue
+		}
+		if nbits == 0 {
+			t = time.Duration(i) * res
+			vo, vi = 0, 0
+		}
+		vo <<= 1
+		if mosi[i] {
+			vo |= 1
+		}
+		if miso != nil {
+			vi <<= 1
+			if miso[i] {
+				vi |= 1
+			}
+		}
+		if nbits++; nbits == 8 {
+			s := fmt.Sprintf("MOSI 0x%02x", vo)
+			if miso != nil {
+				s += fmt.Sprintf(" MISO 0x%02x", vi)
+			}
+			out = append(out, event{t, s})
+			nbits = 0
+		}
+	}
+	return out
+}
+
+// decodeUART decodes 8N1 frames, LSB first, where bit is the duration of one
+// bit.
+func decodeUART(rx []gpio.Level, bit, res time.Duration) []event {
+	var out []event
+	// at returns the index of the middle of the bit k of the frame starting at
+	// start, where the start bit is 0.
+	at := func(start, k int) int {
+		return start + int((time.Duration(2*k+1)*bit/2)/res)
+	}
+	for i := 1; i < len(rx); i++ {
+		// The line is idle high; a falling edge is a start bit.
+		if !rx[i-1] || rx[i] {
+			continue
+		}
+		if at(i, 9) >= len(rx) {
+			break
+		}
+		if rx[at(i, 0)] {
+			// Glitch.
+			continue
+		}
+		var v byte
+		for k := 0; k < 8; k++ {
+			if rx[at(i, k+1)] {
+				v |= 1 << uint(k)
+			}
+		}
+		s := fmt.Sprintf("0x%02x", v)
+		if v >= 0x20 && v < 0x7F {
+			s += fmt.Sprintf(" %q", rune(v))
+		}
+		if !rx[at(i, 9)] {
+			s += " framing error"
+		}
+		out = append(out, event{time.Duration(i) * res, s})
+		i = at(i, 9)
+	}
+	return out
+}
+
+// decodeOneWire decodes the reset and presence pulses and the bytes, LSB
+// first, from the duration of the low pulses.
+func decodeOneWire(dq []gpio.Level, res time.Duration) []event {
+	var out []event
+	var v byte
+	nbits := 0
+	var t time.Duration
+	resetEnd := -1
+	for i := 1; i < len(dq); i++ {
+		if !dq[i-1] || dq[i] {
+			continue
+		}
+		start := i
+		for i < len(dq) && !bool(dq[i]) {
+			i++
+		}
+		if i == len(dq) {
+			break
+		}
+		d := time.Duration(i-start) * res
+		switch {
+		case d >= 400*time.Microsecond:
+			out = append(out, event{time.Duration(start) * res, "RESET"})
+			resetEnd = i
+			nbits = 0
+		case resetEnd != -1 && time.Duration(start-resetEnd)*res <= 75*time.Microsecond:
+			// The device pulls the line low 15~60µs after the reset.
+			out = append(out, event{time.Duration(start) * res, "PRESENCE"})
+			resetEnd = -1
+		default:
+			resetEnd = -1
+			if nbits == 0 {
+				t = time.Duration(start) * res
+				v = 0
+			}
+			// The line is sampled 15µs after the start of the slot.
+			if d < 15*time.Microsecond {
+				v |= 1 << uint(nbits)
+			}
+			if nbits++; nbits == 8 {
+				out = append(out, event{t, fmt.Sprintf("0x%02x", v)})
+				nbits = 0
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,382 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+const res = time.Microsecond
+
+func TestDecodeI2C(t *testing.T) {
+	data := []struct {
+		name     string
+		w        func(w *i2cWave)
+		expected []string
+	}{
+		{
+			"write",
+			func(w *i2cWave) {
+				w.start()
+				w.byte(0x3c<<1, true)
+				w.byte(0x12, true)
+				w.stop()
+			},
+			[]string{"START", "address 0x3c write ACK", "data 0x12 ACK", "STOP"},
+		},
+		{
+			"read NACK",
+			func(w *i2cWave) {
+				w.start()
+				w.byte(0x50<<1|1, true)
+				w.byte(0xff, false)
+				w.stop()
+			},
+			[]string{"START", "address 0x50 read ACK", "data 0xff NACK", "STOP"},
+		},
+		{
+			"address NACK",
+			func(w *i2cWave) {
+				w.start()
+				w.byte(0x10<<1, false)
+				w.stop()
+			},
+			[]string{"START", "address 0x10 write NACK", "STOP"},
+		},
+		{
+			"repeated start",
+			func(w *i2cWave) {
+				w.start()
+				w.byte(0x50<<1, true)
+				w.byte(0x00, true)
+				w.start()
+				w.byte(0x50<<1|1, true)
+				w.byte(0xab, false)
+				w.stop()
+			},
+			[]string{"START", "address 0x50 write ACK", "data 0x00 ACK", "REPEATED START", "address 0x50 read ACK", "data 0xab NACK", "STOP"},
+		},
+		{
+			"no start",
+			func(w *i2cWave) {
+				w.byte(0x3c<<1, true)
+			},
+			nil,
+		},
+	}
+	for _, line := range data {
+		w := &i2cWave{}
+		w.add(true, true)
+		line.w(w)
+		w.add(true, true)
+		if s := strs(decodeI2C(w.sda, w.scl, res)); !reflect.DeepEqual(s, line.expected) {
+			t.Errorf("%s: %q != %q", line.name, s, line.expected)
+		}
+	}
+}
+
+func TestDecodeI2C_time(t *testing.T) {
+	w := &i2cWave{}
+	w.add(true, true)
+	w.start()
+	w.byte(0x3c<<1, true)
+	e := decodeI2C(w.sda, w.scl, res)
+	// The START is detected when SDA falls and the address on the first rising
+	// edge of SCL.
+	if len(e) != 2 || e[0].t != 3*res || e[1].t != 6*res {
+		t.Fatal(e)
+	}
+}
+
+func TestDecodeSPI(t *testing.T) {
+	for mode := 0; mode < 4; mode++ {
+		w := &spiWave{mode: mode}
+		w.idle(true, 2)
+		// Ignored as CS is not asserted.
+		w.byte(0xff, 0xff, true)
+		w.idle(false, 2)
+		w.byte(0xa5, 0x3c, false)
+		w.byte(0x01, 0x80, false)
+		w.idle(true, 2)
+		expected := []string{"MOSI 0xa5 MISO 0x3c", "MOSI 0x01 MISO 0x80"}
+		if s := strs(decodeSPI(w.sclk, w.mosi, w.miso, w.cs, mode, res)); !reflect.DeepEqual(s, expected) {
+			t.Errorf("mode %d: %q != %q", mode, s, expected)
+		}
+		// Without MISO nor CS, all the bytes are decoded.
+		expected = []string{"MOSI 0xff", "MOSI 0xa5", "MOSI 0x01"}
+		if s := strs(decodeSPI(w.sclk, w.mosi, nil, nil, mode, res)); !reflect.DeepEqual(s, expected) {
+			t.Errorf("mode %d: %q != %q", mode, s, expected)
+		}
+	}
+}
+
+func TestDecodeSPI_deselected(t *testing.T) {
+	w := &spiWave{}
+	w.idle(false, 2)
+	w.bit(true, true, false)
+	w.bit(true, true, false)
+	// A partial byte is dropped when CS is released.
+	w.idle(true, 2)
+	w.idle(false, 2)
+	w.byte(0x42, 0x00, false)
+	expected := []string{"MOSI 0x42 MISO 0x00"}
+	if s := strs(decodeSPI(w.sclk, w.mosi, w.miso, w.cs, 0, res)); !reflect.DeepEqual(s, expected) {
+		t.Fatalf("%q != %q", s, expected)
+	}
+}
+
+func TestDecodeUART(t *testing.T) {
+	const bit = 8 * res
+	data := []struct {
+		name     string
+		frames   []uartFrame
+		expected []string
+	}{
+		{"printable", []uartFrame{{0x41, true}}, []string{`0x41 'A'`}},
+		{"control", []uartFrame{{0x0a, true}}, []string{"0x0a"}},
+		{"high", []uartFrame{{0xff, true}}, []string{"0xff"}},
+		{"framing error", []uartFrame{{0x55, false}}, []string{`0x55 'U' framing error`}},
+		{"sequence", []uartFrame{{'h', true}, {'i', true}}, []string{`0x68 'h'`, `0x69 'i'`}},
+	}
+	for _, line := range data {
+		var rx []gpio.Level
+		rx = appendLevel(rx, gpio.High, 10)
+		for _, f := range line.frames {
+			rx = f.append(rx, int(bit/res))
+		}
+		rx = appendLevel(rx, gpio.High, 20)
+		if s := strs(decodeUART(rx, bit, res)); !reflect.DeepEqual(s, line.expected) {
+			t.Errorf("%s: %q != %q", line.name, s, line.expected)
+		}
+	}
+}
+
+func TestDecodeUART_truncated(t *testing.T) {
+	// The capture ends before the middle of the stop bit.
+	var rx []gpio.Level
+	rx = appendLevel(rx, gpio.High, 10)
+	rx = uartFrame{0x41, true}.append(rx, 8)
+	rx = rx[:len(rx)-12]
+	if e := decodeUART(rx, 8*res, res); len(e) != 0 {
+		t.Fatal(e)
+	}
+}
+
+func TestDecodeOneWire(t *testing.T) {
+	data := []struct {
+		name     string
+		w        func(dq []gpio.Level) []gpio.Level
+		expected []string
+	}{
+		{
+			"reset presence",
+			func(dq []gpio.Level) []gpio.Level {
+				dq = oneWireReset(dq, true)
+				return oneWireByte(dq, 0xcc)
+			},
+			[]string{"RESET", "PRESENCE", "0xcc"},
+		},
+		{
+			"no presence",
+			func(dq []gpio.Level) []gpio.Level {
+				dq = oneWireReset(dq, false)
+				return oneWireByte(dq, 0x33)
+			},
+			[]string{"RESET", "0x33"},
+		},
+		{
+			"bytes",
+			func(dq []gpio.Level) []gpio.Level {
+				dq = oneWireByte(dq, 0x44)
+				return oneWireByte(dq, 0xbe)
+			},
+			[]string{"0x44", "0xbe"},
+		},
+	}
+	for _, line := range data {
+		dq := appendLevel(nil, gpio.High, 10)
+		dq = line.w(dq)
+		dq = appendLevel(dq, gpio.High, 10)
+		if s := strs(decodeOneWire(dq, res)); !reflect.DeepEqual(s, line.expected) {
+			t.Errorf("%s: %q != %q", line.name, s, line.expected)
+		}
+	}
+}
+
+func TestNewDecoder(t *testing.T) {
+	data := []struct {
+		protocol string
+		pins     int
+		baud     int
+		spiMode  int
+		res      time.Duration
+		ok       bool
+	}{
+		{"i2c", 2, 0, 0, res, true},
+		{"i2c", 1, 0, 0, res, false},
+		{"spi", 2, 0, 3, res, true},
+		{"spi", 4, 0, 0, res, true},
+		{"spi", 5, 0, 0, res, false},
+		{"spi", 2, 0, 4, res, false},
+		{"uart", 1, 9600, 0, res, true},
+		{"uart", 2, 9600, 0, res, false},
+		{"uart", 1, 0, 0, res, false},
+		{"uart", 1, 9600, 0, 100 * res, false},
+		{"1wire", 1, 0, 0, res, true},
+		{"1wire", 1, 0, 0, 10 * res, false},
+		{"can", 1, 0, 0, res, false},
+	}
+	for i, line := range data {
+		d, err := newDecoder(line.protocol, line.pins, line.baud, line.spiMode, line.res)
+		if ok := err == nil; ok != line.ok || (d != nil) != line.ok {
+			t.Errorf("#%d: %s: %v", i, line.protocol, err)
+		}
+	}
+}
+
+//
+
+func strs(e []event) []string {
+	var out []string
+	for _, x := range e {
+		out = append(out, x.s)
+	}
+	return out
+}
+
+func appendLevel(s []gpio.Level, l gpio.Level, n int) []gpio.Level {
+	for i := 0; i < n; i++ {
+		s = append(s, l)
+	}
+	return s
+}
+
+// i2cWave generates the samples of an I²C transaction, one sample per step.
+type i2cWave struct {
+	sda, scl []gpio.Level
+}
+
+func (w *i2cWave) add(sda, scl gpio.Level) {
+	w.sda = append(w.sda, sda)
+	w.scl = append(w.scl, scl)
+}
+
+// start generates a START, or a repeated START when SCL is low.
+func (w *i2cWave) start() {
+	w.add(true, false)
+	w.add(true, true)
+	w.add(false, true)
+	w.add(false, false)
+}
+
+func (w *i2cWave) stop() {
+	w.add(false, false)
+	w.add(false, true)
+	w.add(true, true)
+}
+
+func (w *i2cWave) bit(b gpio.Level) {
+	w.add(b, false)
+	w.add(b, true)
+	w.add(b, true)
+	w.add(b, false)
+}
+
+// byte generates 8 bits MSB first and the acknowledge bit.
+func (w *i2cWave) byte(v byte, ack bool) {
+	for i := uint(0); i < 8; i++ {
+		w.bit(v&(0x80>>i) != 0)
+	}
+	w.bit(!gpio.Level(ack))
+}
+
+// spiWave generates the samples of an SPI transfer in mode.
+type spiWave struct {
+	mode                 int
+	sclk, mosi, miso, cs []gpio.Level
+}
+
+func (w *spiWave) add(sclk, mosi, miso, cs gpio.Level) {
+	w.sclk = append(w.sclk, sclk)
+	w.mosi = append(w.mosi, mosi)
+	w.miso = append(w.miso, miso)
+	w.cs = append(w.cs, cs)
+}
+
+// idle holds the clock at its idle level for n samples.
+func (w *spiWave) idle(cs gpio.Level, n int) {
+	cpol := gpio.Level(w.mode >= 2)
+	for i := 0; i < n; i++ {
+		w.add(cpol, false, false, cs)
+	}
+}
+
+func (w *spiWave) bit(o, i, cs gpio.Level) {
+	cpol := gpio.Level(w.mode >= 2)
+	if w.mode&1 == 0 {
+		// The data is set before the leading edge, which samples it.
+		w.add(cpol, o, i, cs)
+		w.add(!cpol, o, i, cs)
+		w.add(cpol, o, i, cs)
+	} else {
+		// The data is set on the leading edge and sampled on the trailing edge.
+		w.add(cpol, false, false, cs)
+		w.add(!cpol, o, i, cs)
+		w.add(cpol, o, i, cs)
+	}
+}
+
+func (w *spiWave) byte(o, i byte, cs gpio.Level) {
+	for j := uint(0); j < 8; j++ {
+		w.bit(o&(0x80>>j) != 0, i&(0x80>>j) != 0, cs)
+	}
+}
+
+// uartFrame is a 8N1 frame; stop is false to generate a framing error.
+type uartFrame struct {
+	v    byte
+	stop bool
+}
+
+func (f uartFrame) append(rx []gpio.Level, samplesPerBit int) []gpio.Level {
+	rx = appendLevel(rx, gpio.Low, samplesPerBit)
+	for i := uint(0); i < 8; i++ {
+		rx = appendLevel(rx, f.v&(1<<i) != 0, samplesPerBit)
+	}
+	rx = appendLevel(rx, gpio.Level(f.stop), samplesPerBit)
+	// Idle between frames.
+	return appendLevel(rx, gpio.High, samplesPerBit)
+}
+
+// oneWireReset generates a reset pulse and optionally the presence pulse of
+// a device, with one sample per µs.
+func oneWireReset(dq []gpio.Level, presence bool) []gpio.Level {
+	dq = appendLevel(dq, gpio.Low, 480)
+	dq = appendLevel(dq, gpio.High, 30)
+	if presence {
+		dq = appendLevel(dq, gpio.Low, 120)
+	} else {
+		dq = appendLevel(dq, gpio.High, 120)
+	}
+	return appendLevel(dq, gpio.High, 330)
+}
+
+// oneWireByte generates 8 write slots, LSB first, with one sample per µs.
+func oneWireByte(dq []gpio.Level, v byte) []gpio.Level {
+	for i := uint(0); i < 8; i++ {
+		if v&(1<<i) != 0 {
+			dq = appendLevel(dq, gpio.Low, 6)
+			dq = appendLevel(dq, gpio.High, 64)
+		} else {
+			dq = appendLevel(dq, gpio.Low, 60)
+			dq = appendLevel(dq, gpio.High, 10)
+		}
+	}
+	return dq
+}
@@ -0,0 +1,307 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// gpio-capture samples GPIO pins like a logic analyzer.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpiostream"
+	"periph.io/x/periph/conn/gpio/gpioutil"
+	"periph.io/x/periph/experimental/devices/bitbang"
+	"periph.io/x/periph/host"
+	"periph.io/x/periph/host/bcm283x"
+	"periph.io/x/periph/host/cpu"
+)
+
+// sampler samples the pins n times at the resolution res.
+type sampler func(pull gpio.Pull, res time.Duration, n int) ([]*gpiostream.BitStream, error)
+
+// newSampler returns the sampler for the pins.
+//
+// A single pin is sampled with StreamIn(), in software when the driver doesn't
+// support streaming. Several bcm283x pins of the same bank are sampled at once
+// by reading the level register of their bank. Calling StreamIn() concurrently
+// on each pin is not an option as the calls would share the PWM clock and FIFO
+// used for pacing the DMA. Other pins are sampled in software, reading all of
+// them once per tick.
+func newSampler(pins []gpio.PinIO) (sampler, error) {
+	if len(pins) == 1 {
+		s, ok := pins[0].(gpiostream.PinIn)
+		if !ok {
+			s = bitbang.NewStreamIn(pins[0])
+		}
+		return func(pull gpio.Pull, res time.Duration, n int) ([]*gpiostream.BitStream, error) {
+			b := &gpiostream.BitStream{Bits: make(gpiostream.Bits, (n+7)/8), Res: res}
+			if err := s.StreamIn(pull, b); err != nil {
+				return nil, fmt.Errorf("%s: %v", pins[0], err)
+			}
+			return []*gpiostream.BitStream{b}, nil
+		}, nil
+	}
+	g, err := newBankGroup(pins)
+	if err == nil {
+		return func(pull gpio.Pull, res time.Duration, n int) ([]*gpiostream.BitStream, error) {
+			out := make([]*gpiostream.BitStream, len(pins))
+			s := make([]gpiostream.Stream, len(pins))
+			for i := range out {
+				out[i] = &gpiostream.BitStream{Bits: make(gpiostream.Bits, (n+7)/8), Res: res}
+				s[i] = out[i]
+			}
+			if err := g.StreamIn(pull, s); err != nil {
+				return nil, err
+			}
+			return out, nil
+		}, nil
+	}
+	log.Printf("%v; sampling in software", err)
+	sg, err := gpioutil.NewGroup(pins...)
+	if err != nil {
+		return nil, err
+	}
+	return func(pull gpio.Pull, res time.Duration, n int) ([]*gpiostream.BitStream, error) {
+		return sampleGroup(sg, pull, res, n)
+	}, nil
+}
+
+// newBankGroup returns a group to sample the pins with DMA if they are all
+// bcm283x pins of the same bank.
+func newBankGroup(pins []gpio.PinIO) (*bcm283x.Group, error) {
+	b := make([]*bcm283x.Pin, len(pins))
+	for i, p := range pins {
+		q, ok := p.(*bcm283x.Pin)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a bcm283x pin", p)
+		}
+		b[i] = q
+	}
+	return bcm283x.NewGroup(b...)
+}
+
+// sampleGroup samples the pins of g n times at the resolution res in
+// software.
+//
+// All the pins are read once per tick, one after the other, so the samples of
+// the pins are aligned within the time it takes to read them. It returns an
+// error when a sample was late by more than res.
+func sampleGroup(g gpio.Group, pull gpio.Pull, res time.Duration, n int) ([]*gpiostream.BitStream, error) {
+	pins := g.Pins()
+	for _, p := range pins {
+		if err := p.In(pull, gpio.NoEdge); err != nil {
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
+	}
+	out := make([]*gpiostream.BitStream, len(pins))
+	for i := range out {
+		out[i] = &gpiostream.BitStream{Bits: make(gpiostream.Bits, (n+7)/8), Res: res}
+	}
+	mask := uint32(1)<<uint(len(pins)) - 1
+	// This helps reduce jitter a little.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	var late time.Duration
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		t := time.Duration(i) * res
+		waitUntil(start.Add(t))
+		v := g.Read(mask)
+		if d := time.Since(start) - t; d > late {
+			late = d
+		}
+		for j, b := range out {
+			if v&(1<<uint(j)) != 0 {
+				b.Bits[i/8] |= 1 << uint(i%8)
+			}
+		}
+	}
+	if late > res {
+		return nil, fmt.Errorf("jitter of %s exceeded the resolution of %s; lower the sample rate", late, res)
+	}
+	return out, nil
+}
+
+// waitUntil sleeps then spins until t.
+func waitUntil(t time.Time) {
+	const spinThreshold = 100 * time.Microsecond
+	if w := t.Sub(time.Now()); w > spinThreshold {
+		time.Sleep(w - spinThreshold)
+	}
+	for w := t.Sub(time.Now()); w > 0; w = t.Sub(time.Now()) {
+		cpu.Nanospin(w)
+	}
+}
+
+// levels returns the first n samples of a BitStream.
+func levels(b *gpiostream.BitStream, n int) []gpio.Level {
+	out := make([]gpio.Level, n)
+	for i := range out {
+		out[i] = b.Bits[i/8]&(1<<uint(i%8)) != 0
+	}
+	return out
+}
+
+func parsePull(s string) (gpio.Pull, error) {
+	switch s {
+	case "":
+		return gpio.PullNoChange, nil
+	case "float":
+		return gpio.Float, nil
+	case "down":
+		return gpio.PullDown, nil
+	case "up":
+		return gpio.PullUp, nil
+	default:
+		return gpio.PullNoChange, fmt.Errorf("invalid pull %q", s)
+	}
+}
+
+func parseEdge(s string) (gpio.Edge, error) {
+	switch s {
+	case "":
+		return gpio.NoEdge, nil
+	case "rising":
+		return gpio.RisingEdge, nil
+	case "falling":
+		return gpio.FallingEdge, nil
+	case "both":
+		return gpio.BothEdges, nil
+	default:
+		return gpio.NoEdge, fmt.Errorf("invalid trigger %q", s)
+	}
+}
+
+func mainImpl() error {
+	rate := flag.Int("r", 100000, "sample rate in Hz")
+	duration := flag.Duration("d", 100*time.Millisecond, "duration of the capture")
+	pullName := flag.String("p", "", "pull resistor: up, down, float; unchanged by default")
+	trigger := flag.String("t", "", "start on an edge of the first pin: rising, falling, both; immediate by default")
+	format := flag.String("f", "wave", "output format: vcd, csv, wave")
+	output := flag.String("o", "", "output file; stdout by default")
+	width := flag.Int("w", 80, "width of the wave output")
+	protocol := flag.String("decode", "", "protocol to decode, with the pins in order: i2c (SDA SCL), spi (SCLK MOSI [MISO [CS]]), uart (RX), 1wire (DQ)")
+	baud := flag.Int("baud", 9600, "baud rate for -decode uart")
+	spiMode := flag.Int("spimode", 0, "SPI mode for -decode spi")
+	verbose := flag.Bool("v", false, "enable verbose logs")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(ioutil.Discard)
+	}
+	log.SetFlags(log.Lmicroseconds)
+
+	if flag.NArg() == 0 {
+		return errors.New("specify the GPIO pins to capture")
+	}
+	if *rate <= 0 || *rate > int(time.Second) {
+		return errors.New("specify a valid sample rate")
+	}
+	res := time.Second / time.Duration(*rate)
+	n := int(*duration / res)
+	if n <= 0 {
+		return errors.New("the duration must be longer than the sample period")
+	}
+	pull, err := parsePull(*pullName)
+	if err != nil {
+		return err
+	}
+	edge, err := parseEdge(*trigger)
+	if err != nil {
+		return err
+	}
+	var write func(w io.Writer, names []string, samples [][]gpio.Level, res time.Duration) error
+	switch *format {
+	case "vcd":
+		write = writeVCD
+	case "csv":
+		write = writeCSV
+	case "wave":
+		write = func(w io.Writer, names []string, samples [][]gpio.Level, res time.Duration) error {
+			return writeWave(w, names, samples, res, *width)
+		}
+	default:
+		return fmt.Errorf("invalid format %q", *format)
+	}
+	var decode decoder
+	if *protocol != "" {
+		if decode, err = newDecoder(*protocol, flag.NArg(), *baud, *spiMode, res); err != nil {
+			return err
+		}
+	}
+
+	if _, err := host.Init(); err != nil {
+		return err
+	}
+	pins := make([]gpio.PinIO, flag.NArg())
+	names := make([]string, flag.NArg())
+	for i, name := range flag.Args() {
+		p := gpioreg.ByName(name)
+		if p == nil {
+			return fmt.Errorf("invalid pin %q", name)
+		}
+		pins[i] = p
+		names[i] = p.Name()
+	}
+	capture, err := newSampler(pins)
+	if err != nil {
+		return err
+	}
+
+	if edge != gpio.NoEdge {
+		// The latency of the edge detection depends on the driver, so the edge
+		// itself is likely not captured.
+		if err := pins[0].In(pull, edge); err != nil {
+			return err
+		}
+		log.Printf("waiting for %s edge on %s", edge, pins[0])
+		if !pins[0].WaitForEdge(-1) {
+			return errors.New("failed to wait for the trigger")
+		}
+	}
+	log.Printf("capturing %d samples at %s", n, res)
+	streams, err := capture(pull, res, n)
+	if err != nil {
+		return err
+	}
+	samples := make([][]gpio.Level, len(streams))
+	for i, s := range streams {
+		samples[i] = levels(s, n)
+	}
+
+	if decode != nil {
+		for _, e := range decode(samples) {
+			fmt.Printf("%12s  %s\n", e.t, e.s)
+		}
+		if *output == "" {
+			return nil
+		}
+	}
+	w := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return write(w, names, samples, res)
+}
+
+func main() {
+	if err := mainImpl(); err != nil {
+		fmt.Fprintf(os.Stderr, "gpio-capture: %s.\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+)
+
+func TestNewSampler_software(t *testing.T) {
+	pins := []gpio.PinIO{
+		&gpiotest.Pin{N: "GPIO1", Num: 1, L: gpio.High},
+		&gpiotest.Pin{N: "GPIO2", Num: 2, L: gpio.Low},
+		&gpiotest.Pin{N: "GPIO3", Num: 3, L: gpio.High},
+	}
+	s, err := newSampler(pins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams, err := s(gpio.PullNoChange, 5*time.Millisecond, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 3 {
+		t.Fatal(streams)
+	}
+	for i, b := range streams {
+		for j, l := range levels(b, 10) {
+			if l != pins[i].Read() {
+				t.Fatalf("pin %d sample %d: %s", i, j, l)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiostream"
+)
+
+// writeVCD writes the samples as a Value Change Dump, to be opened with
+// GTKWave or PulseView.
+func writeVCD(w io.Writer, names []string, samples [][]gpio.Level, res time.Duration) error {
+	signals := make([]gpiostream.Signal, len(names))
+	for i := range names {
+		signals[i] = gpiostream.Signal{Name: names[i], Stream: toEdgeStream(samples[i], res)}
+	}
+	return gpiostream.WriteVCD(w, signals...)
+}
+
+// writeCSV writes one row per level change, with the time in nanoseconds.
+//
+// The last row is the last sample.
+func writeCSV(w io.Writer, names []string, samples [][]gpio.Level, res time.Duration) error {
+	c := csv.NewWriter(w)
+	if err := c.Write(append([]string{"time_ns"}, names...)); err != nil {
+		return err
+	}
+	n := len(samples[0])
+	row := make([]string, len(names)+1)
+	for i := 0; i < n; i++ {
+		changed := i == 0 || i == n-1
+		for j := range samples {
+			if i != 0 && samples[j][i] != samples[j][i-1] {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		row[0] = strconv.FormatInt(int64(time.Duration(i)*res), 10)
+		for j := range samples {
+			row[j+1] = "0"
+			if samples[j][i] {
+				row[j+1] = "1"
+			}
+		}
+		if err := c.Write(row); err != nil {
+			return err
+		}
+	}
+	c.Flush()
+	return c.Error()
+}
+
+// writeWave draws the samples in the terminal, one line per pin.
+//
+// Each column covers multiple samples; a column with both levels is drawn as
+// a cross.
+func writeWave(w io.Writer, names []string, samples [][]gpio.Level, res time.Duration, width int) error {
+	max := 0
+	for _, name := range names {
+		if len(name) > max {
+			max = len(name)
+		}
+	}
+	n := len(samples[0])
+	cols := width - max - 2
+	if cols <= 0 {
+		return fmt.Errorf("width must be larger than %d", max+2)
+	}
+	if cols > n {
+		cols = n
+	}
+	b := bufio.NewWriter(w)
+	for i, name := range names {
+		line := make([]string, cols)
+		for c := range line {
+			high, low := false, false
+			for _, l := range samples[i][c*n/cols : (c+1)*n/cols] {
+				if l {
+					high = true
+				} else {
+					low = true
+				}
+			}
+			switch {
+			case high && low:
+				line[c] = "╳"
+			case high:
+				line[c] = "▔"
+			default:
+				line[c] = "▁"
+			}
+		}
+		fmt.Fprintf(b, "%-*s  %s\n", max, name, strings.Join(line, ""))
+	}
+	end := (time.Duration(n) * res).String()
+	if len(end) < cols {
+		fmt.Fprintf(b, "%-*s  0%*s\n", max, "", cols-1, end)
+	}
+	return b.Flush()
+}
+
+// toEdgeStream converts samples into an EdgeStream.
+func toEdgeStream(samples []gpio.Level, res time.Duration) *gpiostream.EdgeStream {
+	e := &gpiostream.EdgeStream{Res: res}
+	if len(samples) == 0 {
+		return e
+	}
+	if !samples[0] {
+		e.Edges = append(e.Edges, 0)
+	}
+	start := 0
+	for i := 1; i <= len(samples); i++ {
+		if i == len(samples) || samples[i] != samples[start] {
+			e.Edges = append(e.Edges, time.Duration(i-start)*res)
+			start = i
+		}
+	}
+	return e
+}
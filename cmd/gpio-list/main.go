@@ -2,7 +2,7 @@
 // Use of this source code is governed under the Apache License, Version 2.0
 // that can be found in the LICENSE file.
 
-// gpio-list prints out the function of each GPIO pin.
+// gpio-list prints out the function of each GPIO pin and PWM channel.
 package main
 
 import (
@@ -15,6 +15,7 @@ import (
 
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/pin"
 	"periph.io/x/periph/conn/pin/pinreg"
 	"periph.io/x/periph/host"
 )
@@ -59,18 +60,54 @@ func printGPIO(invalid bool) {
 		}
 	}
 	for _, p := range all {
+		used := ""
+		if owner := gpioreg.Owner(p); owner != "" {
+			used = fmt.Sprintf(" (used by %s)", owner)
+		}
 		if pinreg.IsConnected(p) {
-			fmt.Printf("%-*s: %s\n", maxName, p, p.Function())
+			if used != "" {
+				fmt.Printf("%-*s: %-*s%s\n", maxName, p, maxFn, p.Function(), used)
+			} else {
+				fmt.Printf("%-*s: %s\n", maxName, p, p.Function())
+			}
 		} else if invalid {
-			fmt.Printf("%-*s: %-*s (not connected)\n", maxName, p, maxFn, p.Function())
+			fmt.Printf("%-*s: %-*s (not connected)%s\n", maxName, p, maxFn, p.Function(), used)
+		}
+	}
+}
+
+// printPWM prints the PWM channels that are on a header but are not GPIO
+// pins, like the ones exposed by sysfs.
+func printPWM() {
+	var pwms []pin.Pin
+	for _, hdr := range pinreg.All() {
+		for _, row := range hdr {
+			for _, p := range row {
+				if _, ok := p.(gpio.PinIO); ok {
+					continue
+				}
+				if _, ok := p.(gpio.PinPWM); ok {
+					pwms = append(pwms, p)
+				}
+			}
+		}
+	}
+	sort.Slice(pwms, func(i, j int) bool { return pwms[i].Name() < pwms[j].Name() })
+	max := 0
+	for _, p := range pwms {
+		if l := len(p.String()); l > max {
+			max = l
 		}
 	}
+	for _, p := range pwms {
+		fmt.Printf("%-*s: %s\n", max, p, p.Function())
+	}
 }
 
 func mainImpl() error {
 	all := flag.Bool("a", false, "print everything")
 	aliases := flag.Bool("l", false, "print aliases pins (e.g. I2C1_SCL)")
-	gpios := flag.Bool("g", false, "print GPIO pins (e.g. GPIO1) (default)")
+	gpios := flag.Bool("g", false, "print GPIO pins (e.g. GPIO1) and PWM channels (e.g. PWM0_0) (default)")
 	invalid := flag.Bool("n", false, "show not connected/INVALID pins")
 	verbose := flag.Bool("v", false, "enable verbose logs")
 	flag.Parse()
@@ -96,6 +133,7 @@ func mainImpl() error {
 	}
 	if *gpios {
 		printGPIO(*invalid)
+		printPWM()
 	}
 	return nil
 }
@@ -88,7 +88,45 @@ func printHardware(invalid bool, all map[string][][]pin.Pin) {
 	}
 }
 
+func printAliases(b *pinreg.Board) {
+	names := make([]string, 0, len(b.Aliases))
+	max := 0
+	for name := range b.Aliases {
+		names = append(names, name)
+		if l := len(name); l > max {
+			max = l
+		}
+	}
+	sort.Strings(names)
+	activeLow := map[string]bool{}
+	for _, name := range b.ActiveLow {
+		activeLow[name] = true
+	}
+	fmt.Print("\nAliases:\n")
+	for _, name := range names {
+		if activeLow[name] {
+			fmt.Printf("  %-*s -> %s (active low)\n", max, name, b.Aliases[name])
+		} else {
+			fmt.Printf("  %-*s -> %s\n", max, name, b.Aliases[name])
+		}
+	}
+}
+
+func loadBoard(path string) (*pinreg.Board, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := pinreg.ParseBoard(f)
+	if err != nil {
+		return nil, err
+	}
+	return b, pinreg.RegisterBoard(b)
+}
+
 func mainImpl() error {
+	board := flag.String("b", "", "JSON board description to load, e.g. for a HAT")
 	invalid := flag.Bool("n", false, "show not connected/INVALID pins")
 	verbose := flag.Bool("v", false, "enable verbose logs")
 	flag.Parse()
@@ -101,6 +139,12 @@ func mainImpl() error {
 	if err != nil {
 		return err
 	}
+	var b *pinreg.Board
+	if *board != "" {
+		if b, err = loadBoard(*board); err != nil {
+			return err
+		}
+	}
 	all := pinreg.All()
 	if len(all) == 0 && len(state.Failed) != 0 {
 		fmt.Fprintf(os.Stderr, "Got the following driver failures:\n")
@@ -118,6 +162,9 @@ func mainImpl() error {
 			printHardware(*invalid, map[string][][]pin.Pin{name: hdr})
 		}
 	}
+	if b != nil && len(b.Aliases) != 0 {
+		printAliases(b)
+	}
 	return nil
 }
 
@@ -0,0 +1,119 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// i2c-scan probes all the addresses on an I²C bus.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/smbus"
+	"periph.io/x/periph/host"
+)
+
+var probes = map[string]i2c.Probe{
+	"quick": smbus.ProbeQuick,
+	"read":  i2c.ProbeRead,
+	"write": i2c.ProbeWrite,
+}
+
+// printGrid prints the addresses in the same layout as i2cdetect.
+func printGrid(found []uint16) {
+	present := map[uint16]bool{}
+	for _, a := range found {
+		present[a] = true
+	}
+	fmt.Print("    ")
+	for i := 0; i < 16; i++ {
+		fmt.Printf(" %x ", i)
+	}
+	fmt.Print("\n")
+	for addr := uint16(0); addr < 0x80; addr++ {
+		if addr%16 == 0 {
+			fmt.Printf("%02x:", addr)
+		}
+		switch {
+		case i2c.IsReserved(addr):
+			fmt.Print("   ")
+		case present[addr]:
+			fmt.Printf(" %02x", addr)
+		default:
+			fmt.Print(" --")
+		}
+		if addr%16 == 15 {
+			fmt.Print("\n")
+		}
+	}
+}
+
+func mainImpl() error {
+	busName := flag.String("b", "", "I²C bus to use")
+	probe := flag.String("p", "read", "probe method to use: quick, read or write")
+	fallback := flag.Bool("fallback", false, "use the read probe if the bus doesn't support the one requested")
+	asJSON := flag.Bool("json", false, "print the result as JSON")
+	verbose := flag.Bool("v", false, "verbose mode")
+	flag.Parse()
+	if !*verbose {
+		log.SetOutput(ioutil.Discard)
+	}
+	log.SetFlags(log.Lmicroseconds)
+	if flag.NArg() != 0 {
+		return errors.New("unexpected argument, try -help")
+	}
+	p := probes[*probe]
+	if p == nil {
+		return fmt.Errorf("unknown probe method %q", *probe)
+	}
+
+	if _, err := host.Init(); err != nil {
+		return err
+	}
+	bus, err := i2creg.Open(*busName)
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+
+	found, err := i2c.Scan(bus, p)
+	if *fallback && conn.IsKind(err, conn.ErrUnsupported) {
+		log.Printf("%s probe is not supported, using read: %v", *probe, err)
+		found, err = i2c.Scan(bus, i2c.ProbeRead)
+	}
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		out := struct {
+			Bus       string   `json:"bus"`
+			Addresses []uint16 `json:"addresses"`
+		}{fmt.Sprintf("%s", bus), found}
+		if out.Addresses == nil {
+			out.Addresses = []uint16{}
+		}
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s\n", b)
+		return err
+	}
+	printGrid(found)
+	return nil
+}
+
+func main() {
+	if err := mainImpl(); err != nil {
+		fmt.Fprintf(os.Stderr, "i2c-scan: %s.\n", err)
+		os.Exit(1)
+	}
+}
@@ -6,10 +6,14 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 
 	"periph.io/x/periph"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
 	"periph.io/x/periph/host"
 )
 
@@ -29,7 +33,47 @@ func printDrivers(drivers []periph.DriverFailure) {
 	}
 }
 
+// printI2C prints the devices found on each I²C bus.
+//
+// It reads one byte at each address, which may have side effects on some
+// devices.
+func printI2C() {
+	refs := i2creg.All()
+	if len(refs) == 0 {
+		fmt.Print("  <none>\n")
+		return
+	}
+	for _, ref := range refs {
+		bus, err := ref.Open()
+		if err != nil {
+			fmt.Printf("- %s: %v\n", ref.Name, err)
+			continue
+		}
+		found, err := i2c.Scan(bus, i2c.ProbeRead)
+		bus.Close()
+		if err != nil {
+			fmt.Printf("- %s: %v\n", ref.Name, err)
+			continue
+		}
+		if len(found) == 0 {
+			fmt.Printf("- %s: <none>\n", ref.Name)
+			continue
+		}
+		fmt.Printf("- %s:", ref.Name)
+		for _, a := range found {
+			fmt.Printf(" 0x%02X", a)
+		}
+		fmt.Print("\n")
+	}
+}
+
 func mainImpl() error {
+	scanI2C := flag.Bool("i2c", false, "probe the devices on each I²C bus by reading one byte at each address")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		return errors.New("unexpected argument, try -help")
+	}
+
 	state, err := host.Init()
 	if err != nil {
 		return err
@@ -58,6 +102,10 @@ func mainImpl() error {
 	printDrivers(state.Skipped)
 	fmt.Printf("Drivers failed to load and the error:\n")
 	printDrivers(state.Failed)
+	if *scanI2C {
+		fmt.Printf("I²C devices found on each bus:\n")
+		printI2C()
+	}
 	return err
 }
 
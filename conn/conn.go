@@ -4,7 +4,10 @@
 
 package conn
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 // Resource is a basic resource (like a gpio pin) or a device.
 //
@@ -101,3 +104,74 @@ type Limits interface {
 	// Returns 0 if undefined.
 	MaxTxSize() int
 }
+
+// TxContexter is implemented by a Conn that natively supports a context for
+// its transactions.
+//
+// Users should call TxContext() instead of asserting this interface directly.
+type TxContexter interface {
+	// TxContext does a single transaction like Conn.Tx() but returns
+	// ctx.Err() without starting it if ctx is already done.
+	//
+	// Whether a transaction already started is interrupted when ctx is done
+	// depends on the implementation, which must document it.
+	TxContext(ctx context.Context, w, r []byte) error
+}
+
+// TxContext does a single transaction on c unless ctx is already done.
+//
+// If c implements TxContexter, its TxContext() method is used. Otherwise the
+// transaction is run via RunContext(), which doesn't interrupt it once
+// started.
+func TxContext(ctx context.Context, c Conn, w, r []byte) error {
+	if t, ok := c.(TxContexter); ok {
+		return t.TxContext(ctx, w, r)
+	}
+	return RunContext(ctx, w, r, c.Tx)
+}
+
+// RunContext runs the transaction tx unless ctx is already done, in which
+// case it returns ctx.Err().
+//
+// It is meant to be used by packages implementing the context aware
+// variants of their own transaction functions for buses that do not
+// natively support cancelation. ctx is only checked before the transaction
+// starts: a transaction already on the wire cannot be interrupted, so tx
+// always runs to completion and its result is returned, even if ctx is done
+// in the meantime.
+func RunContext(ctx context.Context, w, r []byte, tx func(w, r []byte) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return tx(w, r)
+}
+
+// WithContext returns a Conn that runs every transaction through
+// TxContext() with ctx.
+//
+// This is useful to apply a deadline to the I/O done by an existing device
+// driver that doesn't accept a context.Context. Once ctx is done, the next
+// transactions fail; the one in flight completes.
+func WithContext(ctx context.Context, c Conn) Conn {
+	return &ctxConn{ctx: ctx, c: c}
+}
+
+// ctxConn implements Conn with the transactions bound to a context.
+type ctxConn struct {
+	ctx context.Context
+	c   Conn
+}
+
+func (c *ctxConn) String() string {
+	return fmt.Sprint(c.c)
+}
+
+func (c *ctxConn) Tx(w, r []byte) error {
+	return TxContext(c.ctx, c.c, w, r)
+}
+
+func (c *ctxConn) Duplex() Duplex {
+	return c.c.Duplex()
+}
+
+var _ Conn = &ctxConn{}
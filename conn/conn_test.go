@@ -5,8 +5,13 @@
 package conn
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"testing"
+	"time"
 )
 
 func ExampleConn() {
@@ -22,8 +27,265 @@ func ExampleConn() {
 	}
 }
 
+func ExampleTxContext() {
+	// Get a connection from one of the registries, for example:
+	//   b, _ := spireg.Open("SPI0.0")
+	//   c, _ := b.DevParams(1000000, spi.Mode3, 8)
+	var c Conn
+
+	// Give up if the device doesn't reply within 100ms.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	reply := [4]byte{}
+	if err := TxContext(ctx, c, []byte("command"), reply[:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExampleError() {
+	// Get a connection from one of the registries, for example:
+	//   b, _ := i2creg.Open("")
+	//   c := &i2c.Dev{Bus: b, Addr: 0x76}
+	var c Conn
+
+	// Retry only when the device didn't acknowledge, e.g. because it is busy
+	// writing to its EEPROM.
+	for i := 0; i < 3; i++ {
+		err := c.Tx([]byte("command"), nil)
+		if err == nil {
+			break
+		}
+		if !IsKind(err, ErrNotAcknowledged) {
+			log.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
 func TestDuplex(t *testing.T) {
 	if Half.String() != "Half" || Duplex(10).String() != "Duplex(10)" {
 		t.Fatal()
 	}
 }
+
+func TestTxContext(t *testing.T) {
+	c := &blockingConn{r: []byte{1, 2}}
+	r := make([]byte, 2)
+	if err := TxContext(context.Background(), c, []byte{3}, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, []byte{1, 2}) {
+		t.Fatal(r)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := TxContext(ctx, c, []byte{3}, r); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := TxContext(ctx, c, []byte{3}, r); err != context.Canceled {
+		t.Fatal(err)
+	}
+}
+
+func TestTxContext_deadline(t *testing.T) {
+	c := &blockingConn{block: make(chan struct{}), r: []byte{1, 2}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	go func() {
+		// The transaction completes only after the deadline.
+		<-ctx.Done()
+		time.Sleep(time.Millisecond)
+		close(c.block)
+	}()
+	r := make([]byte, 2)
+	// The transaction was started, so its result is returned.
+	if err := TxContext(ctx, c, []byte{3}, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, []byte{1, 2}) {
+		t.Fatal(r)
+	}
+	if err := TxContext(ctx, c, []byte{3}, r); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+}
+
+func TestTxContext_native(t *testing.T) {
+	n := &nativeConn{&blockingConn{}}
+	if err := TxContext(context.Background(), n, nil, nil); err != errNative {
+		t.Fatal(err)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := WithContext(ctx, &blockingConn{})
+	if s := c.(fmt.Stringer).String(); s != "blocking" {
+		t.Fatal(s)
+	}
+	if d := c.Duplex(); d != Half {
+		t.Fatal(d)
+	}
+	if err := c.Tx(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := c.Tx(nil, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+}
+
+func TestError(t *testing.T) {
+	cause := errors.New("cause")
+	var err error = &Error{Kind: ErrTimeout, Msg: "drv: oops", Err: cause}
+	if s := err.Error(); s != "drv: oops: cause" {
+		t.Fatal(s)
+	}
+	if !IsKind(err, ErrTimeout) || IsKind(err, ErrBusBusy) || err.(*Error).Unwrap() != cause {
+		t.Fatal("IsKind")
+	}
+	wrapped := &Error{Kind: ErrBusBusy, Msg: "dev", Err: err}
+	if !IsKind(wrapped, ErrTimeout) || !IsKind(wrapped, ErrBusBusy) || IsKind(wrapped, ErrUnsupported) {
+		t.Fatal("wrapped")
+	}
+	if !IsKind(ErrTimeout, ErrTimeout) || IsKind(nil, ErrTimeout) || IsKind(cause, ErrTimeout) {
+		t.Fatal("plain")
+	}
+	if s := (&Error{Kind: ErrUnsupported, Msg: "drv: nope"}).Error(); s != "drv: nope" {
+		t.Fatal(s)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	f := &flakyConn{fails: 2, err: &Error{Kind: ErrBusBusy, Msg: "flaky"}}
+	c := WithRetry(f, nil)
+	if s := fmt.Sprint(c); s != "flaky" {
+		t.Fatal(s)
+	}
+	if c.Duplex() != Half {
+		t.Fatal(c.Duplex())
+	}
+	if err := c.Tx(nil, nil); err != nil || f.calls != 3 {
+		t.Fatal(err, f.calls)
+	}
+	// Exhausted.
+	f.calls = 0
+	f.fails = 10
+	if err := c.Tx(nil, nil); !IsKind(err, ErrBusBusy) || f.calls != 3 {
+		t.Fatal(err, f.calls)
+	}
+	// Not transient.
+	f.calls = 0
+	f.err = errors.New("permanent")
+	if err := c.Tx(nil, nil); err != f.err || f.calls != 1 {
+		t.Fatal(err, f.calls)
+	}
+}
+
+func TestRetry_Backoff(t *testing.T) {
+	f := &flakyConn{fails: 3, err: &Error{Kind: ErrTimeout, Msg: "flaky"}}
+	r := &Retry{Attempts: 4, Backoff: time.Microsecond, MaxBackoff: 2 * time.Microsecond}
+	if err := WithRetry(f, r).Tx(nil, nil); err != nil || f.calls != 4 {
+		t.Fatal(err, f.calls)
+	}
+	// Custom Transient.
+	f.calls = 0
+	f.fails = 1
+	f.err = errNative
+	r = &Retry{Transient: func(err error) bool { return err == errNative }}
+	if err := WithRetry(f, r).Tx(nil, nil); err != nil || f.calls != 2 {
+		t.Fatal(err, f.calls)
+	}
+}
+
+func TestRetry_NotAcknowledged(t *testing.T) {
+	f := &flakyConn{fails: 1, err: &Error{Kind: ErrNotAcknowledged, Msg: "flaky"}}
+	if err := WithRetry(f, nil).Tx(nil, nil); !IsKind(err, ErrNotAcknowledged) || f.calls != 1 {
+		t.Fatal(err, f.calls)
+	}
+	f.calls = 0
+	if err := WithRetry(f, &Retry{NotAcknowledged: true}).Tx(nil, nil); err != nil || f.calls != 2 {
+		t.Fatal(err, f.calls)
+	}
+}
+
+func TestRetry_TxContext(t *testing.T) {
+	f := &flakyConn{fails: 10, err: &Error{Kind: ErrTimeout, Msg: "flaky"}}
+	r := &Retry{Attempts: 10, Backoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := TxContext(ctx, WithRetry(f, r), nil, nil); err != context.DeadlineExceeded || f.calls != 1 {
+		t.Fatal(err, f.calls)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	f.calls = 0
+	if err := TxContext(ctx, WithRetry(f, nil), nil, nil); err != context.Canceled || f.calls != 0 {
+		t.Fatal(err, f.calls)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	if IsTransient(errors.New("foo")) || IsTransient(&Error{Kind: ErrUnsupported}) || IsTransient(&Error{Kind: ErrNotAcknowledged}) || IsTransient(&Error{Kind: ErrInUse}) {
+		t.Fatal("not transient")
+	}
+	if !IsTransient(&Error{Msg: "wrapped", Err: &Error{Kind: ErrTimeout}}) {
+		t.Fatal("transient")
+	}
+}
+
+//
+
+var errNative = errors.New("native")
+
+// flakyConn fails the first fails transactions with err.
+type flakyConn struct {
+	fails int
+	err   error
+	calls int
+}
+
+func (f *flakyConn) String() string {
+	return "flaky"
+}
+
+func (f *flakyConn) Tx(w, r []byte) error {
+	f.calls++
+	if f.calls <= f.fails {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyConn) Duplex() Duplex {
+	return Half
+}
+
+type blockingConn struct {
+	block chan struct{}
+	r     []byte
+}
+
+func (b *blockingConn) String() string {
+	return "blocking"
+}
+
+func (b *blockingConn) Tx(w, r []byte) error {
+	if b.block != nil {
+		<-b.block
+	}
+	copy(r, b.r)
+	return nil
+}
+
+func (b *blockingConn) Duplex() Duplex {
+	return Half
+}
+
+type nativeConn struct {
+	Conn
+}
+
+func (n *nativeConn) TxContext(ctx context.Context, w, r []byte) error {
+	return errNative
+}
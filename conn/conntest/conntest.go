@@ -6,10 +6,12 @@
 package conntest
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"math/rand"
 	"sync"
+	"time"
 
 	"periph.io/x/periph/conn"
 )
@@ -44,6 +46,14 @@ func (r *RecordRaw) Tx(w, read []byte) error {
 	return err
 }
 
+// TxContext implements conn.TxContexter.
+func (r *RecordRaw) TxContext(ctx context.Context, w, read []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.Tx(w, read)
+}
+
 // Duplex implements conn.Conn.
 func (r *RecordRaw) Duplex() conn.Duplex {
 	return conn.Half
@@ -53,6 +63,15 @@ func (r *RecordRaw) Duplex() conn.Duplex {
 type IO struct {
 	W []byte
 	R []byte
+	// WMask, if not nil, selects the bits of W that are verified by Playback.
+	// A 0 byte is a don't-care byte. The bytes of W past the end of WMask are
+	// verified.
+	WMask []byte
+	// Repeat is the number of additional times the IO may be matched by
+	// Playback. Playback moves on to the next IO as soon as a transaction
+	// doesn't match the repeated one, so that a status register polled a
+	// variable number of times can be replayed.
+	Repeat int
 }
 
 // Record implements conn.Conn that records everything written to it.
@@ -70,6 +89,21 @@ func (r *Record) String() string {
 
 // Tx implements conn.Conn.
 func (r *Record) Tx(w, read []byte) error {
+	return r.txInternal(context.Background(), w, read)
+}
+
+// TxContext implements conn.TxContexter.
+//
+// The context is forwarded to Conn. The I/O is not recorded if the transaction
+// fails, including when ctx is already done.
+func (r *Record) TxContext(ctx context.Context, w, read []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.txInternal(ctx, w, read)
+}
+
+func (r *Record) txInternal(ctx context.Context, w, read []byte) error {
 	io := IO{}
 	if len(w) != 0 {
 		io.W = make([]byte, len(w))
@@ -82,7 +116,7 @@ func (r *Record) Tx(w, read []byte) error {
 			return Errorf("conntest: read unsupported when no bus is connected")
 		}
 	} else {
-		if err := r.Conn.Tx(w, read); err != nil {
+		if err := conn.TxContext(ctx, r.Conn, w, read); err != nil {
 			return err
 		}
 	}
@@ -115,6 +149,8 @@ type Playback struct {
 	D         conn.Duplex
 	Count     int
 	DontPanic bool
+
+	hits int // number of times Ops[Count] has been matched
 }
 
 func (p *Playback) String() string {
@@ -125,6 +161,10 @@ func (p *Playback) String() string {
 func (p *Playback) Close() error {
 	p.Lock()
 	defer p.Unlock()
+	if p.hits != 0 {
+		p.Count++
+		p.hits = 0
+	}
 	if len(p.Ops) != p.Count {
 		return errorf(p.DontPanic, "conntest: expected playback to be empty: I/O count %d; expected %d", p.Count, len(p.Ops))
 	}
@@ -135,20 +175,38 @@ func (p *Playback) Close() error {
 func (p *Playback) Tx(w, r []byte) error {
 	p.Lock()
 	defer p.Unlock()
+	if p.hits != 0 && !p.matches(w, r) {
+		// The repeated IO is done.
+		p.Count++
+		p.hits = 0
+	}
 	if len(p.Ops) <= p.Count {
 		return errorf(p.DontPanic, "conntest: unexpected Tx() (count #%d) expecting []conntest.IO{W:%#v, R:%#v}", p.Count, w, r)
 	}
-	if !bytes.Equal(p.Ops[p.Count].W, w) {
+	if !EqualMask(w, p.Ops[p.Count].W, p.Ops[p.Count].WMask) {
 		return errorf(p.DontPanic, "conntest: unexpected write (count #%d) %#v != %#v", p.Count, w, p.Ops[p.Count].W)
 	}
 	if len(p.Ops[p.Count].R) != len(r) {
 		return errorf(p.DontPanic, "conntest: unexpected read buffer length (count #%d) %d != %d", p.Count, len(r), len(p.Ops[p.Count].R))
 	}
 	copy(r, p.Ops[p.Count].R)
-	p.Count++
+	if p.hits++; p.hits > p.Ops[p.Count].Repeat {
+		p.Count++
+		p.hits = 0
+	}
 	return nil
 }
 
+// TxContext implements conn.TxContexter.
+//
+// It returns ctx.Err() without consuming an Ops entry if ctx is done.
+func (p *Playback) TxContext(ctx context.Context, w, r []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.Tx(w, r)
+}
+
 // Duplex implements conn.Conn.
 func (p *Playback) Duplex() conn.Duplex {
 	p.Lock()
@@ -156,6 +214,27 @@ func (p *Playback) Duplex() conn.Duplex {
 	return p.D
 }
 
+// EqualMask returns true if a and b have the same length and the bits selected
+// by mask are equal.
+//
+// A nil mask selects all the bits. The bytes past the end of mask are
+// compared entirely.
+func EqualMask(a, b, mask []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		m := byte(0xFF)
+		if i < len(mask) {
+			m = mask[i]
+		}
+		if (a[i]^b[i])&m != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // Discard implements conn.Conn and discards all writes and reads zeros. It
 // never fails.
 type Discard struct {
@@ -174,12 +253,118 @@ func (d *Discard) Tx(w, r []byte) error {
 	return nil
 }
 
+// TxContext implements conn.TxContexter.
+func (d *Discard) TxContext(ctx context.Context, w, r []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.Tx(w, r)
+}
+
 // Duplex implements conn.Conn.
 func (d *Discard) Duplex() conn.Duplex {
 	return d.D
 }
 
+// ErrInjected is the default error returned by Faults.
 //
+// It is a transient error as reported by conn.IsTransient().
+var ErrInjected error = &conn.Error{Kind: conn.ErrBusBusy, Msg: "conntest: injected fault"}
+
+// Faults describes the faults to inject into transactions.
+//
+// The faults are chosen with a pseudo random number generator seeded with
+// Seed, so that a test run is reproducible.
+type Faults struct {
+	Seed int64
+	// ErrorRate is the probability, between 0 and 1, that a transaction fails
+	// with Err without being done.
+	ErrorRate float64
+	// Err is the error returned; defaults to ErrInjected.
+	Err error
+	// DelayRate is the probability, between 0 and 1, that a transaction is
+	// delayed by Delay.
+	DelayRate float64
+	Delay     time.Duration
+	// FlipRate is the probability, between 0 and 1, that one random bit of the
+	// data read is flipped.
+	FlipRate float64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// Inject runs the transaction tx that reads into r, injecting faults.
+//
+// It is meant to be used by the fakes of the other XXXtest packages.
+func (f *Faults) Inject(r []byte, tx func() error) error {
+	fail, delay, flip := f.roll()
+	if delay {
+		time.Sleep(f.Delay)
+	}
+	if fail {
+		if f.Err != nil {
+			return f.Err
+		}
+		return ErrInjected
+	}
+	if err := tx(); err != nil {
+		return err
+	}
+	if flip >= 0 && len(r) != 0 {
+		flip %= len(r) * 8
+		r[flip/8] ^= 1 << uint(flip%8)
+	}
+	return nil
+}
+
+// Faulty implements conn.Conn. It forwards the transactions to Conn and
+// injects faults.
+type Faulty struct {
+	Conn conn.Conn
+	Faults
+}
+
+func (f *Faulty) String() string {
+	return fmt.Sprintf("faulty(%s)", f.Conn)
+}
+
+// Tx implements conn.Conn.
+func (f *Faulty) Tx(w, r []byte) error {
+	return f.Inject(r, func() error { return f.Conn.Tx(w, r) })
+}
+
+// Duplex implements conn.Conn.
+func (f *Faulty) Duplex() conn.Duplex {
+	return f.Conn.Duplex()
+}
+
+//
+
+// matches returns true if the transaction matches Ops[Count].
+func (p *Playback) matches(w, r []byte) bool {
+	return p.Count < len(p.Ops) && EqualMask(w, p.Ops[p.Count].W, p.Ops[p.Count].WMask) && len(p.Ops[p.Count].R) == len(r)
+}
+
+// roll chooses the faults to inject into the next transaction.
+//
+// The same number of values is consumed at each call so the sequence only
+// depends on Seed. flip is -1 if no bit is to be flipped.
+func (f *Faults) roll() (bool, bool, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rnd == nil {
+		f.rnd = rand.New(rand.NewSource(f.Seed))
+	}
+	fail := f.rnd.Float64() < f.ErrorRate
+	delay := f.rnd.Float64() < f.DelayRate
+	flip := f.rnd.Float64() < f.FlipRate
+	bit := f.rnd.Intn(1 << 20)
+	if !flip {
+		bit = -1
+	}
+	return fail, delay, bit
+}
 
 // errorf is the internal implementation that optionally panic.
 //
@@ -199,3 +384,8 @@ type testErr struct {
 var _ conn.Conn = &RecordRaw{}
 var _ conn.Conn = &Record{}
 var _ conn.Conn = &Playback{}
+var _ conn.TxContexter = &RecordRaw{}
+var _ conn.TxContexter = &Record{}
+var _ conn.TxContexter = &Playback{}
+var _ conn.TxContexter = &Discard{}
+var _ conn.Conn = &Faulty{}
@@ -6,7 +6,12 @@ package conntest
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"periph.io/x/periph/conn"
 )
@@ -76,6 +81,32 @@ func TestPlayback(t *testing.T) {
 	}
 }
 
+func TestRecord_Playback_TxContext(t *testing.T) {
+	p := &Playback{Ops: []IO{{W: []byte{10}, R: []byte{12}}}}
+	r := Record{Conn: p}
+	ctx, cancel := context.WithCancel(context.Background())
+	v := [1]byte{}
+	if err := r.TxContext(ctx, []byte{10}, v[:]); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := r.TxContext(ctx, []byte{10}, v[:]); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := p.TxContext(ctx, []byte{10}, v[:]); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := (&Discard{}).TxContext(ctx, nil, v[:]); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if v[0] != 12 || len(r.Ops) != 1 {
+		t.Fatal(v, r.Ops)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestPlayback_Close_panic(t *testing.T) {
 	p := Playback{Ops: []IO{{W: []byte{10}}}}
 	defer func() {
@@ -218,3 +249,190 @@ func TestDiscard(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFaulty(t *testing.T) {
+	f := &Faulty{Conn: &Discard{D: conn.Half}, Faults: Faults{ErrorRate: 1}}
+	if s := f.String(); s != "faulty(discard)" {
+		t.Fatal(s)
+	}
+	if v := f.Duplex(); v != conn.Half {
+		t.Fatal(v)
+	}
+	if err := f.Tx(nil, nil); err != ErrInjected || !conn.IsTransient(err) {
+		t.Fatal(err)
+	}
+	errFoo := errors.New("foo")
+	f = &Faulty{Conn: &Discard{}, Faults: Faults{ErrorRate: 1, Err: errFoo}}
+	if err := f.Tx(nil, nil); err != errFoo {
+		t.Fatal(err)
+	}
+}
+
+func TestFaulty_flip(t *testing.T) {
+	f := &Faulty{Conn: &Discard{}, Faults: Faults{FlipRate: 1}}
+	for i := 0; i < 10; i++ {
+		var r [4]byte
+		if err := f.Tx(nil, r[:]); err != nil {
+			t.Fatal(err)
+		}
+		if n := bits(r[:]); n != 1 {
+			t.Fatal(r, n)
+		}
+	}
+}
+
+func TestFaulty_delay(t *testing.T) {
+	f := &Faulty{Conn: &Discard{}, Faults: Faults{DelayRate: 1, Delay: time.Millisecond}}
+	start := time.Now()
+	if err := f.Tx(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < time.Millisecond {
+		t.Fatal(d)
+	}
+}
+
+func TestFaulty_seed(t *testing.T) {
+	run := func(seed int64) []bool {
+		f := &Faulty{Conn: &Discard{}, Faults: Faults{Seed: seed, ErrorRate: 0.5}}
+		var out []bool
+		for i := 0; i < 64; i++ {
+			out = append(out, f.Tx(nil, nil) == nil)
+		}
+		return out
+	}
+	a := run(1)
+	if !reflect.DeepEqual(a, run(1)) {
+		t.Fatal("same seed must be reproducible")
+	}
+	if reflect.DeepEqual(a, run(2)) {
+		t.Fatal("different seeds should differ")
+	}
+}
+
+//
+
+func bits(b []byte) int {
+	n := 0
+	for _, v := range b {
+		for ; v != 0; v &= v - 1 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSave_Load(t *testing.T) {
+	ops := []IO{{W: []byte{10, 11}, R: []byte{1, 2}}, {W: []byte{12}}, {R: []byte{0}}}
+	var b bytes.Buffer
+	if err := Save(&b, ops); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "{\"version\":1,\"kind\":\"conn\",\"ops\":[\n" +
+		"{\"w\":\"0a0b\",\"r\":\"0102\"},\n" +
+		"{\"w\":\"0c\"},\n" +
+		"{\"r\":\"00\"}\n" +
+		"]}\n"
+	if s := b.String(); s != expected {
+		t.Fatalf("%q", s)
+	}
+	got, err := Load(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ops, got) {
+		t.Fatalf("%#v != %#v", ops, got)
+	}
+	p := Playback{Ops: got}
+	v := [2]byte{}
+	if err := p.Tx([]byte{10, 11}, v[:]); err != nil || v != [2]byte{1, 2} {
+		t.Fatal(err, v)
+	}
+}
+
+func TestSave_empty(t *testing.T) {
+	var b bytes.Buffer
+	if err := Save(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); s != "{\"version\":1,\"kind\":\"conn\",\"ops\":[\n]}\n" {
+		t.Fatalf("%q", s)
+	}
+	if ops, err := Load(&b); len(ops) != 0 || err != nil {
+		t.Fatal(ops, err)
+	}
+}
+
+func TestLoad_fail(t *testing.T) {
+	data := []string{
+		"",
+		"{\"version\":2,\"kind\":\"conn\",\"ops\":[]}",
+		"{\"version\":1,\"kind\":\"i2c\",\"ops\":[]}",
+		"{\"version\":1,\"kind\":\"conn\",\"ops\":[{\"w\":\"xx\"}]}",
+		"{\"version\":1,\"kind\":\"conn\",\"ops\":{}}",
+	}
+	for i, d := range data {
+		if _, err := Load(strings.NewReader(d)); err == nil {
+			t.Fatalf("#%d: expected error", i)
+		}
+	}
+}
+
+func TestPlayback_WMask(t *testing.T) {
+	p := Playback{
+		Ops:       []IO{{W: []byte{0x10, 0x20, 0x30}, WMask: []byte{0xF0, 0x00}}},
+		DontPanic: true,
+	}
+	if err := p.Tx([]byte{0x1F, 0xFF, 0x30}, nil); err != nil {
+		t.Fatal(err)
+	}
+	p.Count = 0
+	if p.Tx([]byte{0x20, 0x20, 0x30}, nil) == nil {
+		t.Fatal("masked bits differ")
+	}
+	if p.Tx([]byte{0x10, 0x20, 0x31}, nil) == nil {
+		t.Fatal("bytes past WMask are verified")
+	}
+}
+
+func TestPlayback_Repeat(t *testing.T) {
+	p := Playback{
+		Ops: []IO{
+			{W: []byte{0x01}, R: []byte{0x80}, Repeat: 3},
+			{W: []byte{0x01}, R: []byte{0x00}},
+			{W: []byte{0x02}, R: []byte{0xFF}, Repeat: 10},
+			{W: []byte{0x03}},
+			{W: []byte{0x04}, Repeat: 10},
+		},
+		DontPanic: true,
+	}
+	// Busy 4 times, then ready.
+	var got []byte
+	v := [1]byte{}
+	for i := 0; i < 5; i++ {
+		if err := p.Tx([]byte{0x01}, v[:]); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v[0])
+	}
+	if !bytes.Equal(got, []byte{0x80, 0x80, 0x80, 0x80, 0x00}) {
+		t.Fatal(got)
+	}
+	// Moves on when a transaction doesn't match the repeated IO.
+	if err := p.Tx([]byte{0x02}, v[:]); err != nil || v[0] != 0xFF {
+		t.Fatal(err, v)
+	}
+	if err := p.Tx([]byte{0x03}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if p.Count != 4 {
+		t.Fatal(p.Count)
+	}
+	if err := p.Tx([]byte{0x04}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// A repeated IO matched at least once is consumed.
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// TraceVersion is the version of the trace format written by Save.
+//
+// The trace format is JSON with one I/O per line, so that golden files can be
+// reviewed and diffed easily:
+//
+//	{"version":1,"kind":"conn","ops":[
+//	{"w":"0a0b","r":"0102"},
+//	{"w":"0c"}
+//	]}
+//
+// Bytes are encoded as lowercase hexadecimal strings.
+const TraceVersion = 1
+
+// Hex is a byte slice that is encoded as a hexadecimal string in JSON.
+//
+// It is meant to be used by the fakes of the other XXXtest packages to encode
+// their IO.
+type Hex []byte
+
+// MarshalJSON implements json.Marshaler.
+func (h Hex) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(h))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (h *Hex) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	d, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(d) == 0 {
+		d = nil
+	}
+	*h = d
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (i IO) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ioJSON{W: i.W, R: i.R, WMask: i.WMask, Repeat: i.Repeat})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (i *IO) UnmarshalJSON(b []byte) error {
+	var j ioJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	*i = IO{W: j.W, R: j.R, WMask: j.WMask, Repeat: j.Repeat}
+	return nil
+}
+
+// Save writes ops as a trace to w.
+//
+// Use it to save the Ops of a Record as a golden file, to be loaded back with
+// Load into a Playback.
+func Save(w io.Writer, ops []IO) error {
+	return WriteTrace(w, "conn", len(ops), func(i int) interface{} { return ops[i] })
+}
+
+// Load reads a trace written by Save.
+func Load(r io.Reader) ([]IO, error) {
+	var ops []IO
+	if err := ReadTrace(r, "conn", &ops); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// WriteTrace writes a trace of n I/O of the specified kind to w.
+//
+// op(i) must return the i-th I/O, which is encoded in JSON.
+//
+// It is meant to be used by the fakes of the other XXXtest packages.
+func WriteTrace(w io.Writer, kind string, n int, op func(i int) interface{}) error {
+	h, err := json.Marshal(kind)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "{\"version\":%d,\"kind\":%s,\"ops\":[\n", TraceVersion, h); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		b, err := json.Marshal(op(i))
+		if err != nil {
+			return err
+		}
+		if i != n-1 {
+			b = append(b, ',')
+		}
+		if _, err = fmt.Fprintf(w, "%s\n", b); err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+// ReadTrace reads a trace of the specified kind from r and decodes the I/O
+// into ops, which must be a pointer to a slice.
+//
+// It is meant to be used by the fakes of the other XXXtest packages.
+func ReadTrace(r io.Reader, kind string, ops interface{}) error {
+	var t struct {
+		Version int             `json:"version"`
+		Kind    string          `json:"kind"`
+		Ops     json.RawMessage `json:"ops"`
+	}
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return fmt.Errorf("conntest: invalid trace: %v", err)
+	}
+	if t.Version != TraceVersion {
+		return fmt.Errorf("conntest: unsupported trace version %d", t.Version)
+	}
+	if t.Kind != kind {
+		return fmt.Errorf("conntest: unexpected trace kind %q; expected %q", t.Kind, kind)
+	}
+	if len(t.Ops) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(t.Ops, ops); err != nil {
+		return fmt.Errorf("conntest: invalid trace: %v", err)
+	}
+	return nil
+}
+
+//
+
+type ioJSON struct {
+	W      Hex `json:"w,omitempty"`
+	R      Hex `json:"r,omitempty"`
+	WMask  Hex `json:"wmask,omitempty"`
+	Repeat int `json:"repeat,omitempty"`
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conn
+
+import "errors"
+
+// Kinds of failures that are common to all buses.
+//
+// Drivers return an *Error wrapping one of these so that callers can test
+// for the kind of failure with IsKind(), independently of the driver used.
+var (
+	// ErrNotAcknowledged is returned when the remote device didn't acknowledge
+	// its address or a byte, e.g. an I²C NACK or no 1-wire presence pulse.
+	ErrNotAcknowledged = errors.New("not acknowledged")
+	// ErrTimeout is returned when the bus or the remote device didn't complete
+	// the operation in time, e.g. excessive I²C clock stretching.
+	ErrTimeout = errors.New("timeout")
+	// ErrBusBusy is returned when the bus couldn't be acquired, e.g. it is used
+	// by another master or the arbitration was lost.
+	ErrBusBusy = errors.New("bus busy")
+	// ErrUnsupported is returned when the operation is not supported by the
+	// bus, the driver or the hardware.
+	ErrUnsupported = errors.New("unsupported")
+	// ErrInUse is returned when the device is used by another driver, e.g. an
+	// I²C address bound to a kernel driver. Unlike ErrBusBusy, it doesn't go
+	// away by retrying.
+	ErrInUse = errors.New("in use")
+)
+
+// Error is a bus error of a known kind.
+//
+// IsKind(err, Kind) returns true. With Go 1.13 and later, errors.Is(err,
+// Kind) also returns true and errors.Unwrap() returns the underlying error,
+// if any.
+type Error struct {
+	Kind error  // One of ErrNotAcknowledged, ErrTimeout, ErrBusBusy, ErrUnsupported or ErrInUse
+	Msg  string // Message, generally prefixed with the driver's name
+	Err  error  // Underlying error, can be nil
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Msg
+	}
+	return e.Msg + ": " + e.Err.Error()
+}
+
+// Is returns true if target is e.Kind.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsKind returns true if err is of the kind kind, one of ErrNotAcknowledged,
+// ErrTimeout, ErrBusBusy, ErrUnsupported or ErrInUse.
+//
+// err matches if it is kind itself or if its Is(error) bool method, like the
+// one of *Error, returns true for kind. Errors with an Unwrap() error method
+// are followed.
+func IsKind(err, kind error) bool {
+	for err != nil {
+		if err == kind {
+			return true
+		}
+		if i, ok := err.(interface{ Is(error) bool }); ok && i.Is(kind) {
+			return true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = u.Unwrap()
+	}
+	return false
+}
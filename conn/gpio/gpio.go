@@ -236,6 +236,62 @@ type PinDefaultPull interface {
 	DefaultPull() Pull
 }
 
+// EdgeEvent is an edge detected on an input pin.
+type EdgeEvent struct {
+	// T is the time at which the edge was detected. Its precision depends on
+	// the driver.
+	T time.Time
+	// L is the level of the pin after the edge: High for a rising edge and Low
+	// for a falling edge.
+	L Level
+	// Seq is the sequence number of the edge, starting at 1 when the delivery
+	// of the edges is started.
+	Seq uint64
+	// Dropped is the number of edges that were dropped right before this one
+	// because the receiver was not keeping up.
+	Dropped int
+}
+
+// PinEdges is optionally implemented by a PinIn that can deliver the detected
+// edges with their time and direction.
+//
+// Unlike WaitForEdge(), the edges are delivered by the driver in the
+// background, so the edges occurring while the receiver is busy are not lost
+// and multiple goroutines can wait on the same channel.
+type PinEdges interface {
+	// Edges starts delivering the edges detected on a channel with room for n
+	// events. Edge detection must have been enabled with In() first.
+	//
+	// When the channel is full, the edges are dropped and reported in the
+	// Dropped field of the next event delivered.
+	//
+	// The channel is closed when the delivery stops, which happens on the next
+	// call to Edges(), In(), Out() or Halt(). Do not call WaitForEdge() while
+	// the edges are delivered.
+	Edges(n int) (<-chan EdgeEvent, error)
+}
+
+// Group is a set of pins that are read or written together, for example to
+// drive a parallel bus.
+//
+// Bit i of a mask is the i-th pin of Pins(), so a group has at most 32 pins.
+//
+// The pins are not guaranteed to change at the same time. The documentation
+// of each implementation states the intermediate states the pins may go
+// through during Out().
+type Group interface {
+	// Pins returns the pins of the group.
+	Pins() []PinIO
+	// Out sets the pins selected by mask to the levels of the corresponding
+	// bits of v.
+	//
+	// Depending on the implementation, the pins may have to be set as output
+	// with PinOut.Out() first.
+	Out(v, mask uint32) error
+	// Read returns the levels of the pins selected by mask.
+	Read(mask uint32) uint32
+}
+
 // INVALID implements PinIO and fails on all access.
 var INVALID PinIO
 
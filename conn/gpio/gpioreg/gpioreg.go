@@ -10,8 +10,10 @@ import (
 	"sort"
 	"strconv"
 	"sync"
+	"time"
 
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/pin"
 )
 
 // ByName returns a GPIO pin from its name, gpio number or one of its aliases.
@@ -135,6 +137,120 @@ func Register(p gpio.PinIO, preferred bool) error {
 // number. It is valid to register the same alias to the same dest multiple
 // times.
 func RegisterAlias(alias string, dest string) error {
+	return registerAlias(alias, dest, false)
+}
+
+// RegisterActiveLowAlias registers an alias for a GPIO pin like
+// RegisterAlias, except that the levels of the alias are inverted.
+//
+// This is useful for a signal that is active when low, like a LED connected to
+// Vcc or a button pulling the pin to ground: Out(gpio.High) drives the pin
+// low, Read() returns High when the pin is low and RisingEdge detects the
+// falling edges of the pin. The levels of the events returned by Edges() are
+// inverted too. The pull resistor is not affected.
+func RegisterActiveLowAlias(alias string, dest string) error {
+	return registerAlias(alias, dest, true)
+}
+
+// Unregister removes a previously registered GPIO pin or alias from the
+// registry.
+//
+// This can happen when a GPIO pin is exposed via an USB device and the device
+// is unplugged. A pin is removed along its claim by Acquire(), unless another
+// registered pin with the same number shares the claim. The aliases to the
+// pin are kept and the ones already resolved keep pointing to it.
+func Unregister(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := byAlias[name]; ok {
+		delete(byAlias, name)
+		return nil
+	}
+	found := false
+	for i := range byName {
+		if p, ok := byName[i][name]; ok {
+			delete(byName[i], name)
+			delete(byNumber[i], p.Number())
+			if getByNumber(p.Number()) == nil {
+				delete(owners, p.Number())
+			}
+			found = true
+		}
+	}
+	if !found {
+		return wrapf("can't unregister unknown pin name %q", name)
+	}
+	return nil
+}
+
+// Acquire marks the pins as used by owner, for example a driver or a bus
+// name.
+//
+// It returns an error naming the current owner if any of the pins is already
+// used, in which case none of the pins is acquired. Aliases are resolved, so
+// on a Raspberry Pi acquiring "SPI0_CS0" then "GPIO8" fails. Two drivers
+// exposing the same pin number, like bcm283x and sysfs, share the claim.
+//
+// Only the pins registered with Register() are tracked; other pins, like
+// gpiotest.Pin, are accepted as is.
+//
+// Call Release() once the pins are not used anymore, normally in Halt() or
+// Close().
+func Acquire(owner string, pins ...pin.Pin) error {
+	if len(owner) == 0 {
+		return wrapf("can't acquire pins with no owner")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	numbers := make([]int, 0, len(pins))
+	for _, p := range pins {
+		n, ok := getNumber(p)
+		if !ok {
+			continue
+		}
+		if o, ok := owners[n]; ok {
+			return wrapf("can't acquire pin %s for %q; it is already used by %q", p, owner, o)
+		}
+		for _, m := range numbers {
+			if m == n {
+				return wrapf("can't acquire pin %s for %q twice", p, owner)
+			}
+		}
+		numbers = append(numbers, n)
+	}
+	for _, n := range numbers {
+		owners[n] = owner
+	}
+	return nil
+}
+
+// Release marks the pins as not used anymore.
+//
+// Releasing a pin that is not acquired is a no-op.
+func Release(pins ...pin.Pin) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range pins {
+		if n, ok := getNumber(p); ok {
+			delete(owners, n)
+		}
+	}
+}
+
+// Owner returns the owner of a pin as passed to Acquire(), or an empty string
+// if the pin is not used.
+func Owner(p pin.Pin) string {
+	mu.Lock()
+	defer mu.Unlock()
+	if n, ok := getNumber(p); ok {
+		return owners[n]
+	}
+	return ""
+}
+
+//
+
+func registerAlias(alias string, dest string, activeLow bool) error {
 	if len(alias) == 0 {
 		return wrapf("can't register an alias with no name")
 	}
@@ -148,19 +264,17 @@ func RegisterAlias(alias string, dest string) error {
 	mu.Lock()
 	defer mu.Unlock()
 	if orig := byAlias[alias]; orig != nil {
-		if orig.dest == dest {
+		if orig.dest == dest && orig.activeLow == activeLow {
 			// It is fine to register the same alias twice. This simplifies unit
 			// tests as there is no way to clear the registry (yet).
 			return nil
 		}
 		return wrapf("can't register alias %q twice; it is already an alias: %v", alias, orig)
 	}
-	byAlias[alias] = &pinAlias{name: alias, dest: dest}
+	byAlias[alias] = &pinAlias{name: alias, dest: dest, activeLow: activeLow}
 	return nil
 }
 
-//
-
 var (
 	mu sync.Mutex
 	// The first map is preferred pins, the second is for more limited pins,
@@ -168,6 +282,8 @@ var (
 	byNumber = [2]map[int]gpio.PinIO{{}, {}}
 	byName   = [2]map[string]gpio.PinIO{{}, {}}
 	byAlias  = map[string]*pinAlias{}
+	// owners is the owner of the acquired pins, by number.
+	owners = map[int]string{}
 )
 
 // pinAlias implements an alias for a PinIO.
@@ -176,8 +292,9 @@ var (
 // real pin under the alias.
 type pinAlias struct {
 	gpio.PinIO
-	name string
-	dest string
+	name      string
+	dest      string
+	activeLow bool // The levels are inverted
 }
 
 // String returns the alias name along the real pin's Name() in parenthesis, if
@@ -199,6 +316,67 @@ func (a *pinAlias) Real() gpio.PinIO {
 	return a.PinIO
 }
 
+// In implements gpio.PinIn.
+//
+// The edges are swapped when the alias is active low.
+func (a *pinAlias) In(pull gpio.Pull, edge gpio.Edge) error {
+	if a.activeLow && (edge == gpio.RisingEdge || edge == gpio.FallingEdge) {
+		edge ^= gpio.BothEdges
+	}
+	return a.PinIO.In(pull, edge)
+}
+
+// WaitForEdge implements gpio.PinIn.
+//
+// It waits for the edges selected with In(), which are already swapped when
+// the alias is active low, so it returns on the edges of the alias. It
+// doesn't report the level; Read() returns it inverted.
+func (a *pinAlias) WaitForEdge(timeout time.Duration) bool {
+	return a.PinIO.WaitForEdge(timeout)
+}
+
+// Edges implements gpio.PinEdges if the real pin does.
+//
+// The levels of the events are inverted when the alias is active low. Events
+// that can't be delivered because the channel is full are dropped and counted
+// in the Dropped field of the next event.
+func (a *pinAlias) Edges(n int) (<-chan gpio.EdgeEvent, error) {
+	e, ok := a.PinIO.(gpio.PinEdges)
+	if !ok {
+		return nil, wrapf("pin %s doesn't support edges delivery", a)
+	}
+	c, err := e.Edges(n)
+	if err != nil || !a.activeLow {
+		return c, err
+	}
+	out := make(chan gpio.EdgeEvent, n)
+	go func() {
+		defer close(out)
+		dropped := 0
+		for ev := range c {
+			ev.L = !ev.L
+			ev.Dropped += dropped
+			select {
+			case out <- ev:
+				dropped = 0
+			default:
+				dropped = ev.Dropped + 1
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Read implements gpio.PinIn.
+func (a *pinAlias) Read() gpio.Level {
+	return a.PinIO.Read() != gpio.Level(a.activeLow)
+}
+
+// Out implements gpio.PinOut.
+func (a *pinAlias) Out(l gpio.Level) error {
+	return a.PinIO.Out(l != gpio.Level(a.activeLow))
+}
+
 func getByNumber(number int) gpio.PinIO {
 	if p, ok := byNumber[0][number]; ok {
 		return p
@@ -209,6 +387,31 @@ func getByNumber(number int) gpio.PinIO {
 	return nil
 }
 
+// getNumber resolves the aliases and returns the number of the pin if it is
+// registered.
+func getNumber(p pin.Pin) (int, bool) {
+	for {
+		r, ok := p.(gpio.RealPin)
+		if !ok {
+			break
+		}
+		p = r.Real()
+	}
+	if p == nil {
+		return 0, false
+	}
+	n := p.Number()
+	if n < 0 {
+		return 0, false
+	}
+	for i := range byNumber {
+		if q, ok := byNumber[i][n]; ok && q.Name() == p.Name() {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
 // getByName recursively resolves the aliases to get the pin.
 func getByName(name string) gpio.PinIO {
 	if p, ok := byName[0][name]; ok {
@@ -245,3 +448,5 @@ func (p pinList) Less(i, j int) bool { return p[i].Number() < p[j].Number() }
 func init() {
 	Register(gpio.INVALID, true)
 }
+
+var _ gpio.PinEdges = &pinAlias{}
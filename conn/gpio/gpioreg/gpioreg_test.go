@@ -162,6 +162,94 @@ func TestRegisterAlias(t *testing.T) {
 	}
 }
 
+func TestRegisterActiveLowAlias(t *testing.T) {
+	defer reset()
+	p := &levelPin{basicPin: basicPin{PinIO: gpio.INVALID, name: "GPIO17", num: 17}}
+	if err := Register(p, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterActiveLowAlias("LED", "GPIO17"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterActiveLowAlias("LED", "GPIO17"); err != nil {
+		t.Fatal(err)
+	}
+	if RegisterAlias("LED", "GPIO17") == nil {
+		t.Fatal("can't register an alias with a different polarity")
+	}
+	a := ByName("LED")
+	if a == nil {
+		t.Fatal("LED doesn't resolve")
+	}
+	if err := a.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if p.l != gpio.Low || a.Read() != gpio.High {
+		t.Fatal("levels are not inverted")
+	}
+	if r := a.(gpio.RealPin).Real(); r != p {
+		t.Fatal(r)
+	}
+	if err := a.In(gpio.PullUp, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if p.edge != gpio.FallingEdge || p.pull != gpio.PullUp {
+		t.Fatal(p.edge, p.pull)
+	}
+	if err := a.In(gpio.PullUp, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	if p.edge != gpio.BothEdges {
+		t.Fatal(p.edge)
+	}
+}
+
+func TestRegisterActiveLowAlias_edges(t *testing.T) {
+	defer reset()
+	p := &edgesPin{levelPin: levelPin{basicPin: basicPin{PinIO: gpio.INVALID, name: "GPIO17", num: 17}}}
+	if err := Register(p, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterActiveLowAlias("LED", "GPIO17"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterAlias("BUTTON", "GPIO17"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ByName("BUTTON").(gpio.PinEdges).Edges(1); err != nil || p.n != 1 {
+		t.Fatal(err, p.n)
+	}
+	c, err := ByName("LED").(gpio.PinEdges).Edges(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.c <- gpio.EdgeEvent{L: gpio.High, Seq: 1}
+	if e := <-c; e.L != gpio.Low || e.Seq != 1 {
+		t.Fatal(e)
+	}
+	p.c <- gpio.EdgeEvent{L: gpio.Low, Seq: 2}
+	if e := <-c; e.L != gpio.High || e.Seq != 2 {
+		t.Fatal(e)
+	}
+	close(p.c)
+	if e, ok := <-c; ok {
+		t.Fatal(e)
+	}
+}
+
+func TestRegisterActiveLowAlias_edges_unsupported(t *testing.T) {
+	defer reset()
+	if err := Register(&levelPin{basicPin: basicPin{PinIO: gpio.INVALID, name: "GPIO17", num: 17}}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterActiveLowAlias("LED", "GPIO17"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ByName("LED").(gpio.PinEdges).Edges(1); err == nil {
+		t.Fatal("the real pin doesn't support edges")
+	}
+}
+
 func TestRegisterAlias_fail(t *testing.T) {
 	defer reset()
 	if err := RegisterAlias("", "Dest"); err == nil {
@@ -175,6 +263,136 @@ func TestRegisterAlias_fail(t *testing.T) {
 	}
 }
 
+func TestUnregister(t *testing.T) {
+	defer reset()
+	a := &basicPin{PinIO: gpio.INVALID, name: "a", num: 1}
+	if err := Register(a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterAlias("CS", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Acquire("spi", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unregister("CS"); err != nil {
+		t.Fatal(err)
+	}
+	if p := ByName("CS"); p != nil {
+		t.Fatal(p)
+	}
+	if s := Owner(a); s != "spi" {
+		t.Fatal(s)
+	}
+	// The same pin number exposed by the OS shares the claim.
+	if err := Register(&basicPin{PinIO: gpio.INVALID, name: "a", num: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unregister("a"); err != nil {
+		t.Fatal(err)
+	}
+	if p := ByName("1"); p != nil {
+		t.Fatal(p)
+	}
+	if err := Register(a, true); err != nil {
+		t.Fatal(err)
+	}
+	if s := Owner(a); s != "" {
+		t.Fatal(s)
+	}
+	if err := Acquire("spi", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unregister("a"); err != nil {
+		t.Fatal(err)
+	}
+	if p := ByName("a"); p != nil {
+		t.Fatal(p)
+	}
+	if p := ByName("1"); p != nil {
+		t.Fatal(p)
+	}
+	if err := Unregister("a"); err == nil {
+		t.Fatal("unknown pin")
+	}
+	// It can be registered and acquired again.
+	if err := Register(a, true); err != nil {
+		t.Fatal(err)
+	}
+	if s := Owner(a); s != "" {
+		t.Fatal(s)
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	defer reset()
+	a := &basicPin{PinIO: gpio.INVALID, name: "a", num: 1}
+	b := &basicPin{PinIO: gpio.INVALID, name: "b", num: 2}
+	if err := Register(a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(b, true); err != nil {
+		t.Fatal(err)
+	}
+	// The same pin number exposed by the OS.
+	if err := Register(&basicPin{PinIO: gpio.INVALID, name: "a", num: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterAlias("CS", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Acquire("spi", ByName("CS")); err != nil {
+		t.Fatal(err)
+	}
+	if s := Owner(a); s != "spi" {
+		t.Fatal(s)
+	}
+	if s := Owner(b); s != "" {
+		t.Fatal(s)
+	}
+	err := Acquire("tm1637", b, a)
+	if err == nil || err.Error() != `gpioreg: can't acquire pin a for "tm1637"; it is already used by "spi"` {
+		t.Fatal(err)
+	}
+	// Nothing was acquired.
+	if s := Owner(b); s != "" {
+		t.Fatal(s)
+	}
+	if err := Acquire("tm1637", byNumber[1][1]); err == nil {
+		t.Fatal("same pin number")
+	}
+	Release(a)
+	if err := Acquire("tm1637", b, a); err != nil {
+		t.Fatal(err)
+	}
+	Release(a, b)
+	// Unregistered pins are not tracked.
+	c := &basicPin{PinIO: gpio.INVALID, name: "c", num: 3}
+	if err := Acquire("tm1637", c, c, gpio.INVALID); err != nil {
+		t.Fatal(err)
+	}
+	if s := Owner(c); s != "" {
+		t.Fatal(s)
+	}
+}
+
+func TestAcquire_fail(t *testing.T) {
+	defer reset()
+	a := &basicPin{PinIO: gpio.INVALID, name: "a", num: 1}
+	if err := Register(a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Acquire("", a); err == nil {
+		t.Fatal("no owner")
+	}
+	if err := Acquire("spi", a, a); err == nil {
+		t.Fatal("same pin twice")
+	}
+	if s := Owner(a); s != "" {
+		t.Fatal(s)
+	}
+}
+
 func TestPinList(t *testing.T) {
 	l := pinList{&basicPin{PinIO: gpio.INVALID, num: 1}, &basicPin{PinIO: gpio.INVALID}}
 	sort.Sort(l)
@@ -204,10 +422,46 @@ func (b *basicPin) Number() int {
 	return b.num
 }
 
+// levelPin records the level and the edge set on a pin.
+type levelPin struct {
+	basicPin
+	l    gpio.Level
+	pull gpio.Pull
+	edge gpio.Edge
+}
+
+func (l *levelPin) In(pull gpio.Pull, edge gpio.Edge) error {
+	l.pull = pull
+	l.edge = edge
+	return nil
+}
+
+func (l *levelPin) Read() gpio.Level {
+	return l.l
+}
+
+func (l *levelPin) Out(level gpio.Level) error {
+	l.l = level
+	return nil
+}
+
+type edgesPin struct {
+	levelPin
+	n int
+	c chan gpio.EdgeEvent
+}
+
+func (e *edgesPin) Edges(n int) (<-chan gpio.EdgeEvent, error) {
+	e.n = n
+	e.c = make(chan gpio.EdgeEvent, 2)
+	return e.c, nil
+}
+
 func reset() {
 	mu.Lock()
 	defer mu.Unlock()
 	byNumber = [2]map[int]gpio.PinIO{{}, {}}
 	byName = [2]map[string]gpio.PinIO{{}, {}}
 	byAlias = map[string]*pinAlias{}
+	owners = map[int]string{}
 }
@@ -0,0 +1,349 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpiostream
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// ToEdgeStream converts a finite stream into an EdgeStream.
+//
+// The conversion is lossless. Consecutive bits of the same level are merged
+// into a single edge. Res is the resolution of the stream.
+func ToEdgeStream(s Stream) (*EdgeStream, error) {
+	r, err := appendRuns(nil, s)
+	if err != nil {
+		return nil, err
+	}
+	return runsToEdges(r, s.Resolution()), nil
+}
+
+// ToBitStreamLSB rasterizes a finite stream at resolution res.
+//
+// The level changes are rounded to the closest multiple of res. The last byte
+// is padded with the last level.
+func ToBitStreamLSB(s Stream, res time.Duration) (*BitStreamLSB, error) {
+	b, err := rasterize(s, res, false)
+	if err != nil {
+		return nil, err
+	}
+	return &BitStreamLSB{Bits: b, Res: res}, nil
+}
+
+// ToBitStreamMSB rasterizes a finite stream at resolution res.
+//
+// The level changes are rounded to the closest multiple of res. The last byte
+// is padded with the last level.
+func ToBitStreamMSB(s Stream, res time.Duration) (*BitStreamMSB, error) {
+	b, err := rasterize(s, res, true)
+	if err != nil {
+		return nil, err
+	}
+	return &BitStreamMSB{Bits: b, Res: res}, nil
+}
+
+// ToBitStream rasterizes a finite stream at resolution res.
+//
+// The level changes are rounded to the closest multiple of res. The last byte
+// is padded with the last level.
+func ToBitStream(s Stream, res time.Duration) (*BitStream, error) {
+	b, err := rasterize(s, res, false)
+	if err != nil {
+		return nil, err
+	}
+	return &BitStream{Bits: b, Res: res}, nil
+}
+
+// Resample returns a copy of the stream at resolution res.
+//
+// The type of the stream is preserved. The level changes are rounded to the
+// closest multiple of res. The parts of a Program are resampled individually,
+// so an infinite Program can be resampled.
+func Resample(s Stream, res time.Duration) (Stream, error) {
+	if res <= 0 {
+		return nil, errors.New("gpiostream: invalid resolution")
+	}
+	switch t := s.(type) {
+	case *BitStreamLSB:
+		return ToBitStreamLSB(t, res)
+	case *BitStreamMSB:
+		return ToBitStreamMSB(t, res)
+	case *BitStream:
+		return ToBitStream(t, res)
+	case *EdgeStream:
+		r, err := appendRuns(nil, t)
+		if err != nil {
+			return nil, err
+		}
+		return runsToEdges(quantize(r, res), res), nil
+	case *Program:
+		o := &Program{Parts: make([]Stream, 0, len(t.Parts)), Loops: t.Loops}
+		for _, p := range t.Parts {
+			x, err := Resample(p, res)
+			if err != nil {
+				return nil, err
+			}
+			o.Parts = append(o.Parts, x)
+		}
+		return o, nil
+	default:
+		return nil, fmt.Errorf("gpiostream: unsupported stream %T", s)
+	}
+}
+
+// Flatten expands the loops of a finite Program into a single stream.
+//
+// If all the bit streams in the Program are of the same type and resolution,
+// they are concatenated into a stream of this type. Otherwise the Program is
+// converted into an EdgeStream. Any other stream is returned as is.
+func Flatten(s Stream) (Stream, error) {
+	p, ok := s.(*Program)
+	if !ok {
+		return s, nil
+	}
+	var leaves []Stream
+	if err := appendLeaves(&leaves, p); err != nil {
+		return nil, err
+	}
+	if b := concatBits(leaves); b != nil {
+		return b, nil
+	}
+	return ToEdgeStream(p)
+}
+
+// Equal returns true if the two finite streams describe the same waveform,
+// with every level change within tolerance.
+//
+// Streams that cannot be converted are never equal.
+func Equal(a, b Stream, tolerance time.Duration) bool {
+	ra, err := appendRuns(nil, a)
+	if err != nil {
+		return false
+	}
+	rb, err := appendRuns(nil, b)
+	if err != nil {
+		return false
+	}
+	if len(ra) != len(rb) {
+		return false
+	}
+	var ta, tb time.Duration
+	for i := range ra {
+		if ra[i].l != rb[i].l {
+			return false
+		}
+		ta += ra[i].d
+		tb += rb[i].d
+		if d := ta - tb; d > tolerance || -d > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+//
+
+// run is a level held for a duration.
+type run struct {
+	l gpio.Level
+	d time.Duration
+}
+
+// appendRuns appends the levels of a finite stream to r.
+//
+// Consecutive runs of the same level are merged and empty runs are skipped.
+func appendRuns(r []run, s Stream) ([]run, error) {
+	switch t := s.(type) {
+	case *BitStreamLSB:
+		return appendBits(r, t.Bits, t.Res, false)
+	case *BitStreamMSB:
+		return appendBits(r, t.Bits, t.Res, true)
+	case *BitStream:
+		return appendBits(r, t.Bits, t.Res, false)
+	case *EdgeStream:
+		l := gpio.High
+		for _, e := range t.Edges {
+			if e < 0 {
+				return nil, errors.New("gpiostream: invalid edge duration")
+			}
+			r = appendRun(r, l, e)
+			l = !l
+		}
+		return r, nil
+	case *Program:
+		if t.Loops < 0 {
+			return nil, errors.New("gpiostream: infinite Program is not supported")
+		}
+		for i := 0; i < t.Loops; i++ {
+			for _, p := range t.Parts {
+				var err error
+				if r, err = appendRuns(r, p); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("gpiostream: unsupported stream %T", s)
+	}
+}
+
+func appendBits(r []run, b []byte, res time.Duration, msb bool) ([]run, error) {
+	if len(b) != 0 && res <= 0 {
+		return nil, errors.New("gpiostream: invalid resolution")
+	}
+	for _, v := range b {
+		for j := uint(0); j < 8; j++ {
+			if msb {
+				r = appendRun(r, v&(0x80>>j) != 0, res)
+			} else {
+				r = appendRun(r, v&(1<<j) != 0, res)
+			}
+		}
+	}
+	return r, nil
+}
+
+func appendRun(r []run, l gpio.Level, d time.Duration) []run {
+	if d == 0 {
+		return r
+	}
+	if i := len(r) - 1; i >= 0 && r[i].l == l {
+		r[i].d += d
+		return r
+	}
+	return append(r, run{l, d})
+}
+
+// quantize rounds the level changes to the closest multiple of res, relative
+// to the start of the stream.
+func quantize(r []run, res time.Duration) []run {
+	var out []run
+	var t, start time.Duration
+	for _, x := range r {
+		t += x.d
+		end := (t + res/2) / res * res
+		out = appendRun(out, x.l, end-start)
+		start = end
+	}
+	return out
+}
+
+func runsToEdges(r []run, res time.Duration) *EdgeStream {
+	e := &EdgeStream{Res: res}
+	if len(r) != 0 && !r[0].l {
+		e.Edges = append(e.Edges, 0)
+	}
+	for _, x := range r {
+		e.Edges = append(e.Edges, x.d)
+	}
+	return e
+}
+
+// rasterize converts a finite stream into bits at resolution res.
+//
+// The last byte is padded with the last level.
+func rasterize(s Stream, res time.Duration, msb bool) ([]byte, error) {
+	if res <= 0 {
+		return nil, errors.New("gpiostream: invalid resolution")
+	}
+	r, err := appendRuns(nil, s)
+	if err != nil {
+		return nil, err
+	}
+	r = quantize(r, res)
+	n := 0
+	for _, x := range r {
+		n += int(x.d / res)
+	}
+	b := make([]byte, (n+7)/8)
+	i := 0
+	l := gpio.Low
+	set := func() {
+		if l {
+			if msb {
+				b[i/8] |= 0x80 >> uint(i%8)
+			} else {
+				b[i/8] |= 1 << uint(i%8)
+			}
+		}
+		i++
+	}
+	for _, x := range r {
+		l = x.l
+		for j := x.d / res; j > 0; j-- {
+			set()
+		}
+	}
+	for i < 8*len(b) {
+		set()
+	}
+	return b, nil
+}
+
+// appendLeaves appends the streams of a finite Program, with its loops
+// expanded.
+func appendLeaves(leaves *[]Stream, p *Program) error {
+	if p.Loops < 0 {
+		return errors.New("gpiostream: infinite Program is not supported")
+	}
+	for i := 0; i < p.Loops; i++ {
+		for _, s := range p.Parts {
+			if c, ok := s.(*Program); ok {
+				if err := appendLeaves(leaves, c); err != nil {
+					return err
+				}
+				continue
+			}
+			*leaves = append(*leaves, s)
+		}
+	}
+	return nil
+}
+
+// concatBits concatenates bit streams of the same type and resolution.
+//
+// Returns nil if the streams can't be concatenated.
+func concatBits(leaves []Stream) Stream {
+	if len(leaves) == 0 {
+		return nil
+	}
+	var bits []byte
+	switch t := leaves[0].(type) {
+	case *BitStreamLSB:
+		for _, s := range leaves {
+			b, ok := s.(*BitStreamLSB)
+			if !ok || b.Res != t.Res {
+				return nil
+			}
+			bits = append(bits, b.Bits...)
+		}
+		return &BitStreamLSB{Bits: bits, Res: t.Res}
+	case *BitStreamMSB:
+		for _, s := range leaves {
+			b, ok := s.(*BitStreamMSB)
+			if !ok || b.Res != t.Res {
+				return nil
+			}
+			bits = append(bits, b.Bits...)
+		}
+		return &BitStreamMSB{Bits: bits, Res: t.Res}
+	case *BitStream:
+		for _, s := range leaves {
+			b, ok := s.(*BitStream)
+			if !ok || b.Res != t.Res {
+				return nil
+			}
+			bits = append(bits, b.Bits...)
+		}
+		return &BitStream{Bits: bits, Res: t.Res}
+	default:
+		return nil
+	}
+}
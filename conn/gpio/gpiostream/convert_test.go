@@ -0,0 +1,227 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpiostream
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestToEdgeStream(t *testing.T) {
+	const res = time.Microsecond
+	e, err := ToEdgeStream(&BitStreamMSB{Bits: BitsMSB{0x3C}, Res: res})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// It starts with Low.
+	expected := &EdgeStream{Edges: []time.Duration{0, 2 * res, 4 * res, 2 * res}, Res: res}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatal(e)
+	}
+	p := &Program{
+		Parts: []Stream{
+			&BitStreamLSB{Bits: BitsLSB{0xFF}, Res: res},
+			&EdgeStream{Edges: []time.Duration{res, res}, Res: res},
+		},
+		Loops: 2,
+	}
+	if e, err = ToEdgeStream(p); err != nil {
+		t.Fatal(err)
+	}
+	expected = &EdgeStream{Edges: []time.Duration{9 * res, res, 9 * res, res}, Res: res / 2}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatal(e)
+	}
+}
+
+func TestToEdgeStream_fail(t *testing.T) {
+	data := []Stream{
+		&BitStreamLSB{Bits: BitsLSB{1}},
+		&BitStreamMSB{Bits: BitsMSB{1}},
+		&BitStream{Bits: Bits{1}},
+		&EdgeStream{Edges: []time.Duration{-1}},
+		&Program{Parts: []Stream{&EdgeStream{Edges: []time.Duration{-1}}}, Loops: 1},
+		&Program{Parts: []Stream{&EdgeStream{}}, Loops: -1},
+		&fakeStream{},
+	}
+	for i, d := range data {
+		if _, err := ToEdgeStream(d); err == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+	}
+}
+
+func TestToBitStream(t *testing.T) {
+	const res = time.Microsecond
+	e := &EdgeStream{Edges: []time.Duration{0, 2 * res, 4 * res, 2 * res}, Res: res}
+	msb, err := ToBitStreamMSB(e, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(msb, &BitStreamMSB{Bits: BitsMSB{0x3C}, Res: res}) {
+		t.Fatal(msb)
+	}
+	lsb, err := ToBitStreamLSB(msb, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lsb, &BitStreamLSB{Bits: BitsLSB{0x3C}, Res: res}) {
+		t.Fatal(lsb)
+	}
+	// The last byte is padded with the last level and the level changes are
+	// rounded.
+	e = &EdgeStream{Edges: []time.Duration{1400 * time.Nanosecond, 1600 * time.Nanosecond, 7 * res}, Res: res}
+	b, err := ToBitStream(e, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b, &BitStream{Bits: Bits{0xF9, 0xFF}, Res: res}) {
+		t.Fatal(b)
+	}
+	if _, err := ToBitStream(e, 0); err == nil {
+		t.Fatal("invalid resolution")
+	}
+	if _, err := ToBitStreamLSB(&fakeStream{}, res); err == nil {
+		t.Fatal("unsupported stream")
+	}
+	if _, err := ToBitStreamMSB(&fakeStream{}, res); err == nil {
+		t.Fatal("unsupported stream")
+	}
+}
+
+func TestResample(t *testing.T) {
+	const res = time.Microsecond
+	s, err := Resample(&BitStreamLSB{Bits: BitsLSB{0x0F}, Res: res}, 2*res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, &BitStreamLSB{Bits: BitsLSB{0x03}, Res: 2 * res}) {
+		t.Fatal(s)
+	}
+	e := &EdgeStream{Edges: []time.Duration{1400 * time.Nanosecond, 1600 * time.Nanosecond}, Res: 100 * time.Nanosecond}
+	p := &Program{Parts: []Stream{e, &BitStream{Bits: Bits{0xFF}, Res: res / 2}}, Loops: -1}
+	if s, err = Resample(p, res); err != nil {
+		t.Fatal(err)
+	}
+	expected := &Program{
+		Parts: []Stream{
+			&EdgeStream{Edges: []time.Duration{res, 2 * res}, Res: res},
+			&BitStream{Bits: Bits{0xFF}, Res: res},
+		},
+		Loops: -1,
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatal(s)
+	}
+	if _, err := Resample(p, 0); err == nil {
+		t.Fatal("invalid resolution")
+	}
+	data := []Stream{
+		&BitStreamMSB{Bits: BitsMSB{1}},
+		&EdgeStream{Edges: []time.Duration{-1}},
+		&Program{Parts: []Stream{&fakeStream{}}, Loops: 1},
+	}
+	for i, d := range data {
+		if _, err := Resample(d, res); err == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	const res = time.Microsecond
+	e := &EdgeStream{Edges: []time.Duration{res}, Res: res}
+	if s, err := Flatten(e); s != e || err != nil {
+		t.Fatal(s, err)
+	}
+	data := []struct {
+		parts    []Stream
+		expected Stream
+	}{
+		{
+			[]Stream{&BitStreamLSB{Bits: BitsLSB{1}, Res: res}, &BitStreamLSB{Bits: BitsLSB{2}, Res: res}},
+			&BitStreamLSB{Bits: BitsLSB{1, 2, 1, 2}, Res: res},
+		},
+		{
+			[]Stream{&BitStreamMSB{Bits: BitsMSB{1}, Res: res}, &BitStreamMSB{Bits: BitsMSB{2}, Res: res}},
+			&BitStreamMSB{Bits: BitsMSB{1, 2, 1, 2}, Res: res},
+		},
+		{
+			[]Stream{&BitStream{Bits: Bits{1}, Res: res}, &BitStream{Bits: Bits{2}, Res: res}},
+			&BitStream{Bits: Bits{1, 2, 1, 2}, Res: res},
+		},
+	}
+	for i, d := range data {
+		// Nested Programs are expanded too.
+		p := &Program{Parts: []Stream{&Program{Parts: d.parts[:1], Loops: 1}, d.parts[1]}, Loops: 2}
+		s, err := Flatten(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(s, d.expected) {
+			t.Fatalf("#%d: %#v", i, s)
+		}
+	}
+
+	// Mixed types are converted into an EdgeStream.
+	lsb := &BitStreamLSB{Bits: BitsLSB{1}, Res: res}
+	p := &Program{Parts: []Stream{lsb, e}, Loops: 2}
+	s, err := Flatten(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, &EdgeStream{Edges: []time.Duration{res, 7 * res, 2 * res, 7 * res, res}, Res: res / 2}) {
+		t.Fatal(s)
+	}
+	// Different resolutions are converted into an EdgeStream.
+	p = &Program{Parts: []Stream{lsb, &BitStreamLSB{Bits: BitsLSB{1}, Res: 2 * res}}, Loops: 1}
+	if s, err = Flatten(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*EdgeStream); !ok {
+		t.Fatal(s)
+	}
+	if _, err := Flatten(&Program{Parts: []Stream{&Program{Loops: -1}}, Loops: 1}); err == nil {
+		t.Fatal("infinite Program")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	const res = time.Microsecond
+	a := &BitStreamMSB{Bits: BitsMSB{0x38}, Res: res}
+	b := &EdgeStream{Edges: []time.Duration{0, 2 * res, 3 * res, 3 * res}, Res: res}
+	if !Equal(a, b, 0) {
+		t.Fatal("same waveform")
+	}
+	c := &EdgeStream{Edges: []time.Duration{0, 2100 * time.Nanosecond, 2900 * time.Nanosecond, 3 * res}, Res: res}
+	if Equal(a, c, 0) {
+		t.Fatal("out of tolerance")
+	}
+	if !Equal(a, c, 100*time.Nanosecond) {
+		t.Fatal("within tolerance")
+	}
+	if Equal(a, &BitStreamLSB{Bits: BitsLSB{0x38}, Res: res}, 0) {
+		t.Fatal("different order")
+	}
+	if Equal(a, &BitStreamMSB{Bits: BitsMSB{0xC7}, Res: res}, res) {
+		t.Fatal("inverted")
+	}
+	if Equal(a, &fakeStream{}, 0) || Equal(&fakeStream{}, a, 0) {
+		t.Fatal("unsupported stream")
+	}
+}
+
+//
+
+type fakeStream struct{}
+
+func (f *fakeStream) Resolution() time.Duration {
+	return 0
+}
+
+func (f *fakeStream) Duration() time.Duration {
+	return 0
+}
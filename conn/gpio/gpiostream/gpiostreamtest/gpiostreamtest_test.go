@@ -5,7 +5,9 @@
 package gpiostreamtest
 
 import (
+	"bytes"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -203,6 +205,17 @@ func TestPinOutPlayback(t *testing.T) {
 	}
 }
 
+func TestPinOutPlayback_equivalent(t *testing.T) {
+	p := &PinOutPlayback{N: "Yo", Ops: []gpiostream.Stream{&gpiostream.BitStreamMSB{Res: time.Second, Bits: gpiostream.BitsMSB{0xCC}}}}
+	e := &gpiostream.EdgeStream{Res: time.Second, Edges: []time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second, 2 * time.Second}}
+	if err := p.StreamOut(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestPinOutPlayback_fail(t *testing.T) {
 	p := &PinOutPlayback{DontPanic: true}
 	if p.StreamOut(&gpiostream.BitStream{Res: time.Second, Bits: gpiostream.Bits{0xCC}}) == nil {
@@ -225,6 +238,7 @@ func TestPinOutRecord(t *testing.T) {
 	data := []gpiostream.Stream{
 		&gpiostream.BitStreamLSB{Res: time.Second, Bits: gpiostream.BitsLSB{0xCC}},
 		&gpiostream.BitStreamMSB{Res: time.Second, Bits: gpiostream.BitsMSB{0xCC}},
+		&gpiostream.BitStream{Res: time.Second, Bits: gpiostream.Bits{0xCC}},
 		&gpiostream.EdgeStream{Res: time.Second, Edges: []time.Duration{time.Minute, 2 * time.Minute}},
 		&gpiostream.Program{Parts: []gpiostream.Stream{&gpiostream.BitStreamLSB{Res: time.Second, Bits: gpiostream.BitsLSB{0xCC}}}, Loops: 2},
 	}
@@ -243,6 +257,45 @@ func TestPinOutRecord(t *testing.T) {
 	}
 }
 
+func TestPinOutRecord_WriteVCD(t *testing.T) {
+	p := &PinOutRecord{N: "Yo"}
+	if err := p.StreamOut(&gpiostream.BitStreamMSB{Res: time.Second, Bits: gpiostream.BitsMSB{0xF0}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.StreamOut(&gpiostream.EdgeStream{Res: time.Second, Edges: []time.Duration{time.Second}}); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := p.WriteVCD(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := "$version periph.io/x/periph $end\n" +
+		"$timescale 1 s $end\n" +
+		"$scope module periph $end\n" +
+		"$var wire 1 ! Yo $end\n" +
+		"$upscope $end\n" +
+		"$enddefinitions $end\n" +
+		"#0\n$dumpvars\n1!\n$end\n" +
+		"#4\n0!\n" +
+		"#8\n1!\n" +
+		"#9\n"
+	if s := b.String(); s != expected {
+		t.Fatal(s)
+	}
+	p.N = ""
+	b.Reset()
+	if err := p.WriteVCD(&b); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); !strings.Contains(s, "$var wire 1 ! pin $end\n") {
+		t.Fatal(s)
+	}
+	p.N = "a b"
+	if p.WriteVCD(&b) == nil {
+		t.Fatal("invalid signal name")
+	}
+}
+
 func TestPinOutRecord_fail(t *testing.T) {
 	p := &PinOutRecord{DontPanic: true}
 	if p.StreamOut(nil) == nil {
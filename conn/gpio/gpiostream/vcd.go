@@ -0,0 +1,310 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpiostream
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// Signal is a named stream, as stored in a VCD file.
+type Signal struct {
+	Name   string
+	Stream Stream
+}
+
+// WriteVCD encodes the signals in the Value Change Dump format, as read by
+// GTKWave and PulseView.
+//
+// The timescale is the largest one supported by the format that divides the
+// resolution of all the streams, including the parts of Programs, so the
+// resolution is preserved when it is 1, 10 or 100 times a second,
+// millisecond, microsecond or nanosecond. The level changes are rounded to the
+// timescale.
+//
+// Infinite Programs are not supported.
+func WriteVCD(w io.Writer, signals ...Signal) error {
+	ts, unit := vcdTimescale(signals)
+	type change struct {
+		t time.Duration
+		i int
+		l gpio.Level
+	}
+	var changes []change
+	var end time.Duration
+	for i, s := range signals {
+		if len(s.Name) == 0 || strings.IndexFunc(s.Name, isSpace) != -1 {
+			return fmt.Errorf("gpiostream: invalid signal name %q", s.Name)
+		}
+		r, err := appendRuns(nil, s.Stream)
+		if err != nil {
+			return err
+		}
+		// A signal without any level starts Low.
+		changes = append(changes, change{0, i, gpio.Low})
+		var t time.Duration
+		for j, x := range quantize(r, ts) {
+			if j == 0 {
+				changes[len(changes)-1].l = x.l
+			} else {
+				changes = append(changes, change{t, i, x.l})
+			}
+			t += x.d
+		}
+		if t > end {
+			end = t
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].t < changes[j].t })
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "$version periph.io/x/periph $end\n")
+	fmt.Fprintf(b, "$timescale %d %s $end\n", ts/unit, vcdUnits[unit])
+	fmt.Fprintf(b, "$scope module periph $end\n")
+	for i, s := range signals {
+		fmt.Fprintf(b, "$var wire 1 %s %s $end\n", vcdID(i), s.Name)
+	}
+	fmt.Fprintf(b, "$upscope $end\n")
+	fmt.Fprintf(b, "$enddefinitions $end\n")
+	// All the signals have their initial value at 0.
+	fmt.Fprintf(b, "#0\n$dumpvars\n")
+	last := time.Duration(0)
+	for _, c := range changes {
+		if c.t != last {
+			if last == 0 {
+				fmt.Fprintf(b, "$end\n")
+			}
+			fmt.Fprintf(b, "#%d\n", c.t/ts)
+			last = c.t
+		}
+		fmt.Fprintf(b, "%c%s\n", vcdValue(c.l), vcdID(c.i))
+	}
+	if last == 0 {
+		fmt.Fprintf(b, "$end\n")
+	}
+	if end > last {
+		fmt.Fprintf(b, "#%d\n", end/ts)
+	}
+	return b.Flush()
+}
+
+// ReadVCD decodes the single bit signals of a Value Change Dump.
+//
+// Each signal is returned as an EdgeStream whose resolution is the timescale
+// of the dump. Use ToBitStream() to convert it to a BitStream. The signals
+// all last until the last timestamp of the dump. Unknown and high impedance
+// values are decoded as Low. Vectors and reals are ignored.
+func ReadVCD(r io.Reader) ([]Signal, error) {
+	s := bufio.NewScanner(r)
+	s.Split(bufio.ScanWords)
+	var ts time.Duration
+	var names []string
+	ids := map[string]int{}
+	// Skip the declarations.
+	for done := false; !done; {
+		if !s.Scan() {
+			return nil, vcdErr(s, "missing $enddefinitions")
+		}
+		cmd := s.Text()
+		args, err := vcdCommand(s)
+		if err != nil {
+			return nil, err
+		}
+		switch cmd {
+		case "$timescale":
+			if ts, err = parseTimescale(strings.Join(args, "")); err != nil {
+				return nil, err
+			}
+		case "$var":
+			if len(args) < 4 {
+				return nil, fmt.Errorf("gpiostream: invalid $var %q", strings.Join(args, " "))
+			}
+			if args[1] != "1" {
+				continue
+			}
+			if _, ok := ids[args[2]]; !ok {
+				ids[args[2]] = len(names)
+				names = append(names, args[3])
+			}
+		case "$enddefinitions":
+			done = true
+		default:
+			if !strings.HasPrefix(cmd, "$") {
+				return nil, fmt.Errorf("gpiostream: unexpected %q in the declarations", cmd)
+			}
+		}
+	}
+	if ts == 0 {
+		return nil, errors.New("gpiostream: missing $timescale")
+	}
+
+	levels := make([]gpio.Level, len(names))
+	changes := make([]time.Duration, len(names))
+	runs := make([][]run, len(names))
+	var t time.Duration
+	for s.Scan() {
+		w := s.Text()
+		switch w[0] {
+		case '#':
+			v, err := strconv.ParseInt(w[1:], 10, 64)
+			if err != nil || time.Duration(v)*ts < t {
+				return nil, fmt.Errorf("gpiostream: invalid timestamp %q", w)
+			}
+			t = time.Duration(v) * ts
+		case '0', '1', 'x', 'X', 'z', 'Z':
+			i, ok := ids[w[1:]]
+			if !ok {
+				continue
+			}
+			l := gpio.Level(w[0] == '1')
+			if l != levels[i] {
+				runs[i] = appendRun(runs[i], levels[i], t-changes[i])
+				levels[i] = l
+				changes[i] = t
+			}
+		case 'b', 'B', 'r', 'R':
+			// Skip the identifier of the vector.
+			s.Scan()
+		case '$':
+			// $dumpvars, $dumpall, $dumpon, $dumpoff and $end only delimit value
+			// changes.
+			if w == "$comment" {
+				if _, err := vcdCommand(s); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("gpiostream: unexpected %q", w)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]Signal, len(names))
+	for i := range names {
+		runs[i] = appendRun(runs[i], levels[i], t-changes[i])
+		out[i] = Signal{Name: names[i], Stream: runsToEdges(runs[i], ts)}
+	}
+	return out, nil
+}
+
+//
+
+var vcdUnits = map[time.Duration]string{
+	time.Second:      "s",
+	time.Millisecond: "ms",
+	time.Microsecond: "us",
+	time.Nanosecond:  "ns",
+}
+
+// vcdTimescale returns the largest timescale that divides the resolution of
+// all the streams and its unit.
+func vcdTimescale(signals []Signal) (time.Duration, time.Duration) {
+	for _, unit := range []time.Duration{time.Second, time.Millisecond, time.Microsecond, time.Nanosecond} {
+		for _, m := range []time.Duration{100, 10, 1} {
+			ok := true
+			for _, s := range signals {
+				if !resDivisible(s.Stream, m*unit) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				return m * unit, unit
+			}
+		}
+	}
+	return time.Nanosecond, time.Nanosecond
+}
+
+// resDivisible returns true if the resolution of s is a multiple of ts.
+//
+// The parts of a Program are checked individually, as the resolution of a
+// Program is lowered for its sampling.
+func resDivisible(s Stream, ts time.Duration) bool {
+	if p, ok := s.(*Program); ok {
+		for _, x := range p.Parts {
+			if !resDivisible(x, ts) {
+				return false
+			}
+		}
+		return true
+	}
+	// Empty streams have no resolution.
+	return s.Resolution()%ts == 0
+}
+
+// parseTimescale parses a timescale like "10us".
+func parseTimescale(s string) (time.Duration, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i > 0 {
+		m, err := strconv.Atoi(s[:i])
+		if err == nil && (m == 1 || m == 10 || m == 100) {
+			for unit, name := range vcdUnits {
+				if name == s[i:] {
+					return time.Duration(m) * unit, nil
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("gpiostream: unsupported timescale %q", s)
+}
+
+// vcdID returns the short identifier of the signal i.
+//
+// The identifiers use the printable ASCII characters from '!' to '~'.
+func vcdID(i int) string {
+	var b []byte
+	for {
+		b = append(b, byte('!'+i%94))
+		i /= 94
+		if i == 0 {
+			return string(b)
+		}
+		i--
+	}
+}
+
+func vcdValue(l gpio.Level) byte {
+	if l {
+		return '1'
+	}
+	return '0'
+}
+
+// vcdCommand returns the arguments of the command just scanned, up to $end.
+func vcdCommand(s *bufio.Scanner) ([]string, error) {
+	cmd := s.Text()
+	if cmd == "$end" || !strings.HasPrefix(cmd, "$") {
+		return nil, nil
+	}
+	var args []string
+	for s.Scan() {
+		if s.Text() == "$end" {
+			return args, nil
+		}
+		args = append(args, s.Text())
+	}
+	return nil, vcdErr(s, "missing $end for "+cmd)
+}
+
+func vcdErr(s *bufio.Scanner, msg string) error {
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return errors.New("gpiostream: " + msg)
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpiostream
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteVCD(t *testing.T) {
+	const res = time.Microsecond
+	signals := []Signal{
+		{"GPIO1", &BitStreamMSB{Bits: BitsMSB{0xC2}, Res: res}},
+		{"GPIO2", &EdgeStream{Edges: []time.Duration{0, 3 * res, 2 * res}, Res: res}},
+	}
+	var b bytes.Buffer
+	if err := WriteVCD(&b, signals...); err != nil {
+		t.Fatal(err)
+	}
+	expected := "$version periph.io/x/periph $end\n" +
+		"$timescale 1 us $end\n" +
+		"$scope module periph $end\n" +
+		"$var wire 1 ! GPIO1 $end\n" +
+		"$var wire 1 \" GPIO2 $end\n" +
+		"$upscope $end\n" +
+		"$enddefinitions $end\n" +
+		"#0\n$dumpvars\n1!\n0\"\n$end\n" +
+		"#2\n0!\n" +
+		"#3\n1\"\n" +
+		"#6\n1!\n" +
+		"#7\n0!\n" +
+		"#8\n"
+	if s := b.String(); s != expected {
+		t.Fatal(s)
+	}
+
+	// Round trip.
+	out, err := ReadVCD(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Name != "GPIO1" || out[1].Name != "GPIO2" {
+		t.Fatal(out)
+	}
+	msb, err := ToBitStreamMSB(out[0].Stream, out[0].Stream.Resolution())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(msb, signals[0].Stream) {
+		t.Fatal(msb)
+	}
+	// The signals last until the end of the dump.
+	e := &EdgeStream{Edges: []time.Duration{0, 3 * res, 5 * res}, Res: res}
+	if !reflect.DeepEqual(out[1].Stream, e) {
+		t.Fatal(out[1].Stream)
+	}
+}
+
+func TestWriteVCD_timescale(t *testing.T) {
+	data := []struct {
+		res      time.Duration
+		expected string
+	}{
+		{20 * time.Second, "10 s"},
+		{10 * time.Millisecond, "10 ms"},
+		{time.Microsecond, "1 us"},
+		{1500 * time.Nanosecond, "100 ns"},
+		{time.Nanosecond, "1 ns"},
+	}
+	for i, d := range data {
+		var b bytes.Buffer
+		s := &EdgeStream{Edges: []time.Duration{d.res}, Res: d.res}
+		if err := WriteVCD(&b, Signal{"A", s}); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), "$timescale "+d.expected+" $end") {
+			t.Fatalf("#%d: %s", i, b.String())
+		}
+	}
+	// Without any signal.
+	var b bytes.Buffer
+	if err := WriteVCD(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(b.String(), "$enddefinitions $end\n#0\n$dumpvars\n$end\n") {
+		t.Fatal(b.String())
+	}
+}
+
+func TestWriteVCD_fail(t *testing.T) {
+	s := &BitStream{Bits: Bits{1}, Res: time.Microsecond}
+	data := []Signal{
+		{"", s},
+		{"GPIO 1", s},
+		{"GPIO1", &Program{Parts: []Stream{s}, Loops: -1}},
+	}
+	for i, d := range data {
+		if err := WriteVCD(&bytes.Buffer{}, d); err == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+	}
+}
+
+func TestReadVCD(t *testing.T) {
+	// Similar to what PulseView exports.
+	const dump = `$date Mon Oct 16 2017 $end
+$version libsigrok 0.5.0 $end
+$comment
+  Acquisition with 2/8 channels at 1 MHz
+$end
+$timescale 1ns $end
+$scope module libsigrok $end
+$var wire 1 ! D0 $end
+$var wire 1 " D1 $end
+$var wire 4 # BUS $end
+$upscope $end
+$enddefinitions $end
+#0 x! 1" b0101 #
+#1000 1! $comment middle $end
+#2000 0! z"
+#4000 1! 0"
+#5000
+`
+	out, err := ReadVCD(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Signal{
+		{"D0", &EdgeStream{Edges: []time.Duration{0, time.Microsecond, time.Microsecond, 2 * time.Microsecond, time.Microsecond}, Res: time.Nanosecond}},
+		{"D1", &EdgeStream{Edges: []time.Duration{2 * time.Microsecond, 3 * time.Microsecond}, Res: time.Nanosecond}},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatal(out)
+	}
+}
+
+func TestReadVCD_fail(t *testing.T) {
+	data := []string{
+		"",
+		"$timescale 1 us $end",
+		"$timescale 1 us",
+		"$enddefinitions $end",
+		"$timescale 2 us $end $enddefinitions $end",
+		"$timescale 1 ps $end $enddefinitions $end",
+		"$timescale us $end $enddefinitions $end",
+		"$var wire 1 ! $end $enddefinitions $end",
+		"foo $enddefinitions $end",
+		"$timescale 1 us $end $enddefinitions $end #2 #1",
+		"$timescale 1 us $end $enddefinitions $end #a",
+		"$timescale 1 us $end $enddefinitions $end foo",
+		"$timescale 1 us $end $enddefinitions $end $comment",
+	}
+	for i, d := range data {
+		if _, err := ReadVCD(strings.NewReader(d)); err == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+	}
+}
+
+func TestVCDID(t *testing.T) {
+	data := []struct {
+		i        int
+		expected string
+	}{
+		{0, "!"},
+		{93, "~"},
+		{94, "!!"},
+		{95, "\"!"},
+	}
+	for _, d := range data {
+		if s := vcdID(d.i); s != d.expected {
+			t.Fatalf("%d: %q", d.i, s)
+		}
+	}
+}
@@ -8,15 +8,20 @@ package gpiotest
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioutil"
 )
 
 // Pin implements gpio.Pin.
 //
 // Modify its members to simulate hardware events.
+//
+// A Pin can also be connected to a Net to simulate a wire shared with other
+// Pins. L is then the level of the wire.
 type Pin struct {
 	N   string // Should be immutable
 	Num int    // Should be immutable
@@ -26,6 +31,13 @@ type Pin struct {
 	L          gpio.Level // Used for both input and output
 	P          gpio.Pull
 	EdgesChan  chan gpio.Level // Use it to fake edges
+
+	net   *Net
+	out   bool       // true if the pin drives the Net
+	drive gpio.Level // level driven on the Net
+	edge  gpio.Edge
+
+	stream gpioutil.EdgeStream
 }
 
 func (p *Pin) String() string {
@@ -49,32 +61,45 @@ func (p *Pin) Function() string {
 
 // Halt implements conn.Resource.
 //
-// It has no effect.
+// It stops the delivery of the edges started by Edges().
 func (p *Pin) Halt() error {
+	p.stream.Stop()
 	return nil
 }
 
 // In is concurrent safe.
+//
+// When the pin is connected to a Net, it stops driving it.
 func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
+	p.stream.Stop()
 	p.Lock()
-	defer p.Unlock()
 	p.P = pull
-	if pull == gpio.PullDown {
+	p.edge = edge
+	n := p.net
+	if n != nil {
+		p.out = false
+	} else if pull == gpio.PullDown {
 		p.L = gpio.Low
 	} else if pull == gpio.PullUp {
 		p.L = gpio.High
 	}
 	if edge != gpio.NoEdge && p.EdgesChan == nil {
+		p.Unlock()
 		return errors.New("gpiotest: please set p.EdgesChan first")
 	}
 	// Flush any buffered edges.
-	for {
+	for done := false; !done; {
 		select {
 		case <-p.EdgesChan:
 		default:
-			return nil
+			done = true
 		}
 	}
+	p.Unlock()
+	if n != nil {
+		n.update()
+	}
+	return nil
 }
 
 // Read is concurrent safe.
@@ -87,29 +112,272 @@ func (p *Pin) Read() gpio.Level {
 // WaitForEdge implements gpio.PinIn.
 func (p *Pin) WaitForEdge(timeout time.Duration) bool {
 	if timeout == -1 {
-		p.Out(<-p.EdgesChan)
+		p.set(<-p.EdgesChan)
 		return true
 	}
 	select {
 	case <-time.After(timeout):
 		return false
 	case l := <-p.EdgesChan:
-		p.Out(l)
+		p.set(l)
 		return true
 	}
 }
 
+// Edges implements gpio.PinEdges.
+//
+// The edges are taken from EdgesChan and timestamped when received.
+func (p *Pin) Edges(n int) (<-chan gpio.EdgeEvent, error) {
+	p.Lock()
+	edge := p.edge
+	p.Unlock()
+	if edge == gpio.NoEdge {
+		return nil, errors.New("gpiotest: edge detection is not enabled")
+	}
+	return p.stream.Start(n, func(timeout time.Duration) (gpio.EdgeEvent, bool) {
+		select {
+		case <-time.After(timeout):
+			return gpio.EdgeEvent{}, false
+		case l := <-p.EdgesChan:
+			p.set(l)
+			return gpio.EdgeEvent{T: time.Now(), L: l}, true
+		}
+	})
+}
+
 // Pull implements gpio.PinIn.
 func (p *Pin) Pull() gpio.Pull {
 	return p.P
 }
 
 // Out is concurrent safe.
+//
+// When the pin is connected to a Net, it drives it.
 func (p *Pin) Out(l gpio.Level) error {
+	p.stream.Stop()
+	p.Lock()
+	n := p.net
+	if n == nil {
+		p.L = l
+	} else {
+		p.out = true
+		p.drive = l
+	}
+	p.Unlock()
+	if n != nil {
+		n.update()
+	}
+	return nil
+}
+
+// Net simulates a wire connecting Pins.
+//
+// The level of the wire is resolved from the Pins driving it, or when none
+// does, from the pull resistors. A floating wire keeps its last level.
+//
+// Two Pins driving opposite levels on a push-pull wire is a contention,
+// reported by Err().
+type Net struct {
+	// Pull is the external pull resistor on the wire, if any.
+	Pull gpio.Pull
+	// OpenDrain makes the Pins only drive the wire low. Driving it high
+	// releases it, as for an I²C bus.
+	OpenDrain bool
+
+	mu      sync.Mutex
+	pins    []*Pin
+	l       gpio.Level
+	drive   gpio.Level // level driven by Drive()
+	driving bool
+	err     error
+	watch   []func(l gpio.Level)
+}
+
+// Connect connects the pins to the wire.
+//
+// A Pin can only be connected to one Net.
+func (n *Net) Connect(pins ...*Pin) error {
+	n.mu.Lock()
+	for _, p := range pins {
+		p.Lock()
+		if p.net != nil {
+			p.Unlock()
+			n.mu.Unlock()
+			return fmt.Errorf("gpiotest: %s is already connected", p)
+		}
+		p.net = n
+		p.out = false
+		p.Unlock()
+		n.pins = append(n.pins, p)
+	}
+	n.mu.Unlock()
+	n.update()
+	return nil
+}
+
+// Read returns the level of the wire.
+func (n *Net) Read() gpio.Level {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.l
+}
+
+// Err returns the first contention detected, if any.
+func (n *Net) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.err
+}
+
+// Watch registers f to be called each time the level of the wire changes.
+//
+// f is called synchronously by the goroutine that caused the change, after
+// the Pins connected to the wire were updated. It can drive Pins, which makes
+// it possible to simulate a peer device.
+func (n *Net) Watch(f func(l gpio.Level)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.watch = append(n.watch, f)
+}
+
+// Drive drives the wire like an external signal source would.
+func (n *Net) Drive(l gpio.Level) {
+	n.mu.Lock()
+	n.drive = l
+	n.driving = true
+	n.mu.Unlock()
+	n.update()
+}
+
+// Release stops driving the wire started with Drive().
+func (n *Net) Release() {
+	n.mu.Lock()
+	n.driving = false
+	n.mu.Unlock()
+	n.update()
+}
+
+// Step is a step of a waveform injected with Play().
+type Step struct {
+	Delay time.Duration // Delay before L is driven
+	L     gpio.Level
+}
+
+// Play drives the wire with the waveform w.
+//
+// It returns once the last step is driven. The wire is still driven
+// afterward; use Release() to stop.
+func (n *Net) Play(w []Step) {
+	for _, s := range w {
+		if s.Delay > 0 {
+			time.Sleep(s.Delay)
+		}
+		n.Drive(s.L)
+	}
+}
+
+//
+
+// set sets the level without driving a Net.
+func (p *Pin) set(l gpio.Level) {
 	p.Lock()
 	defer p.Unlock()
 	p.L = l
-	return nil
+}
+
+// update resolves the level of the wire and notifies the changes.
+func (n *Net) update() {
+	n.mu.Lock()
+	l := n.resolve()
+	changed := l != n.l
+	n.l = l
+	var edges []*Pin
+	for _, p := range n.pins {
+		p.Lock()
+		p.L = l
+		if changed && p.EdgesChan != nil && isEdge(p.edge, l) {
+			edges = append(edges, p)
+		}
+		p.Unlock()
+	}
+	watch := n.watch
+	n.mu.Unlock()
+	if !changed {
+		return
+	}
+	for _, p := range edges {
+		select {
+		case p.EdgesChan <- l:
+		default:
+		}
+	}
+	for _, f := range watch {
+		f(l)
+	}
+}
+
+// resolve returns the level of the wire.
+//
+// n.mu must be held.
+func (n *Net) resolve() gpio.Level {
+	var lo, hi []string
+	if n.driving {
+		if n.drive {
+			hi = append(hi, "Drive()")
+		} else {
+			lo = append(lo, "Drive()")
+		}
+	}
+	up := n.Pull == gpio.PullUp
+	down := n.Pull == gpio.PullDown
+	for _, p := range n.pins {
+		p.Lock()
+		if p.out {
+			if p.drive {
+				hi = append(hi, p.String())
+			} else {
+				lo = append(lo, p.String())
+			}
+		}
+		up = up || p.P == gpio.PullUp
+		down = down || p.P == gpio.PullDown
+		p.Unlock()
+	}
+	if n.OpenDrain {
+		hi = nil
+	}
+	switch {
+	case len(lo) != 0 && len(hi) != 0:
+		if n.err == nil {
+			n.err = fmt.Errorf("gpiotest: contention between %s driving low and %s driving high", strings.Join(lo, ", "), strings.Join(hi, ", "))
+		}
+		return gpio.Low
+	case len(lo) != 0:
+		return gpio.Low
+	case len(hi) != 0:
+		return gpio.High
+	case up && !down:
+		return gpio.High
+	case down && !up:
+		return gpio.Low
+	default:
+		return n.l
+	}
+}
+
+// isEdge returns true if a change to level l is an edge detected by e.
+func isEdge(e gpio.Edge, l gpio.Level) bool {
+	switch e {
+	case gpio.RisingEdge:
+		return l == gpio.High
+	case gpio.FallingEdge:
+		return l == gpio.Low
+	case gpio.BothEdges:
+		return true
+	default:
+		return false
+	}
 }
 
 var _ gpio.PinIO = &Pin{}
+var _ gpio.PinEdges = &Pin{}
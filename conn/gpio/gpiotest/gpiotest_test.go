@@ -37,6 +37,37 @@ func TestPin(t *testing.T) {
 	}
 }
 
+func TestPin_Edges(t *testing.T) {
+	p := &Pin{N: "GPIO1", Num: 1, EdgesChan: make(chan gpio.Level, 1)}
+	if _, err := p.Edges(1); err == nil {
+		t.Fatal("edge detection is not enabled")
+	}
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Edges(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.EdgesChan <- gpio.High
+	if e := <-c; e.L != gpio.High || e.Seq != 1 || e.T.IsZero() {
+		t.Fatalf("%#v", e)
+	}
+	if p.Read() != gpio.High {
+		t.Fatal("level not updated")
+	}
+	p.EdgesChan <- gpio.Low
+	if e := <-c; e.L != gpio.Low || e.Seq != 2 {
+		t.Fatalf("%#v", e)
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-c; ok {
+		t.Fatal("channel must be closed")
+	}
+}
+
 func TestPin_edge(t *testing.T) {
 	p := &Pin{N: "GPIO1", Num: 1, Fn: "I2C1_SDA", EdgesChan: make(chan gpio.Level, 1)}
 	p.EdgesChan <- gpio.High
@@ -65,6 +96,101 @@ func TestPin_fail(t *testing.T) {
 	}
 }
 
+func TestNet(t *testing.T) {
+	a := &Pin{N: "A", Num: 1}
+	b := &Pin{N: "B", Num: 2}
+	n := Net{}
+	if err := n.Connect(a, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Connect(a); err == nil {
+		t.Fatal("already connected")
+	}
+	if err := a.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if l := b.Read(); l != gpio.High {
+		t.Fatal(l)
+	}
+	// Floating keeps the last level.
+	if err := a.In(gpio.Float, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if l := n.Read(); l != gpio.High {
+		t.Fatal(l)
+	}
+	if err := b.In(gpio.PullDown, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if l := a.Read(); l != gpio.Low {
+		t.Fatal(l)
+	}
+	if err := n.Err(); err != nil {
+		t.Fatal(err)
+	}
+	a.Out(gpio.High)
+	b.Out(gpio.Low)
+	if n.Err() == nil {
+		t.Fatal("expected contention")
+	}
+}
+
+func TestNet_OpenDrain(t *testing.T) {
+	a := &Pin{N: "SDA", Num: 1}
+	b := &Pin{N: "SDA", Num: 2}
+	n := Net{Pull: gpio.PullUp, OpenDrain: true}
+	if err := n.Connect(a, b); err != nil {
+		t.Fatal(err)
+	}
+	if l := a.Read(); l != gpio.High {
+		t.Fatal(l)
+	}
+	a.Out(gpio.High)
+	b.Out(gpio.Low)
+	if l := a.Read(); l != gpio.Low {
+		t.Fatal(l)
+	}
+	b.Out(gpio.High)
+	if l := a.Read(); l != gpio.High {
+		t.Fatal(l)
+	}
+	if err := n.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNet_edges(t *testing.T) {
+	p := &Pin{N: "GPIO1", Num: 1, EdgesChan: make(chan gpio.Level, 10)}
+	n := Net{}
+	if err := n.Connect(p); err != nil {
+		t.Fatal(err)
+	}
+	var got []gpio.Level
+	n.Watch(func(l gpio.Level) { got = append(got, l) })
+	if err := p.In(gpio.Float, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	n.Play([]Step{{L: gpio.High}, {Delay: time.Millisecond, L: gpio.Low}, {L: gpio.Low}, {L: gpio.High}})
+	if !p.WaitForEdge(time.Minute) || p.Read() != gpio.High {
+		t.Fatal("expected rising edge")
+	}
+	if !p.WaitForEdge(time.Minute) || p.Read() != gpio.High {
+		t.Fatal("expected rising edge")
+	}
+	if p.WaitForEdge(0) {
+		t.Fatal("falling edges are not detected")
+	}
+	if len(got) != 3 {
+		t.Fatal(got)
+	}
+	// Once released, the pin drives the wire.
+	n.Release()
+	p.Out(gpio.Low)
+	if l := n.Read(); l != gpio.Low {
+		t.Fatal(l)
+	}
+}
+
 func TestAll(t *testing.T) {
 	if 2 != len(gpioreg.All()) {
 		t.Fail()
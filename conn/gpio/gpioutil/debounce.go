@@ -0,0 +1,180 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpioutil
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// DebounceOpts configures Debounce.
+type DebounceOpts struct {
+	// Stable is the time during which the bounces are ignored after an edge.
+	// If the level changed during this window, the edge is reported at the end
+	// of the window.
+	Stable time.Duration
+	// Rising and Falling replace Stable after a rising, respectively falling,
+	// edge when not 0.
+	Rising  time.Duration
+	Falling time.Duration
+	// Glitch is the minimum duration of a pulse. A level has to be stable for
+	// this duration before the edge is reported, which delays the edges.
+	Glitch time.Duration
+}
+
+// Debounce returns a gpio.PinIn that filters the edges of p.
+//
+// The edges are reported by WaitForEdge() right away, then the bounces are
+// ignored during the stable window set in opts. Use Glitch to also ignore
+// short pulses, for example caused by electrical noise.
+//
+// When p supports hardware debouncing, like sysfs.GPIOLine, it is used for
+// the glitch filter instead.
+func Debounce(p gpio.PinIn, opts DebounceOpts) (gpio.PinIn, error) {
+	if opts.Stable < 0 || opts.Rising < 0 || opts.Falling < 0 || opts.Glitch < 0 {
+		return nil, errors.New("gpioutil: invalid debounce duration")
+	}
+	d := &debounced{PinIn: p, opts: opts}
+	if h, ok := p.(debouncer); ok && opts.Glitch != 0 {
+		d.hw = h.SetDebounce(opts.Glitch) == nil
+	}
+	return d, nil
+}
+
+//
+
+// debouncer is implemented by a pin supporting hardware debouncing.
+type debouncer interface {
+	SetDebounce(d time.Duration) error
+}
+
+type debounced struct {
+	gpio.PinIn
+	opts DebounceOpts
+
+	mu    sync.Mutex
+	hw    bool       // the glitch filter is done by the pin
+	edge  gpio.Edge  // edge requested by In()
+	last  gpio.Level // last level reported
+	until time.Time  // end of the stable window
+}
+
+// In implements gpio.PinIn.
+//
+// Both edges are detected on the pin to keep track of its level.
+func (d *debounced) In(pull gpio.Pull, edge gpio.Edge) error {
+	e := edge
+	if e != gpio.NoEdge {
+		e = gpio.BothEdges
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.PinIn.In(pull, e)
+	if err != nil && d.hw {
+		// The GPIO controller may not support debouncing.
+		if d.PinIn.(debouncer).SetDebounce(0) == nil {
+			d.hw = false
+			err = d.PinIn.In(pull, e)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	d.edge = edge
+	d.last = d.PinIn.Read()
+	d.until = time.Time{}
+	return nil
+}
+
+// WaitForEdge implements gpio.PinIn.
+//
+// With a glitch filter, it may return up to Glitch after the timeout.
+func (d *debounced) WaitForEdge(timeout time.Duration) bool {
+	start := time.Now()
+	remaining := func() time.Duration {
+		if timeout < 0 {
+			return -1
+		}
+		if r := timeout - time.Since(start); r > 0 {
+			return r
+		}
+		return 0
+	}
+	for {
+		d.mu.Lock()
+		edge, last, until := d.edge, d.last, d.until
+		d.mu.Unlock()
+		if edge == gpio.NoEdge {
+			return false
+		}
+		if w := until.Sub(time.Now()); w > 0 {
+			// Ignore the bounces until the end of the window.
+			t := until
+			if r := remaining(); r >= 0 && r < w {
+				t = time.Now().Add(r)
+			}
+			d.drain(t)
+			if time.Now().Before(until) {
+				return false
+			}
+		} else if !d.PinIn.WaitForEdge(remaining()) {
+			return false
+		}
+		l := d.settle()
+		if l != last {
+			d.mu.Lock()
+			d.last = l
+			d.until = time.Now().Add(d.window(l))
+			d.mu.Unlock()
+			if edge == gpio.BothEdges || (edge == gpio.RisingEdge) == bool(l) {
+				return true
+			}
+		}
+		if remaining() == 0 {
+			return false
+		}
+	}
+}
+
+// drain consumes the edges until t.
+func (d *debounced) drain(t time.Time) {
+	for {
+		w := t.Sub(time.Now())
+		if w <= 0 || !d.PinIn.WaitForEdge(w) {
+			return
+		}
+	}
+}
+
+// settle waits for the level to be stable for Glitch and returns it.
+func (d *debounced) settle() gpio.Level {
+	d.mu.Lock()
+	glitch := d.opts.Glitch
+	if d.hw {
+		glitch = 0
+	}
+	d.mu.Unlock()
+	if glitch != 0 {
+		for d.PinIn.WaitForEdge(glitch) {
+		}
+	}
+	return d.PinIn.Read()
+}
+
+// window returns the stable window after an edge to level l.
+func (d *debounced) window(l gpio.Level) time.Duration {
+	if l && d.opts.Rising != 0 {
+		return d.opts.Rising
+	}
+	if !l && d.opts.Falling != 0 {
+		return d.opts.Falling
+	}
+	return d.opts.Stable
+}
+
+var _ gpio.PinIn = &debounced{}
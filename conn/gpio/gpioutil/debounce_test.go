@@ -0,0 +1,249 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpioutil
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestDebounce_Stable(t *testing.T) {
+	p := &fakeIn{edges: make(chan gpio.Level, 10)}
+	d, err := Debounce(p, DebounceOpts{Stable: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.WaitForEdge(0) {
+		t.Fatal("edge detection is not enabled")
+	}
+	if err := d.In(gpio.PullDown, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	// A press with bounces.
+	p.edges <- gpio.High
+	p.edges <- gpio.Low
+	p.edges <- gpio.High
+	if !d.WaitForEdge(-1) || d.Read() != gpio.High {
+		t.Fatal("expected rising edge")
+	}
+	// The bounces are ignored.
+	if d.WaitForEdge(100 * time.Millisecond) {
+		t.Fatal("unexpected edge")
+	}
+	if len(p.edges) != 0 {
+		t.Fatal("bounces were not consumed")
+	}
+	p.edges <- gpio.Low
+	if !d.WaitForEdge(time.Minute) || d.Read() != gpio.Low {
+		t.Fatal("expected falling edge")
+	}
+	// A release during the window is reported at the end of the window.
+	p.edges <- gpio.High
+	if !d.WaitForEdge(time.Minute) || d.Read() != gpio.High {
+		t.Fatal("expected rising edge")
+	}
+}
+
+func TestDebounce_Rising(t *testing.T) {
+	p := &fakeIn{edges: make(chan gpio.Level, 10)}
+	d, err := Debounce(p, DebounceOpts{Rising: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.In(gpio.PullDown, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	p.edges <- gpio.High
+	if !d.WaitForEdge(-1) {
+		t.Fatal("expected rising edge")
+	}
+	// The window after a rising edge is long.
+	p.edges <- gpio.Low
+	p.edges <- gpio.High
+	if d.WaitForEdge(10 * time.Millisecond) {
+		t.Fatal("unexpected edge")
+	}
+	w := d.(*debounced)
+	if v := w.window(gpio.High); v != time.Minute {
+		t.Fatal(v)
+	}
+	if v := w.window(gpio.Low); v != 0 {
+		t.Fatal(v)
+	}
+	w.opts.Falling = time.Second
+	if v := w.window(gpio.Low); v != time.Second {
+		t.Fatal(v)
+	}
+}
+
+func TestDebounce_Glitch(t *testing.T) {
+	p := &fakeIn{edges: make(chan gpio.Level, 10)}
+	d, err := Debounce(p, DebounceOpts{Glitch: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.In(gpio.PullDown, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	// A short pulse is ignored.
+	p.edges <- gpio.High
+	p.edges <- gpio.Low
+	if d.WaitForEdge(50 * time.Millisecond) {
+		t.Fatal("unexpected edge")
+	}
+	// A falling edge is not reported.
+	p.edges <- gpio.High
+	if !d.WaitForEdge(time.Minute) {
+		t.Fatal("expected rising edge")
+	}
+	p.edges <- gpio.Low
+	if d.WaitForEdge(50 * time.Millisecond) {
+		t.Fatal("unexpected edge")
+	}
+	if err := d.In(gpio.PullDown, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if d.WaitForEdge(0) {
+		t.Fatal("edge detection is not enabled")
+	}
+}
+
+func TestDebounce_hardware(t *testing.T) {
+	p := &hwPin{fakeIn: fakeIn{edges: make(chan gpio.Level, 10)}}
+	d, err := Debounce(p, DebounceOpts{Glitch: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.debounce != time.Millisecond {
+		t.Fatal(p.debounce)
+	}
+	if !d.(*debounced).hw {
+		t.Fatal("expected hardware debouncing")
+	}
+	// The pin rejects the debounce period, so it falls back to software.
+	p.err = errors.New("injected")
+	if err := d.In(gpio.PullDown, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if p.debounce != 0 || d.(*debounced).hw {
+		t.Fatal("expected software debouncing")
+	}
+	p = &hwPin{err: errors.New("injected")}
+	if d, err = Debounce(p, DebounceOpts{Glitch: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if d.(*debounced).hw {
+		t.Fatal("expected software debouncing")
+	}
+}
+
+func TestDebounce_fail(t *testing.T) {
+	p := &fakeIn{}
+	if _, err := Debounce(p, DebounceOpts{Stable: -1}); err == nil {
+		t.Fatal("invalid duration")
+	}
+	d, err := Debounce(p, DebounceOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.err = errors.New("injected")
+	if d.In(gpio.PullNoChange, gpio.RisingEdge) == nil {
+		t.Fatal("expected failure")
+	}
+}
+
+//
+
+// fakeIn is a gpio.PinIn that takes its edges from a channel, as
+// gpiotest.Pin does.
+type fakeIn struct {
+	mu    sync.Mutex
+	l     gpio.Level
+	edges chan gpio.Level
+	err   error
+}
+
+func (p *fakeIn) String() string {
+	return "fake"
+}
+
+func (p *fakeIn) Name() string {
+	return "fake"
+}
+
+func (p *fakeIn) Number() int {
+	return 1
+}
+
+func (p *fakeIn) Function() string {
+	return ""
+}
+
+func (p *fakeIn) Halt() error {
+	return nil
+}
+
+func (p *fakeIn) In(pull gpio.Pull, edge gpio.Edge) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.l = pull == gpio.PullUp
+	return nil
+}
+
+func (p *fakeIn) Read() gpio.Level {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.l
+}
+
+func (p *fakeIn) WaitForEdge(timeout time.Duration) bool {
+	var t <-chan time.Time
+	if timeout >= 0 {
+		t = time.After(timeout)
+	}
+	select {
+	case <-t:
+		return false
+	case l := <-p.edges:
+		p.mu.Lock()
+		p.l = l
+		p.mu.Unlock()
+		return true
+	}
+}
+
+func (p *fakeIn) Pull() gpio.Pull {
+	return gpio.PullNoChange
+}
+
+// hwPin is a pin supporting hardware debouncing. It fails In() with a debounce
+// period when err is set.
+type hwPin struct {
+	fakeIn
+	debounce time.Duration
+	err      error
+}
+
+func (p *hwPin) SetDebounce(d time.Duration) error {
+	if d != 0 && p.err != nil {
+		return p.err
+	}
+	p.debounce = d
+	return nil
+}
+
+func (p *hwPin) In(pull gpio.Pull, edge gpio.Edge) error {
+	if p.debounce != 0 && p.err != nil {
+		return p.err
+	}
+	return p.fakeIn.In(pull, edge)
+}
@@ -0,0 +1,25 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpioutil
+
+import "periph.io/x/periph/conn/gpio"
+
+// fakeIO is a gpio.PinIO that records the level set with Out() and returns it
+// in Read().
+type fakeIO struct {
+	fakeIn
+}
+
+func (p *fakeIO) Out(l gpio.Level) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.l = l
+	return nil
+}
+
+var _ gpio.PinIO = &fakeIO{}
@@ -0,0 +1,121 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package gpioutil includes utilities to use GPIO pins and to implement GPIO
+// drivers.
+package gpioutil
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// OnEdge calls f for each edge delivered by p, from a single goroutine.
+//
+// Edge detection must have been enabled with In() first. The edges detected
+// while f is running are queued, up to n edges. It stops when the delivery of
+// the edges stops, as described in gpio.PinEdges.
+func OnEdge(p gpio.PinEdges, n int, f func(e gpio.EdgeEvent)) error {
+	c, err := p.Edges(n)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for e := range c {
+			f(e)
+		}
+	}()
+	return nil
+}
+
+// EdgeStream delivers the edges detected by a driver on a channel.
+//
+// It is meant to be used by the drivers to implement gpio.PinEdges on top of
+// their edge detection. The zero value is ready to use.
+type EdgeStream struct {
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start starts a goroutine that calls wait in a loop and delivers the detected
+// edges on a channel with room for n events. The previous delivery is stopped
+// first.
+//
+// wait must wait up to timeout for an edge and return false if none occurred.
+// EdgeStream sets the fields Seq and Dropped of the events.
+func (s *EdgeStream) Start(n int, wait func(timeout time.Duration) (gpio.EdgeEvent, bool)) (<-chan gpio.EdgeEvent, error) {
+	if n < 0 {
+		return nil, errors.New("gpioutil: invalid channel size")
+	}
+	s.Stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := make(chan gpio.EdgeEvent, n)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go deliver(c, s.stop, s.done, wait)
+	return c, nil
+}
+
+// Stop stops the delivery of the edges and closes the channel.
+//
+// It waits for the current call to wait to return, so the caller must not
+// hold a lock needed by wait. It is a no-op when the delivery is not started.
+func (s *EdgeStream) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+	s.done = nil
+}
+
+//
+
+// pollPeriod is the maximum duration of a call to wait, which bounds the time
+// Stop() blocks.
+const pollPeriod = 100 * time.Millisecond
+
+func deliver(c chan<- gpio.EdgeEvent, stop <-chan struct{}, done chan<- struct{}, wait func(timeout time.Duration) (gpio.EdgeEvent, bool)) {
+	defer close(done)
+	defer close(c)
+	var seq uint64
+	dropped := 0
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		start := time.Now()
+		e, ok := wait(pollPeriod)
+		if !ok {
+			// Do not spin when wait fails without waiting.
+			if d := pollPeriod - time.Since(start); d > 0 {
+				select {
+				case <-stop:
+					return
+				case <-time.After(d):
+				}
+			}
+			continue
+		}
+		seq++
+		e.Seq = seq
+		e.Dropped = dropped
+		select {
+		case c <- e:
+			dropped = 0
+		default:
+			dropped++
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpioutil
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestEdgeStream(t *testing.T) {
+	f := &fakeEdges{c: make(chan gpio.Level)}
+	s := EdgeStream{}
+	if _, err := s.Start(-1, nil); err == nil {
+		t.Fatal("invalid channel size")
+	}
+	c, err := s.Start(1, f.wait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The channel has room for one event, so the two following edges are
+	// dropped.
+	f.c <- gpio.High
+	f.c <- gpio.Low
+	f.c <- gpio.High
+	f.sync(t)
+	if e := <-c; e.L != gpio.High || e.Seq != 1 || e.Dropped != 0 {
+		t.Fatalf("%#v", e)
+	}
+	f.c <- gpio.Low
+	if e := <-c; e.L != gpio.Low || e.Seq != 4 || e.Dropped != 2 {
+		t.Fatalf("%#v", e)
+	}
+	// Starting again stops the previous delivery.
+	c2, err := s.Start(1, f.wait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-c; ok {
+		t.Fatal("channel must be closed")
+	}
+	f.c <- gpio.High
+	if e := <-c2; e.L != gpio.High || e.Seq != 1 {
+		t.Fatalf("%#v", e)
+	}
+	s.Stop()
+	if _, ok := <-c2; ok {
+		t.Fatal("channel must be closed")
+	}
+	s.Stop()
+}
+
+func TestEdgeStream_fail(t *testing.T) {
+	s := EdgeStream{}
+	c, err := s.Start(1, func(timeout time.Duration) (gpio.EdgeEvent, bool) {
+		return gpio.EdgeEvent{}, false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Stop()
+	if _, ok := <-c; ok {
+		t.Fatal("channel must be closed")
+	}
+}
+
+func TestOnEdge(t *testing.T) {
+	p := &fakePin{f: fakeEdges{c: make(chan gpio.Level)}}
+	got := make(chan gpio.EdgeEvent)
+	if err := OnEdge(p, 10, func(e gpio.EdgeEvent) { got <- e }); err != nil {
+		t.Fatal(err)
+	}
+	p.f.c <- gpio.High
+	if e := <-got; e.L != gpio.High || e.Seq != 1 {
+		t.Fatalf("%#v", e)
+	}
+	p.s.Stop()
+	p.err = errors.New("injected")
+	if OnEdge(p, 10, func(e gpio.EdgeEvent) {}) == nil {
+		t.Fatal("expected failure")
+	}
+}
+
+//
+
+type fakePin struct {
+	f   fakeEdges
+	s   EdgeStream
+	err error
+}
+
+func (p *fakePin) Edges(n int) (<-chan gpio.EdgeEvent, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.s.Start(n, p.f.wait)
+}
+
+// fakeEdges simulates the edge detection of a driver.
+type fakeEdges struct {
+	c     chan gpio.Level
+	calls int32
+}
+
+func (f *fakeEdges) wait(timeout time.Duration) (gpio.EdgeEvent, bool) {
+	atomic.AddInt32(&f.calls, 1)
+	select {
+	case <-time.After(timeout):
+		return gpio.EdgeEvent{}, false
+	case l := <-f.c:
+		return gpio.EdgeEvent{T: time.Now(), L: l}, true
+	}
+}
+
+// sync waits for the last edge sent to be processed.
+func (f *fakeEdges) sync(t *testing.T) {
+	n := atomic.LoadInt32(&f.calls)
+	for start := time.Now(); atomic.LoadInt32(&f.calls) == n; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Minute {
+			t.Fatal("edge not processed")
+		}
+	}
+}
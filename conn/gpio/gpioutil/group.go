@@ -0,0 +1,60 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpioutil
+
+import (
+	"errors"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// NewGroup returns a gpio.Group that accesses the pins one after the other.
+//
+// It works with any pins, like sysfs or gpiotest pins. During Out(), the pins
+// change one at a time in the order of Pins(). When the pins are handled by a
+// driver that can access them with a few register accesses, like
+// bcm283x.NewGroup, prefer the group of the driver.
+func NewGroup(pins ...gpio.PinIO) (gpio.Group, error) {
+	if len(pins) == 0 || len(pins) > 32 {
+		return nil, errors.New("gpioutil: a group must have between 1 and 32 pins")
+	}
+	return &group{pins: pins}, nil
+}
+
+//
+
+type group struct {
+	pins []gpio.PinIO
+}
+
+func (g *group) Pins() []gpio.PinIO {
+	return g.pins
+}
+
+// Out calls Out() on the pins selected by mask, in order.
+func (g *group) Out(v, mask uint32) error {
+	for i, p := range g.pins {
+		if mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		if err := p.Out(v&(1<<uint(i)) != 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read calls Read() on the pins selected by mask, in order.
+func (g *group) Read(mask uint32) uint32 {
+	var v uint32
+	for i, p := range g.pins {
+		if mask&(1<<uint(i)) != 0 && p.Read() {
+			v |= 1 << uint(i)
+		}
+	}
+	return v
+}
+
+var _ gpio.Group = &group{}
@@ -0,0 +1,53 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpioutil
+
+import (
+	"errors"
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestNewGroup(t *testing.T) {
+	pins := []*fakeIO{{}, {}, {}}
+	g, err := NewGroup(pins[0], pins[1], pins[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(g.Pins()); l != 3 {
+		t.Fatal(l)
+	}
+	if err := g.Out(0x5, 0x7); err != nil {
+		t.Fatal(err)
+	}
+	if pins[0].l != gpio.High || pins[1].l != gpio.Low || pins[2].l != gpio.High {
+		t.Fatal("unexpected levels")
+	}
+	// Pins not in the mask are left untouched.
+	if err := g.Out(0x2, 0x2); err != nil {
+		t.Fatal(err)
+	}
+	if v := g.Read(0x7); v != 0x7 {
+		t.Fatalf("0x%x", v)
+	}
+	if v := g.Read(0x6); v != 0x6 {
+		t.Fatalf("0x%x", v)
+	}
+	pins[1].err = errors.New("injected")
+	if g.Out(0, 0x7) == nil {
+		t.Fatal("expected failure")
+	}
+}
+
+func TestNewGroup_fail(t *testing.T) {
+	if _, err := NewGroup(); err == nil {
+		t.Fatal("empty group")
+	}
+	pins := make([]gpio.PinIO, 33)
+	if _, err := NewGroup(pins...); err == nil {
+		t.Fatal("too many pins")
+	}
+}
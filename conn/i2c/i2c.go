@@ -10,6 +10,8 @@
 package i2c
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 
@@ -33,6 +35,118 @@ type Bus interface {
 	SetSpeed(hz int64) error
 }
 
+// TxContexter is implemented by a Bus that natively supports a context for
+// its transactions.
+//
+// Users should call TxContext() instead of asserting this interface directly.
+type TxContexter interface {
+	// TxContext does a transaction like Bus.Tx() but returns ctx.Err()
+	// without starting it if ctx is already done.
+	//
+	// Whether a transaction already started is interrupted when ctx is done
+	// depends on the implementation, which must document it.
+	TxContext(ctx context.Context, addr uint16, w, r []byte) error
+}
+
+// TxContext does a transaction on b unless ctx is already done.
+//
+// If b implements TxContexter, its TxContext() method is used. Otherwise the
+// transaction is run via conn.RunContext(), which doesn't interrupt it once
+// started.
+func TxContext(ctx context.Context, b Bus, addr uint16, w, r []byte) error {
+	if t, ok := b.(TxContexter); ok {
+		return t.TxContext(ctx, addr, w, r)
+	}
+	return conn.RunContext(ctx, w, r, func(w, r []byte) error {
+		return b.Tx(addr, w, r)
+	})
+}
+
+// WithContext returns a Bus that runs every transaction through TxContext()
+// with ctx.
+//
+// Once ctx is done, the next transactions fail; the one in flight completes.
+// This is useful to apply a deadline to the I/O done by an existing device
+// driver that doesn't accept a context.Context, for example:
+//
+//   ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//   defer cancel()
+//   dev, err := bmxx80.NewI2C(i2c.WithContext(ctx, b), 0x76, nil)
+func WithContext(ctx context.Context, b Bus) Bus {
+	return &ctxBus{ctx: ctx, b: b}
+}
+
+// WithRetry returns a Bus that retries the transactions on b that failed with
+// a transient error, as specified by r.
+//
+// If r is nil, the zero value of conn.Retry is used. This is useful to use an
+// existing device driver over long cables, for example:
+//
+//   r := &conn.Retry{Attempts: 5, Backoff: time.Millisecond}
+//   dev, err := bmxx80.NewI2C(i2c.WithRetry(b, r), 0x76, nil)
+func WithRetry(b Bus, r *conn.Retry) Bus {
+	if r == nil {
+		r = &conn.Retry{}
+	}
+	return &retryBus{b: b, r: r}
+}
+
+// Msg is one segment of a multi segment transaction done with
+// TxMessages().
+//
+// Each segment starts with a START condition, or a repeated START condition
+// for all but the first one, followed by the address.
+type Msg struct {
+	// Addr is the device address for this segment. Segments in a transaction
+	// can address different devices.
+	Addr uint16
+	// W and R are the output and input data. Only one of the two can be set. If
+	// both are empty, the segment is a zero length write.
+	W, R []byte
+}
+
+// TxMessager is implemented by a Bus that supports transactions with an
+// arbitrary number of segments separated by repeated START conditions,
+// without a STOP condition between them.
+//
+// Users should call TxMessages() instead of asserting this interface
+// directly.
+type TxMessager interface {
+	// TxMessages does a single transaction made of multiple segments.
+	TxMessages(msgs []Msg) error
+}
+
+// TxMessages does a single transaction made of the segments msgs on b.
+//
+// If b implements TxMessager, its TxMessages() method is used. Otherwise only
+// the patterns that can be expressed with Bus.Tx() are supported: a single
+// segment or a write followed by a read at the same address. A lone zero
+// length write is not supported in that case, as drivers commonly skip an
+// empty Bus.Tx() without touching the bus; an error of kind
+// conn.ErrUnsupported is returned.
+func TxMessages(b Bus, msgs []Msg) error {
+	for i := range msgs {
+		if len(msgs[i].W) != 0 && len(msgs[i].R) != 0 {
+			return fmt.Errorf("i2c: message #%d has both W and R set", i)
+		}
+	}
+	if t, ok := b.(TxMessager); ok {
+		return t.TxMessages(msgs)
+	}
+	switch {
+	case len(msgs) == 1 && len(msgs[0].W) == 0 && len(msgs[0].R) == 0:
+		return &conn.Error{Kind: conn.ErrUnsupported, Msg: fmt.Sprintf("i2c: %s doesn't support zero length writes", b)}
+	case len(msgs) == 1:
+		return b.Tx(msgs[0].Addr, msgs[0].W, msgs[0].R)
+	case len(msgs) == 2 && msgs[0].Addr == msgs[1].Addr && len(msgs[0].W) != 0 && len(msgs[1].R) != 0:
+		return b.Tx(msgs[0].Addr, msgs[0].W, msgs[1].R)
+	case len(msgs) == 0:
+		return errors.New("i2c: no message")
+	default:
+		return fmt.Errorf("i2c: %s doesn't support transactions of %d segments", b, len(msgs))
+	}
+}
+
 // BusCloser is an I²C bus that can be closed.
 //
 // This interface is meant to be handled by the application and not the device
@@ -75,6 +189,13 @@ func (d *Dev) Tx(w, r []byte) error {
 	return d.Bus.Tx(d.Addr, w, r)
 }
 
+// TxContext does a transaction like Tx() unless ctx is already done.
+//
+// It's a wrapper for TxContext().
+func (d *Dev) TxContext(ctx context.Context, w, r []byte) error {
+	return TxContext(ctx, d.Bus, d.Addr, w, r)
+}
+
 // Write writes to the I²C bus without reading, implementing io.Writer.
 //
 // It's a wrapper for Tx()
@@ -92,4 +213,64 @@ func (d *Dev) Duplex() conn.Duplex {
 
 //
 
+// ctxBus implements Bus with the transactions bound to a context.
+type ctxBus struct {
+	ctx context.Context
+	b   Bus
+}
+
+func (c *ctxBus) String() string {
+	return fmt.Sprint(c.b)
+}
+
+func (c *ctxBus) Tx(addr uint16, w, r []byte) error {
+	return TxContext(c.ctx, c.b, addr, w, r)
+}
+
+// TxMessages forwards to the underlying Bus once ctx was verified, as the
+// segments must not be split. ctx is only checked before the transaction
+// starts.
+func (c *ctxBus) TxMessages(msgs []Msg) error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	return TxMessages(c.b, msgs)
+}
+
+func (c *ctxBus) SetSpeed(hz int64) error {
+	return c.b.SetSpeed(hz)
+}
+
+// retryBus implements Bus with the transactions retried.
+type retryBus struct {
+	b Bus
+	r *conn.Retry
+}
+
+func (r *retryBus) String() string {
+	return fmt.Sprint(r.b)
+}
+
+func (r *retryBus) Tx(addr uint16, w, read []byte) error {
+	return r.r.Do(context.Background(), func() error { return r.b.Tx(addr, w, read) })
+}
+
+func (r *retryBus) TxContext(ctx context.Context, addr uint16, w, read []byte) error {
+	return r.r.Do(ctx, func() error { return TxContext(ctx, r.b, addr, w, read) })
+}
+
+func (r *retryBus) TxMessages(msgs []Msg) error {
+	return r.r.Do(context.Background(), func() error { return TxMessages(r.b, msgs) })
+}
+
+func (r *retryBus) SetSpeed(hz int64) error {
+	return r.b.SetSpeed(hz)
+}
+
 var _ conn.Conn = &Dev{}
+var _ conn.TxContexter = &Dev{}
+var _ Bus = &ctxBus{}
+var _ TxMessager = &ctxBus{}
+var _ Bus = &retryBus{}
+var _ TxContexter = &retryBus{}
+var _ TxMessager = &retryBus{}
@@ -6,9 +6,11 @@ package i2c
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"reflect"
 	"testing"
 
 	"periph.io/x/periph/conn"
@@ -106,8 +108,223 @@ func TestDevWriteErr(t *testing.T) {
 	}
 }
 
+func TestDevTxContext(t *testing.T) {
+	b := &fakeBus{r: []byte{1}}
+	d := Dev{b, 12}
+	r := make([]byte, 1)
+	if err := d.TxContext(context.Background(), []byte{3}, r); err != nil {
+		t.Fatal(err)
+	}
+	if r[0] != 1 || b.addr != 12 {
+		t.Fatal(r, b.addr)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.TxContext(ctx, []byte{4}, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.w, []byte{3}) {
+		t.Fatal(b.w)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	b := &fakeBus{}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := WithContext(ctx, b)
+	if s := c.(fmt.Stringer).String(); s != "fake" {
+		t.Fatal(s)
+	}
+	if err := c.SetSpeed(100); err != nil || b.speed != 100 {
+		t.Fatal(err, b.speed)
+	}
+	if err := c.Tx(12, []byte{3}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.(TxMessager).TxMessages([]Msg{{Addr: 12, W: []byte{5}}}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := c.Tx(12, []byte{4}, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := c.(TxMessager).TxMessages([]Msg{{Addr: 12, W: []byte{6}}}); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.w, []byte{3, 5}) {
+		t.Fatal(b.w)
+	}
+}
+
+func TestTxMessages(t *testing.T) {
+	b := &fakeBus{r: []byte{1, 2}}
+	r := make([]byte, 2)
+	if err := TxMessages(b, []Msg{{Addr: 12, W: []byte{3}}, {Addr: 12, R: r}}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, []byte{1, 2}) || !bytes.Equal(b.w, []byte{3}) {
+		t.Fatal(r, b.w)
+	}
+	if err := TxMessages(b, []Msg{{Addr: 12, W: []byte{4}}}); err != nil {
+		t.Fatal(err)
+	}
+	if TxMessages(b, nil) == nil {
+		t.Fatal("no message")
+	}
+	if TxMessages(b, []Msg{{Addr: 12, W: []byte{3}}, {Addr: 13, R: r}}) == nil {
+		t.Fatal("different addresses")
+	}
+	if TxMessages(b, []Msg{{Addr: 12, W: []byte{3}, R: r}}) == nil {
+		t.Fatal("both W and R")
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	f := &flakyBus{fails: 2}
+	b := WithRetry(f, nil)
+	if s := fmt.Sprint(b); s != "fake" {
+		t.Fatal(s)
+	}
+	if err := b.SetSpeed(100); err != nil || f.speed != 100 {
+		t.Fatal(err, f.speed)
+	}
+	if err := b.Tx(12, []byte{1}, nil); err != nil || f.calls != 3 {
+		t.Fatal(err, f.calls)
+	}
+	f.calls = 0
+	if err := TxMessages(b, []Msg{{Addr: 12, W: []byte{1}}}); err != nil || f.calls != 3 {
+		t.Fatal(err, f.calls)
+	}
+	f.calls = 0
+	if err := TxContext(context.Background(), b, 12, []byte{1}, nil); err != nil || f.calls != 3 {
+		t.Fatal(err, f.calls)
+	}
+	f.calls = 0
+	f.fails = 10
+	if err := WithRetry(f, &conn.Retry{Attempts: 5}).Tx(12, nil, nil); !conn.IsKind(err, conn.ErrTimeout) || f.calls != 5 {
+		t.Fatal(err, f.calls)
+	}
+}
+
+func TestScan(t *testing.T) {
+	b := &scanBus{present: map[uint16]bool{0x03: true, 0x10: true, 0x76: true, 0x78: true}}
+	w := &scanMsgBus{b}
+	if l, err := Scan(w, ProbeWrite); err != nil || !reflect.DeepEqual(l, []uint16{0x10, 0x76}) {
+		t.Fatalf("%#v %v", l, err)
+	}
+	if b.reads != 0 || b.writes != int(LastAddr-FirstAddr+1) {
+		t.Fatal(b.reads, b.writes)
+	}
+	b.writes = 0
+	if l, err := Scan(b, nil); err != nil || !reflect.DeepEqual(l, []uint16{0x10, 0x76}) {
+		t.Fatalf("%#v %v", l, err)
+	}
+	if b.writes != 0 || b.reads != int(LastAddr-FirstAddr+1) {
+		t.Fatal(b.reads, b.writes)
+	}
+}
+
+func TestScan_unsupported(t *testing.T) {
+	// Zero length writes cannot be done with Tx(), even through a wrapper.
+	b := &scanBus{present: map[uint16]bool{0x10: true}}
+	if err := ProbeWrite(WithContext(context.Background(), b), 0x10); !conn.IsKind(err, conn.ErrUnsupported) {
+		t.Fatal(err)
+	}
+	// The requested probe is not replaced by a read.
+	if l, err := Scan(WithRetry(b, nil), ProbeWrite); !conn.IsKind(err, conn.ErrUnsupported) || len(l) != 0 {
+		t.Fatalf("%#v %v", l, err)
+	}
+	if b.writes != 0 || b.reads != 0 {
+		t.Fatal(b.reads, b.writes)
+	}
+	// The absent devices are not retried.
+	if l, err := Scan(WithRetry(b, nil), ProbeRead); err != nil || !reflect.DeepEqual(l, []uint16{0x10}) {
+		t.Fatalf("%#v %v", l, err)
+	}
+	if b.writes != 0 || b.reads != int(LastAddr-FirstAddr+1) {
+		t.Fatal(b.reads, b.writes)
+	}
+}
+
+func TestScan_error(t *testing.T) {
+	b := &scanBus{present: map[uint16]bool{0x10: true, 0x30: true}, fail: 0x20}
+	l, err := Scan(b, nil)
+	if err == nil || !reflect.DeepEqual(l, []uint16{0x10}) {
+		t.Fatalf("%#v %v", l, err)
+	}
+}
+
+func TestIsReserved(t *testing.T) {
+	data := []struct {
+		addr     uint16
+		reserved bool
+	}{
+		{0x00, true}, {0x07, true}, {0x08, false}, {0x77, false}, {0x78, true}, {0x7F, true}, {0x80, false},
+	}
+	for i, line := range data {
+		if IsReserved(line.addr) != line.reserved {
+			t.Fatal(i, line.addr)
+		}
+	}
+}
+
 //
 
+// flakyBus fails the first fails transactions with a timeout.
+type flakyBus struct {
+	fakeBus
+	fails int
+	calls int
+}
+
+func (f *flakyBus) Tx(addr uint16, w, r []byte) error {
+	f.calls++
+	if f.calls <= f.fails {
+		return &conn.Error{Kind: conn.ErrTimeout, Msg: "flaky"}
+	}
+	return nil
+}
+
+// scanBus reports the addresses in present as acknowledged.
+type scanBus struct {
+	fakeBus
+	present map[uint16]bool
+	fail    uint16
+	reads   int
+	writes  int
+}
+
+func (s *scanBus) Tx(addr uint16, w, r []byte) error {
+	if len(w) != 0 || len(r) != 1 {
+		return errors.New("unexpected")
+	}
+	s.reads++
+	return s.probe(addr)
+}
+
+func (s *scanBus) probe(addr uint16) error {
+	if addr == s.fail {
+		return errors.New("bus fault")
+	}
+	if !s.present[addr] {
+		return &conn.Error{Kind: conn.ErrNotAcknowledged, Msg: "nack"}
+	}
+	return nil
+}
+
+// scanMsgBus adds native zero length writes to scanBus.
+type scanMsgBus struct {
+	*scanBus
+}
+
+func (s *scanMsgBus) TxMessages(msgs []Msg) error {
+	if len(msgs) != 1 || len(msgs[0].W) != 0 || len(msgs[0].R) != 0 {
+		return errors.New("unexpected")
+	}
+	s.writes++
+	return s.probe(msgs[0].Addr)
+}
+
 type fakeBus struct {
 	speed int64
 	err   error
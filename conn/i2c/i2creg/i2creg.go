@@ -58,9 +58,11 @@ type Ref struct {
 func Open(name string) (i2c.BusCloser, error) {
 	var r *Ref
 	var err error
+	var w Wrapper
 	func() {
 		mu.Lock()
 		defer mu.Unlock()
+		w = wrapper
 		if len(byName) == 0 {
 			err = wrapf("no bus found; did you forget to call Init()?")
 			return
@@ -84,7 +86,26 @@ func Open(name string) (i2c.BusCloser, error) {
 	if r == nil {
 		return nil, wrapf("can't open unknown bus: %q", name)
 	}
-	return r.Open()
+	h, err := r.Open()
+	if err != nil || w == nil {
+		return h, err
+	}
+	return w(r.Name, h), nil
+}
+
+// Wrapper wraps a bus handle returned by Open().
+//
+// name is the bus name, even if it was opened by an alias or its number.
+type Wrapper func(name string, h i2c.BusCloser) i2c.BusCloser
+
+// SetWrapper sets a function to wrap every bus handle returned by Open(), for
+// example to instrument all the buses transparently.
+//
+// Use nil to remove it. It doesn't affect the handles already opened.
+func SetWrapper(w Wrapper) {
+	mu.Lock()
+	defer mu.Unlock()
+	wrapper = w
 }
 
 // All returns a copy of all the registered references to all know I²C buses
@@ -206,6 +227,7 @@ var (
 	// Caches
 	byNumber = map[int]*Ref{}
 	byAlias  = map[string]*Ref{}
+	wrapper  Wrapper
 )
 
 // getDefault returns the Ref that should be used as the default bus.
@@ -84,6 +84,31 @@ func TestOpen(t *testing.T) {
 	}
 }
 
+func TestSetWrapper(t *testing.T) {
+	defer reset()
+	if err := Register("a", []string{"x"}, 1, fakeBuser); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	SetWrapper(func(name string, h i2c.BusCloser) i2c.BusCloser {
+		names = append(names, name)
+		return h
+	})
+	if o, err := Open("x"); o == nil || err != nil {
+		t.Fatal(o, err)
+	}
+	if o, err := Open("y"); o != nil || err == nil {
+		t.Fatal(o, err)
+	}
+	SetWrapper(nil)
+	if o, err := Open("1"); o == nil || err != nil {
+		t.Fatal(o, err)
+	}
+	if len(names) != 1 || names[0] != "a" {
+		t.Fatal(names)
+	}
+}
+
 func TestDefault_NoNumber(t *testing.T) {
 	defer reset()
 	if err := Register("a", nil, -1, fakeBuser); err != nil {
@@ -202,6 +227,7 @@ func reset() {
 	byName = map[string]*Ref{}
 	byNumber = map[int]*Ref{}
 	byAlias = map[string]*Ref{}
+	wrapper = nil
 }
 
 type fakeBus struct {
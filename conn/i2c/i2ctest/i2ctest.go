@@ -6,8 +6,10 @@
 package i2ctest
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"sync"
 
 	"periph.io/x/periph/conn/conntest"
@@ -20,6 +22,54 @@ type IO struct {
 	Addr uint16
 	W    []byte
 	R    []byte
+	// Restart is true when this I/O is a segment of a transaction done with
+	// TxMessages() other than the first one, that is, it is preceded by a
+	// repeated START condition.
+	Restart bool
+	// WMask, if not nil, selects the bits of W that are verified by Playback.
+	// A 0 byte is a don't-care byte. The bytes of W past the end of WMask are
+	// verified.
+	WMask []byte
+	// Repeat is the number of additional times the IO may be matched by
+	// Playback. Playback moves on to the next IO as soon as a transaction
+	// doesn't match the repeated one, so that a status register polled a
+	// variable number of times can be replayed.
+	//
+	// For a transaction done with TxMessages(), Repeat is taken from the first
+	// segment.
+	Repeat int
+}
+
+// MarshalJSON implements json.Marshaler.
+func (i IO) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ioJSON{Addr: i.Addr, W: i.W, R: i.R, Restart: i.Restart, WMask: i.WMask, Repeat: i.Repeat})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (i *IO) UnmarshalJSON(b []byte) error {
+	var j ioJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	*i = IO{Addr: j.Addr, W: j.W, R: j.R, Restart: j.Restart, WMask: j.WMask, Repeat: j.Repeat}
+	return nil
+}
+
+// Save writes ops as a trace to w.
+//
+// Use it to save the Ops of a Record as a golden file, to be loaded back with
+// Load into a Playback. See conntest.TraceVersion for the format.
+func Save(w io.Writer, ops []IO) error {
+	return conntest.WriteTrace(w, "i2c", len(ops), func(i int) interface{} { return ops[i] })
+}
+
+// Load reads a trace written by Save.
+func Load(r io.Reader) ([]IO, error) {
+	var ops []IO
+	if err := conntest.ReadTrace(r, "i2c", &ops); err != nil {
+		return nil, err
+	}
+	return ops, nil
 }
 
 // Record implements i2c.Bus that records everything written to it.
@@ -37,8 +87,23 @@ func (r *Record) String() string {
 	return "record"
 }
 
-// Tx implements i2c.Bus
+// Tx implements i2c.Bus.
 func (r *Record) Tx(addr uint16, w, read []byte) error {
+	return r.txInternal(context.Background(), addr, w, read)
+}
+
+// TxContext implements i2c.TxContexter.
+//
+// The context is forwarded to Bus. The I/O is not recorded if the transaction
+// fails, including when ctx is already done.
+func (r *Record) TxContext(ctx context.Context, addr uint16, w, read []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.txInternal(ctx, addr, w, read)
+}
+
+func (r *Record) txInternal(ctx context.Context, addr uint16, w, read []byte) error {
 	io := IO{Addr: addr}
 	if len(w) != 0 {
 		io.W = make([]byte, len(w))
@@ -51,7 +116,7 @@ func (r *Record) Tx(addr uint16, w, read []byte) error {
 			return conntest.Errorf("i2ctest: read unsupported when no bus is connected")
 		}
 	} else {
-		if err := r.Bus.Tx(addr, w, read); err != nil {
+		if err := i2c.TxContext(ctx, r.Bus, addr, w, read); err != nil {
 			return err
 		}
 	}
@@ -63,6 +128,38 @@ func (r *Record) Tx(addr uint16, w, read []byte) error {
 	return nil
 }
 
+// TxMessages implements i2c.TxMessager.
+//
+// Each segment is recorded as one IO.
+func (r *Record) TxMessages(msgs []i2c.Msg) error {
+	r.Lock()
+	defer r.Unlock()
+	if r.Bus == nil {
+		for i := range msgs {
+			if len(msgs[i].R) != 0 {
+				return conntest.Errorf("i2ctest: read unsupported when no bus is connected")
+			}
+		}
+	} else {
+		if err := i2c.TxMessages(r.Bus, msgs); err != nil {
+			return err
+		}
+	}
+	for i := range msgs {
+		io := IO{Addr: msgs[i].Addr, Restart: i != 0}
+		if len(msgs[i].W) != 0 {
+			io.W = make([]byte, len(msgs[i].W))
+			copy(io.W, msgs[i].W)
+		}
+		if len(msgs[i].R) != 0 {
+			io.R = make([]byte, len(msgs[i].R))
+			copy(io.R, msgs[i].R)
+		}
+		r.Ops = append(r.Ops, io)
+	}
+	return nil
+}
+
 // SetSpeed implements i2c.Bus.
 func (r *Record) SetSpeed(hz int64) error {
 	if r.Bus != nil {
@@ -101,6 +198,12 @@ type Playback struct {
 	DontPanic bool
 	SDAPin    gpio.PinIO
 	SCLPin    gpio.PinIO
+	// ByAddr keeps one queue of Ops per device address instead of a single
+	// one. The Ops of each address are still verified in order, but the
+	// transactions to different addresses can be interleaved in any order.
+	ByAddr bool
+
+	hits []int // number of times each of Ops has been matched
 }
 
 func (p *Playback) String() string {
@@ -113,6 +216,11 @@ func (p *Playback) String() string {
 func (p *Playback) Close() error {
 	p.Lock()
 	defer p.Unlock()
+	for i := range p.hits {
+		if p.hits[i] != 0 && !p.done(i) {
+			p.consume(i)
+		}
+	}
 	if len(p.Ops) != p.Count {
 		return errorf(p.DontPanic, "i2ctest: expected playback to be empty: I/O count %d; expected %d", p.Count, len(p.Ops))
 	}
@@ -123,23 +231,149 @@ func (p *Playback) Close() error {
 func (p *Playback) Tx(addr uint16, w, r []byte) error {
 	p.Lock()
 	defer p.Unlock()
-	if len(p.Ops) <= p.Count {
+	n := p.next(addr, []i2c.Msg{{Addr: addr, W: w, R: r}})
+	if n == -1 {
 		return errorf(p.DontPanic, "i2ctest: unexpected Tx() (count #%d) expecting i2ctest.IO{Addr:%d, W:%#v, R:%#v}", p.Count, addr, w, r)
 	}
-	if addr != p.Ops[p.Count].Addr {
-		return errorf(p.DontPanic, "i2ctest: unexpected addr (count #%d) %d != %d", p.Count, addr, p.Ops[p.Count].Addr)
+	if err := p.check(n, addr, w, r, false); err != nil {
+		return err
+	}
+	if n+1 < len(p.Ops) && p.Ops[n+1].Restart {
+		return errorf(p.DontPanic, "i2ctest: unexpected Tx() (count #%d) expecting TxMessages()", n)
+	}
+	copy(r, p.Ops[n].R)
+	p.hit(n)
+	return nil
+}
+
+// TxMessages implements i2c.TxMessager.
+//
+// Each segment is verified against one IO.
+func (p *Playback) TxMessages(msgs []i2c.Msg) error {
+	p.Lock()
+	defer p.Unlock()
+	n := p.Count
+	if len(msgs) != 0 {
+		n = p.next(msgs[0].Addr, msgs)
+	}
+	for i := range msgs {
+		if n == -1 || len(p.Ops) <= n+i {
+			return errorf(p.DontPanic, "i2ctest: unexpected TxMessages() (count #%d) expecting i2c.Msg{Addr:%d, W:%#v, R:%#v}", p.Count+i, msgs[i].Addr, msgs[i].W, msgs[i].R)
+		}
+		if err := p.check(n+i, msgs[i].Addr, msgs[i].W, msgs[i].R, i != 0); err != nil {
+			return err
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	if n+len(msgs) < len(p.Ops) && p.Ops[n+len(msgs)].Restart {
+		return errorf(p.DontPanic, "i2ctest: unexpected end of TxMessages() (count #%d)", n+len(msgs))
+	}
+	for i := range msgs {
+		copy(msgs[i].R, p.Ops[n+i].R)
+	}
+	p.hit(n)
+	return nil
+}
+
+// TxContext implements i2c.TxContexter.
+//
+// It returns ctx.Err() without consuming an Ops entry if ctx is done.
+func (p *Playback) TxContext(ctx context.Context, addr uint16, w, r []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.Tx(addr, w, r)
+}
+
+// next returns the index of the IO to verify the transaction msgs to addr
+// against, or -1 if there is none.
+//
+// A repeated IO that doesn't match msgs is consumed.
+func (p *Playback) next(addr uint16, msgs []i2c.Msg) int {
+	if len(p.hits) < len(p.Ops) {
+		p.hits = append(p.hits, make([]int, len(p.Ops)-len(p.hits))...)
+	}
+	for {
+		n := -1
+		if !p.ByAddr {
+			if p.Count < len(p.Ops) {
+				n = p.Count
+			}
+		} else {
+			for i := range p.Ops {
+				if p.Ops[i].Addr == addr && !p.Ops[i].Restart && !p.done(i) {
+					n = i
+					break
+				}
+			}
+		}
+		if n == -1 || p.hits[n] == 0 || p.matches(n, msgs) {
+			return n
+		}
+		// The repeated IO is done.
+		p.consume(n)
 	}
-	if !bytes.Equal(p.Ops[p.Count].W, w) {
-		return errorf(p.DontPanic, "i2ctest: unexpected write (count #%d) %#v != %#v", p.Count, w, p.Ops[p.Count].W)
+}
+
+// matches returns true if the transaction msgs matches the IO at index n.
+func (p *Playback) matches(n int, msgs []i2c.Msg) bool {
+	for i := range msgs {
+		if len(p.Ops) <= n+i || p.mismatch(n+i, msgs[i].Addr, msgs[i].W, msgs[i].R, i != 0) != "" {
+			return false
+		}
 	}
-	if len(p.Ops[p.Count].R) != len(r) {
-		return errorf(p.DontPanic, "i2ctest: unexpected read buffer length (count #%d) %d != %d", p.Count, len(r), len(p.Ops[p.Count].R))
+	return true
+}
+
+// check verifies the I/O against the IO at index n.
+func (p *Playback) check(n int, addr uint16, w, r []byte, restart bool) error {
+	if s := p.mismatch(n, addr, w, r, restart); s != "" {
+		return errorf(p.DontPanic, "%s", s)
 	}
-	copy(r, p.Ops[p.Count].R)
-	p.Count++
 	return nil
 }
 
+// mismatch returns a description of the difference between the I/O and the
+// IO at index n, or "" if they match.
+func (p *Playback) mismatch(n int, addr uint16, w, r []byte, restart bool) string {
+	if addr != p.Ops[n].Addr {
+		return fmt.Sprintf("i2ctest: unexpected addr (count #%d) %d != %d", n, addr, p.Ops[n].Addr)
+	}
+	if !conntest.EqualMask(w, p.Ops[n].W, p.Ops[n].WMask) {
+		return fmt.Sprintf("i2ctest: unexpected write (count #%d) %#v != %#v", n, w, p.Ops[n].W)
+	}
+	if len(p.Ops[n].R) != len(r) {
+		return fmt.Sprintf("i2ctest: unexpected read buffer length (count #%d) %d != %d", n, len(r), len(p.Ops[n].R))
+	}
+	if p.Ops[n].Restart != restart {
+		return fmt.Sprintf("i2ctest: unexpected repeated start (count #%d) %t != %t", n, restart, p.Ops[n].Restart)
+	}
+	return ""
+}
+
+// hit records that the transaction starting at IO index n was matched.
+func (p *Playback) hit(n int) {
+	if p.hits[n]++; p.hits[n] > p.Ops[n].Repeat {
+		p.consume(n)
+	}
+}
+
+// done returns true if the IO at index n has been consumed.
+func (p *Playback) done(n int) bool {
+	return p.hits[n] > p.Ops[n].Repeat
+}
+
+// consume marks the transaction starting at IO index n as consumed, including
+// its segments.
+func (p *Playback) consume(n int) {
+	for i := n; i < len(p.Ops) && (i == n || p.Ops[i].Restart); i++ {
+		p.hits[i] = p.Ops[i].Repeat + 1
+		p.Count++
+	}
+}
+
 // SetSpeed implements i2c.Bus.
 func (p *Playback) SetSpeed(hz int64) error {
 	return nil
@@ -155,8 +389,40 @@ func (p *Playback) SDA() gpio.PinIO {
 	return p.SDAPin
 }
 
+// Faulty implements i2c.Bus. It forwards the transactions to Bus and injects
+// faults.
+//
+// It is meant to exercise the error paths of device drivers.
+type Faulty struct {
+	Bus i2c.Bus
+	conntest.Faults
+}
+
+func (f *Faulty) String() string {
+	return fmt.Sprintf("faulty(%s)", f.Bus)
+}
+
+// Tx implements i2c.Bus.
+func (f *Faulty) Tx(addr uint16, w, r []byte) error {
+	return f.Inject(r, func() error { return f.Bus.Tx(addr, w, r) })
+}
+
+// SetSpeed implements i2c.Bus.
+func (f *Faulty) SetSpeed(hz int64) error {
+	return f.Bus.SetSpeed(hz)
+}
+
 //
 
+type ioJSON struct {
+	Addr    uint16       `json:"addr"`
+	W       conntest.Hex `json:"w,omitempty"`
+	R       conntest.Hex `json:"r,omitempty"`
+	Restart bool         `json:"restart,omitempty"`
+	WMask   conntest.Hex `json:"wmask,omitempty"`
+	Repeat  int          `json:"repeat,omitempty"`
+}
+
 // errorf is the internal implementation that optionally panic.
 //
 // If dontPanic is false, it panics instead.
@@ -171,6 +437,11 @@ func errorf(dontPanic bool, format string, a ...interface{}) error {
 var _ i2c.Bus = &Record{}
 var _ i2c.Pins = &Record{}
 var _ i2c.Bus = &Playback{}
+var _ i2c.TxContexter = &Record{}
+var _ i2c.TxContexter = &Playback{}
+var _ i2c.TxMessager = &Record{}
+var _ i2c.TxMessager = &Playback{}
 var _ i2c.Pins = &Playback{}
 var _ fmt.Stringer = &Record{}
 var _ fmt.Stringer = &Playback{}
+var _ i2c.Bus = &Faulty{}
@@ -5,11 +5,16 @@
 package i2ctest
 
 import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
 	"testing"
 
 	"periph.io/x/periph/conn/conntest"
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/i2c"
 )
 
 func TestRecord_empty(t *testing.T) {
@@ -129,6 +134,68 @@ func TestPlayback_Tx(t *testing.T) {
 	}
 }
 
+func TestRecord_Playback_TxContext(t *testing.T) {
+	p := &Playback{Ops: []IO{{Addr: 23, W: []byte{10}, R: []byte{12}}}}
+	r := Record{Bus: p}
+	ctx, cancel := context.WithCancel(context.Background())
+	v := [1]byte{}
+	if err := r.TxContext(ctx, 23, []byte{10}, v[:]); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := r.TxContext(ctx, 23, []byte{10}, v[:]); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := p.TxContext(ctx, 23, []byte{10}, v[:]); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if v[0] != 12 || len(r.Ops) != 1 {
+		t.Fatal(v, r.Ops)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecord_Playback_TxMessages(t *testing.T) {
+	p := &Playback{
+		Ops: []IO{
+			{Addr: 23, W: []byte{10}},
+			{Addr: 24, W: []byte{11}, Restart: true},
+			{Addr: 23, R: []byte{12}, Restart: true},
+			{Addr: 23, W: []byte{13}},
+		},
+		DontPanic: true,
+	}
+	r := Record{Bus: p}
+	v := [1]byte{}
+	msgs := []i2c.Msg{{Addr: 23, W: []byte{10}}, {Addr: 24, W: []byte{11}}, {Addr: 23, R: v[:]}}
+	if r.Tx(23, []byte{10}, nil) == nil {
+		t.Fatal("expecting TxMessages")
+	}
+	if r.TxMessages(msgs[:2]) == nil {
+		t.Fatal("missing segment")
+	}
+	if err := r.TxMessages(msgs); err != nil {
+		t.Fatal(err)
+	}
+	if v[0] != 12 {
+		t.Fatal(v)
+	}
+	if r.TxMessages([]i2c.Msg{{Addr: 23, W: []byte{13}}, {Addr: 23, W: []byte{14}}}) == nil {
+		t.Fatal("Ops is too short")
+	}
+	if err := r.Tx(23, []byte{13}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Ops) != 4 || !r.Ops[1].Restart || r.Ops[3].Restart {
+		t.Fatal(r.Ops)
+	}
+}
+
 func TestRecord_Playback(t *testing.T) {
 	r := Record{
 		Bus: &Playback{
@@ -165,3 +232,146 @@ func TestRecord_Playback(t *testing.T) {
 		t.Fatal("Playback.Ops is empty")
 	}
 }
+
+func TestFaulty(t *testing.T) {
+	p := &Playback{Ops: []IO{{Addr: 23, W: []byte{10}, R: []byte{12}}}}
+	f := &Faulty{Bus: p, Faults: conntest.Faults{ErrorRate: 1}}
+	if s := f.String(); s != "faulty(playback)" {
+		t.Fatal(s)
+	}
+	if err := f.SetSpeed(100); err != nil {
+		t.Fatal(err)
+	}
+	v := [1]byte{}
+	if err := f.Tx(23, []byte{10}, v[:]); err != conntest.ErrInjected {
+		t.Fatal(err)
+	}
+	// The failed transaction was not sent.
+	f.ErrorRate = 0
+	f.FlipRate = 1
+	if err := f.Tx(23, []byte{10}, v[:]); err != nil {
+		t.Fatal(err)
+	}
+	if v[0] == 12 {
+		t.Fatal("expected a bit flip")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSave_Load(t *testing.T) {
+	r := Record{
+		Bus: &Playback{
+			Ops: []IO{
+				{Addr: 0x76, W: []byte{0xd0}, R: []byte{0x60}},
+				{Addr: 0x76, W: []byte{0xf7}},
+				{Addr: 0x76, R: []byte{1, 2}, Restart: true},
+			},
+		},
+	}
+	v := [1]byte{}
+	if err := r.Tx(0x76, []byte{0xd0}, v[:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.TxMessages([]i2c.Msg{{Addr: 0x76, W: []byte{0xf7}}, {Addr: 0x76, R: make([]byte, 2)}}); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := Save(&b, r.Ops); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "{\"version\":1,\"kind\":\"i2c\",\"ops\":[\n" +
+		"{\"addr\":118,\"w\":\"d0\",\"r\":\"60\"},\n" +
+		"{\"addr\":118,\"w\":\"f7\"},\n" +
+		"{\"addr\":118,\"r\":\"0102\",\"restart\":true}\n" +
+		"]}\n"
+	if s := b.String(); s != expected {
+		t.Fatalf("%q", s)
+	}
+	ops, err := Load(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Ops, ops) {
+		t.Fatalf("%#v != %#v", r.Ops, ops)
+	}
+	if _, err := Load(strings.NewReader("{\"version\":1,\"kind\":\"spi\",\"ops\":[]}")); err == nil {
+		t.Fatal("expected kind mismatch")
+	}
+}
+
+func TestPlayback_WMask_Repeat(t *testing.T) {
+	p := Playback{
+		Ops: []IO{
+			// A command with a sequence number.
+			{Addr: 0x18, W: []byte{0xb4, 0x00}, WMask: []byte{0xFF, 0x00}},
+			// Status polling.
+			{Addr: 0x18, W: []byte{0xf0}, R: []byte{0x01}, Repeat: 2},
+			{Addr: 0x18, W: []byte{0xf0}, R: []byte{0x00}},
+			{Addr: 0x18, W: []byte{0xe1}, Repeat: 5},
+			{Addr: 0x18, R: []byte{0x02}, Restart: true},
+		},
+		DontPanic: true,
+	}
+	if err := p.Tx(0x18, []byte{0xb4, 0x42}, nil); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	v := [1]byte{1}
+	for ; v[0] != 0; n++ {
+		if err := p.Tx(0x18, []byte{0xf0}, v[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 4 {
+		t.Fatal(n)
+	}
+	msgs := []i2c.Msg{{Addr: 0x18, W: []byte{0xe1}}, {Addr: 0x18, R: v[:]}}
+	for i := 0; i < 2; i++ {
+		v[0] = 0
+		if err := p.TxMessages(msgs); err != nil || v[0] != 2 {
+			t.Fatal(err, v)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlayback_ByAddr(t *testing.T) {
+	p := Playback{
+		Ops: []IO{
+			{Addr: 0x76, W: []byte{0xd0}, R: []byte{0x60}},
+			{Addr: 0x76, W: []byte{0xf7}, R: []byte{0x01}},
+			{Addr: 0x18, W: []byte{0xf0}, R: []byte{0x18}},
+			{Addr: 0x18, W: []byte{0xe1}},
+			{Addr: 0x18, R: []byte{0x02}, Restart: true},
+		},
+		ByAddr:    true,
+		DontPanic: true,
+	}
+	v := [1]byte{}
+	if err := p.Tx(0x18, []byte{0xf0}, v[:]); err != nil || v[0] != 0x18 {
+		t.Fatal(err, v)
+	}
+	if err := p.Tx(0x76, []byte{0xd0}, v[:]); err != nil || v[0] != 0x60 {
+		t.Fatal(err, v)
+	}
+	if err := p.TxMessages([]i2c.Msg{{Addr: 0x18, W: []byte{0xe1}}, {Addr: 0x18, R: v[:]}}); err != nil || v[0] != 2 {
+		t.Fatal(err, v)
+	}
+	if p.Close() == nil {
+		t.Fatal("0x76 queue is not empty")
+	}
+	// Each address queue is still ordered.
+	if p.Tx(0x18, []byte{0xf0}, v[:]) == nil {
+		t.Fatal("0x18 queue is empty")
+	}
+	if err := p.Tx(0x76, []byte{0xf7}, v[:]); err != nil || v[0] != 1 {
+		t.Fatal(err, v)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package i2ctest
+
+import (
+	"fmt"
+	"sync"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/i2c"
+)
+
+// Device is a device model attached to a Sim.
+type Device interface {
+	// Tx is called for each I²C message addressed to the device.
+	//
+	// An access that is not allowed by the model must be reported with an
+	// error created with conntest.Errorf().
+	Tx(w, r []byte) error
+}
+
+// Sim implements i2c.BusCloser and simulates the devices attached to it.
+//
+// Unlike Playback, it is not bound to a fixed sequence of transactions, so a
+// driver can be exercised against a behavioral model of the device.
+//
+// A transaction to an address without device fails with a
+// conn.ErrNotAcknowledged error.
+//
+// Set DontPanic to true to return an error instead of panicking when a device
+// model reports an access that is not allowed, which is the default.
+type Sim struct {
+	sync.Mutex
+	Devices   map[uint16]Device
+	DontPanic bool
+}
+
+func (s *Sim) String() string {
+	return "sim"
+}
+
+// Close implements i2c.BusCloser.
+func (s *Sim) Close() error {
+	return nil
+}
+
+// Tx implements i2c.Bus.
+func (s *Sim) Tx(addr uint16, w, r []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.tx(addr, w, r)
+}
+
+// TxMessages implements i2c.TxMessager.
+//
+// The segments are sent to the device models in order without any other
+// transaction interleaved.
+func (s *Sim) TxMessages(msgs []i2c.Msg) error {
+	s.Lock()
+	defer s.Unlock()
+	for i := range msgs {
+		if err := s.tx(msgs[i].Addr, msgs[i].W, msgs[i].R); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetSpeed implements i2c.Bus.
+func (s *Sim) SetSpeed(hz int64) error {
+	return nil
+}
+
+// RegFlag describes the behavior of a register of Regs.
+type RegFlag uint8
+
+// Valid RegFlag values.
+const (
+	// ReadOnly reports a write to the register.
+	ReadOnly RegFlag = 1 << iota
+	// WriteOnly reports a read of the register.
+	WriteOnly
+	// ClearOnRead resets the register to 0 after it is read.
+	ClearOnRead
+	// Reserved reports any access to the register.
+	Reserved
+)
+
+// Regs implements Device as a register file.
+//
+// The first byte written in a message is the register address, the following
+// bytes are written to consecutive registers. A read starts at the last
+// register address and continues on consecutive registers.
+//
+// An access to a register past the end of Data is reported.
+type Regs struct {
+	// Data is the content of the registers.
+	Data []byte
+	// Flags are the registers that are not plain read-write registers.
+	Flags map[uint8]RegFlag
+	// NoIncrement keeps the register address fixed instead of incrementing it
+	// after each byte.
+	NoIncrement bool
+	// WritePairs makes the bytes written after the first register address
+	// alternate between a value and the next register address, as done by
+	// Bosch sensors.
+	WritePairs bool
+	// OnWrite, if set, is called after a register is written. It is called
+	// with the Sim locked and can modify Data.
+	OnWrite func(reg uint8, v byte) error
+	// OnRead, if set, is called before a register is read. It is called with
+	// the Sim locked and can modify Data.
+	OnRead func(reg uint8) error
+
+	ptr int
+}
+
+// Tx implements Device.
+func (d *Regs) Tx(w, r []byte) error {
+	if len(w) != 0 {
+		d.ptr = int(w[0])
+		w = w[1:]
+	}
+	for i := 0; i < len(w); i++ {
+		if err := d.write(w[i]); err != nil {
+			return err
+		}
+		if d.WritePairs {
+			if i++; i < len(w) {
+				d.ptr = int(w[i])
+			}
+		} else if !d.NoIncrement {
+			d.ptr++
+		}
+	}
+	for i := range r {
+		if err := d.read(&r[i]); err != nil {
+			return err
+		}
+		if !d.NoIncrement {
+			d.ptr++
+		}
+	}
+	return nil
+}
+
+//
+
+// tx sends a message to the device at addr.
+func (s *Sim) tx(addr uint16, w, r []byte) error {
+	d := s.Devices[addr]
+	if d == nil {
+		return &conn.Error{Kind: conn.ErrNotAcknowledged, Msg: fmt.Sprintf("i2ctest: no device at address 0x%02x", addr)}
+	}
+	err := d.Tx(w, r)
+	if conntest.IsErr(err) && !s.DontPanic {
+		panic(err)
+	}
+	return err
+}
+
+// check verifies that the access to the current register is allowed.
+func (d *Regs) check(op string, deny RegFlag) error {
+	if d.ptr >= len(d.Data) {
+		return conntest.Errorf("i2ctest: %s of register 0x%02x past the end of the register file", op, d.ptr)
+	}
+	if f := d.Flags[uint8(d.ptr)]; f&(deny|Reserved) != 0 {
+		return conntest.Errorf("i2ctest: %s of register 0x%02x is not allowed", op, d.ptr)
+	}
+	return nil
+}
+
+func (d *Regs) write(v byte) error {
+	if err := d.check("write", ReadOnly); err != nil {
+		return err
+	}
+	d.Data[d.ptr] = v
+	if d.OnWrite != nil {
+		return d.OnWrite(uint8(d.ptr), v)
+	}
+	return nil
+}
+
+func (d *Regs) read(v *byte) error {
+	if err := d.check("read", WriteOnly); err != nil {
+		return err
+	}
+	if d.OnRead != nil {
+		if err := d.OnRead(uint8(d.ptr)); err != nil {
+			return err
+		}
+	}
+	*v = d.Data[d.ptr]
+	if d.Flags[uint8(d.ptr)]&ClearOnRead != 0 {
+		d.Data[d.ptr] = 0
+	}
+	return nil
+}
+
+var _ i2c.BusCloser = &Sim{}
+var _ i2c.TxMessager = &Sim{}
+var _ Device = &Regs{}
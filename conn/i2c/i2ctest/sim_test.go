@@ -0,0 +1,108 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package i2ctest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/i2c"
+)
+
+func TestSim(t *testing.T) {
+	var written []byte
+	d := &Regs{
+		Data:  []byte{0x10, 0x11, 0x12, 0x13, 0x14},
+		Flags: map[uint8]RegFlag{0: ReadOnly, 2: ClearOnRead, 3: WriteOnly, 4: Reserved},
+		OnWrite: func(reg uint8, v byte) error {
+			written = append(written, reg, v)
+			return nil
+		},
+	}
+	s := Sim{Devices: map[uint16]Device{0x42: d}, DontPanic: true}
+	if str := s.String(); str != "sim" {
+		t.Fatal(str)
+	}
+	if err := s.SetSpeed(100000); err != nil {
+		t.Fatal(err)
+	}
+	v := make([]byte, 2)
+	if err := s.Tx(0x42, []byte{1}, v); err != nil || !bytes.Equal(v, []byte{0x11, 0x12}) {
+		t.Fatal(err, v)
+	}
+	// Auto-increment and clear-on-read.
+	if err := s.Tx(0x42, []byte{1, 0x21, 0x22}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TxMessages([]i2c.Msg{{Addr: 0x42, W: []byte{1}}, {Addr: 0x42, R: v}}); err != nil || !bytes.Equal(v, []byte{0x21, 0x22}) {
+		t.Fatal(err, v)
+	}
+	if err := s.Tx(0x42, []byte{2}, v[:1]); err != nil || v[0] != 0 {
+		t.Fatal(err, v)
+	}
+	if !bytes.Equal(written, []byte{1, 0x21, 2, 0x22}) {
+		t.Fatal(written)
+	}
+	// Accesses not allowed.
+	data := []struct {
+		w []byte
+		r []byte
+	}{
+		{[]byte{0, 1}, nil},
+		{[]byte{3}, v[:1]},
+		{[]byte{4}, v[:1]},
+		{[]byte{4, 1}, nil},
+		{[]byte{3, 1, 2}, nil},
+		{[]byte{5}, v[:1]},
+	}
+	for i, line := range data {
+		if err := s.Tx(0x42, line.w, line.r); !conntest.IsErr(err) {
+			t.Fatalf("#%d: %v", i, err)
+		}
+	}
+	if err := s.Tx(0x43, nil, v); !conn.IsKind(err, conn.ErrNotAcknowledged) {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSim_panic(t *testing.T) {
+	s := Sim{Devices: map[uint16]Device{0x42: &Regs{Data: []byte{0}, Flags: map[uint8]RegFlag{0: ReadOnly}}}}
+	defer func() {
+		if err, ok := recover().(error); !ok || !conntest.IsErr(err) {
+			t.Fatal(err)
+		}
+	}()
+	s.Tx(0x42, []byte{0, 1}, nil)
+	t.Fatal("shouldn't run")
+}
+
+func TestRegs_modes(t *testing.T) {
+	d := &Regs{Data: make([]byte, 4), NoIncrement: true}
+	if err := d.Tx([]byte{1, 2, 3}, nil); err != nil {
+		t.Fatal(err)
+	}
+	v := make([]byte, 2)
+	if err := d.Tx(nil, v); err != nil || !bytes.Equal(v, []byte{3, 3}) {
+		t.Fatal(err, v)
+	}
+	d = &Regs{Data: make([]byte, 4), WritePairs: true}
+	if err := d.Tx([]byte{3, 0x30, 0, 0x10, 2}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d.Data, []byte{0x10, 0, 0, 0x30}) {
+		t.Fatal(d.Data)
+	}
+	errHook := errors.New("hook")
+	d = &Regs{Data: make([]byte, 1), OnRead: func(reg uint8) error { return errHook }}
+	if err := d.Tx([]byte{0}, v[:1]); err != errHook {
+		t.Fatal(err)
+	}
+}
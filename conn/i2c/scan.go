@@ -0,0 +1,74 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package i2c
+
+import "periph.io/x/periph/conn"
+
+// Probe is a strategy to detect if a device is present at an address.
+//
+// It returns nil if a device acknowledged the address. ProbeWrite and
+// ProbeRead are the two strategies that can be done with plain I²C
+// transactions. smbus.ProbeQuick uses a SMBus quick command instead.
+type Probe func(b Bus, addr uint16) error
+
+// ProbeWrite probes an address with a zero length write.
+//
+// It is the fastest method but some devices, like write protected EEPROMs,
+// may interpret it as the start of a write. It requires a Bus implementing
+// TxMessager and returns an error of kind conn.ErrUnsupported otherwise; see
+// TxMessages().
+func ProbeWrite(b Bus, addr uint16) error {
+	return TxMessages(b, []Msg{{Addr: addr}})
+}
+
+// ProbeRead probes an address by reading one byte.
+//
+// It is the safest method but some write-only devices don't acknowledge it.
+func ProbeRead(b Bus, addr uint16) error {
+	var r [1]byte
+	return b.Tx(addr, nil, r[:])
+}
+
+// Range of the 7 bits addresses that are not reserved by the specification.
+const (
+	FirstAddr uint16 = 0x08
+	LastAddr  uint16 = 0x77
+)
+
+// IsReserved returns true if addr is a reserved 7 bits address.
+//
+// Addresses 0x00 to 0x07 are reserved for the general call, CBUS, other bus
+// formats and high speed mode. Addresses 0x78 to 0x7F are reserved for 10
+// bits addressing and device ID. See UM10204 section 3.1.12.
+func IsReserved(addr uint16) bool {
+	return addr < FirstAddr || (addr > LastAddr && addr < 0x80)
+}
+
+// Scan probes all the non reserved 7 bits addresses on b with p and returns
+// the ones that acknowledged.
+//
+// If p is nil, ProbeRead is used. Another probe is never substituted for p,
+// as reading a byte is not free of side effects on every device: if p fails
+// with an error of kind conn.ErrUnsupported, like ProbeWrite on a Bus that
+// cannot do zero length writes, the error is returned.
+//
+// An address is deemed absent only when p fails with an error of kind
+// conn.ErrNotAcknowledged. Any other error stops the scan; it is returned
+// along with the addresses found so far.
+func Scan(b Bus, p Probe) ([]uint16, error) {
+	if p == nil {
+		p = ProbeRead
+	}
+	var out []uint16
+	for addr := FirstAddr; addr <= LastAddr; addr++ {
+		err := p(b, addr)
+		if err == nil {
+			out = append(out, addr)
+		} else if !conn.IsKind(err, conn.ErrNotAcknowledged) {
+			return out, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,427 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package smbus implements the System Management Bus protocol on top of an
+// I²C bus.
+//
+// SMBus is a subset of I²C with well defined transaction types and an
+// optional Packet Error Code (PEC) to detect transmission errors. Dev
+// implements all the transaction types over any i2c.Bus. When the bus
+// implements Bus, the transactions are delegated to it instead; this is
+// needed for SMBus-only adapters that cannot do arbitrary I²C transactions.
+//
+// Specification
+//
+// http://smbus.org/specs/SMBus_3_0_20141220.pdf
+package smbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"periph.io/x/periph/conn/i2c"
+)
+
+// MaxBlockSize is the maximum number of bytes that can be sent or received in
+// a single block transaction.
+const MaxBlockSize = 32
+
+// Protocol is one of the SMBus transaction types.
+type Protocol uint8
+
+// Transaction types as defined in section 6.5 of the specification.
+const (
+	// Quick sends only the read/write bit. It is section 6.5.1.
+	Quick Protocol = iota
+	// Byte sends or receives a single byte without a command code. It is
+	// section 6.5.2 and 6.5.3.
+	Byte
+	// ByteData writes or reads a byte at a command code. It is section 6.5.4
+	// and 6.5.5.
+	ByteData
+	// WordData writes or reads a 16 bits little endian word at a command code.
+	// It is section 6.5.4 and 6.5.5.
+	WordData
+	// ProcCall writes a word and reads back a word. It is section 6.5.6.
+	ProcCall
+	// BlockData writes or reads a block of up to MaxBlockSize bytes prefixed
+	// with its length. It is section 6.5.7.
+	BlockData
+	// BlockProcCall writes a block and reads back a block. It is section
+	// 6.5.8.
+	BlockProcCall
+	// I2CBlockData is not part of the specification but is commonly supported.
+	// It writes or reads a block at a command code without the length prefix.
+	I2CBlockData
+)
+
+const protocolName = "QuickByteByteDataWordDataProcCallBlockDataBlockProcCallI2CBlockData"
+
+var protocolIndex = [...]uint8{0, 5, 9, 17, 25, 33, 42, 55, 67}
+
+func (p Protocol) String() string {
+	if p >= Protocol(len(protocolIndex)-1) {
+		return fmt.Sprintf("Protocol(%d)", p)
+	}
+	return protocolName[protocolIndex[p]:protocolIndex[p+1]]
+}
+
+// Bus is implemented by an i2c.Bus that natively supports SMBus
+// transactions.
+//
+// Users should use Dev instead of calling SMBusTx() directly.
+type Bus interface {
+	i2c.Bus
+	// SMBusTx does a single SMBus transaction p with the device at addr.
+	//
+	// read selects the direction, for Quick it is the bit sent. cmd is the
+	// command code, it is ignored for Quick and Byte.
+	//
+	// data is used both as input and output and depends on p:
+	//
+	// - Quick: unused.
+	//
+	// - Byte, ByteData: data[0] is the byte sent or received.
+	//
+	// - WordData, ProcCall: data[0:2] is the little endian word sent or
+	// received. ProcCall replaces the word sent with the reply.
+	//
+	// - BlockData, BlockProcCall, I2CBlockData: data[0] is the number of bytes
+	// following it. On write, data[1:1+data[0]] is sent. On read, data[0] is
+	// updated with the number of bytes received, which must fit in data. For
+	// I2CBlockData reads, data[0] is the number of bytes to read.
+	//
+	// pec requests that a Packet Error Code is appended and verified.
+	SMBusTx(addr uint16, read bool, cmd byte, p Protocol, data []byte, pec bool) error
+}
+
+// Dev is a SMBus device on an I²C bus.
+//
+// It saves from repeatedly specifying the device address.
+type Dev struct {
+	Bus  i2c.Bus
+	Addr uint16
+	// PEC enables the Packet Error Code on each transaction. It is ignored for
+	// Quick and I2CBlockData.
+	PEC bool
+}
+
+func (d *Dev) String() string {
+	return fmt.Sprintf("%s(%d)", d.Bus, d.Addr)
+}
+
+// QuickCommand sends the read/write bit without any data.
+//
+// Reading is only supported when Bus implements smbus.Bus. Writing requires
+// Bus to implement either smbus.Bus or i2c.TxMessager.
+func (d *Dev) QuickCommand(read bool) error {
+	return d.tx(read, 0, Quick, nil)
+}
+
+// SendByte sends a single byte without command code.
+func (d *Dev) SendByte(b byte) error {
+	return d.tx(false, 0, Byte, []byte{b})
+}
+
+// ReceiveByte receives a single byte without command code.
+func (d *Dev) ReceiveByte() (byte, error) {
+	var data [1]byte
+	err := d.tx(true, 0, Byte, data[:])
+	return data[0], err
+}
+
+// WriteByteData writes a byte at the command code cmd.
+func (d *Dev) WriteByteData(cmd, b byte) error {
+	return d.tx(false, cmd, ByteData, []byte{b})
+}
+
+// ReadByteData reads a byte at the command code cmd.
+func (d *Dev) ReadByteData(cmd byte) (byte, error) {
+	var data [1]byte
+	err := d.tx(true, cmd, ByteData, data[:])
+	return data[0], err
+}
+
+// WriteWordData writes a 16 bits word at the command code cmd.
+func (d *Dev) WriteWordData(cmd byte, w uint16) error {
+	var data [2]byte
+	binary.LittleEndian.PutUint16(data[:], w)
+	return d.tx(false, cmd, WordData, data[:])
+}
+
+// ReadWordData reads a 16 bits word at the command code cmd.
+func (d *Dev) ReadWordData(cmd byte) (uint16, error) {
+	var data [2]byte
+	err := d.tx(true, cmd, WordData, data[:])
+	return binary.LittleEndian.Uint16(data[:]), err
+}
+
+// ProcessCall writes the 16 bits word w at the command code cmd and returns
+// the word sent back by the device.
+func (d *Dev) ProcessCall(cmd byte, w uint16) (uint16, error) {
+	var data [2]byte
+	binary.LittleEndian.PutUint16(data[:], w)
+	err := d.tx(false, cmd, ProcCall, data[:])
+	return binary.LittleEndian.Uint16(data[:]), err
+}
+
+// WriteBlockData writes up to MaxBlockSize bytes at the command code cmd.
+func (d *Dev) WriteBlockData(cmd byte, b []byte) error {
+	data, err := blockData(b, 0)
+	if err != nil {
+		return err
+	}
+	return d.tx(false, cmd, BlockData, data)
+}
+
+// ReadBlockData reads a block at the command code cmd into b.
+//
+// It returns the number of bytes sent by the device. It is an error if the
+// device sends more than len(b) bytes.
+func (d *Dev) ReadBlockData(cmd byte, b []byte) (int, error) {
+	if len(b) == 0 || len(b) > MaxBlockSize {
+		return 0, fmt.Errorf("smbus: invalid block size %d", len(b))
+	}
+	data := make([]byte, 1+len(b))
+	if err := d.tx(true, cmd, BlockData, data); err != nil {
+		return 0, err
+	}
+	// The length is reported by the device, or by a native SMBusTx.
+	if int(data[0]) > len(b) {
+		return 0, fmt.Errorf("smbus: device sent %d bytes, more than %d", data[0], len(b))
+	}
+	return copy(b, data[1:1+data[0]]), nil
+}
+
+// BlockProcessCall writes w at the command code cmd and reads the block sent
+// back by the device into r.
+//
+// It returns the number of bytes sent back by the device. It is an error if
+// the device sends more than len(r) bytes.
+func (d *Dev) BlockProcessCall(cmd byte, w, r []byte) (int, error) {
+	if len(r) == 0 || len(r) > MaxBlockSize {
+		return 0, fmt.Errorf("smbus: invalid block size %d", len(r))
+	}
+	data, err := blockData(w, len(r))
+	if err != nil {
+		return 0, err
+	}
+	if err := d.tx(false, cmd, BlockProcCall, data); err != nil {
+		return 0, err
+	}
+	// data is sized for the largest of w and r.
+	if int(data[0]) > len(r) {
+		return 0, fmt.Errorf("smbus: device sent %d bytes, more than %d", data[0], len(r))
+	}
+	return copy(r, data[1:1+data[0]]), nil
+}
+
+// WriteI2CBlockData writes up to MaxBlockSize bytes at the command code cmd
+// without the length prefix.
+func (d *Dev) WriteI2CBlockData(cmd byte, b []byte) error {
+	data, err := blockData(b, 0)
+	if err != nil {
+		return err
+	}
+	return d.tx(false, cmd, I2CBlockData, data)
+}
+
+// ReadI2CBlockData reads len(b) bytes at the command code cmd without the
+// length prefix.
+func (d *Dev) ReadI2CBlockData(cmd byte, b []byte) error {
+	data, err := blockData(b, 0)
+	if err != nil {
+		return err
+	}
+	if err := d.tx(true, cmd, I2CBlockData, data); err != nil {
+		return err
+	}
+	copy(b, data[1:])
+	return nil
+}
+
+// ProbeQuick implements i2c.Probe by sending a SMBus quick write command.
+//
+// This is the default method used by the i2cdetect tool.
+func ProbeQuick(b i2c.Bus, addr uint16) error {
+	d := Dev{Bus: b, Addr: addr}
+	return d.QuickCommand(false)
+}
+
+// PEC calculates the SMBus Packet Error Code of b.
+//
+// It is the CRC-8 with polynomial x⁸+x²+x+1 of all the bytes of the
+// transaction, including the address bytes.
+func PEC(b []byte) byte {
+	var crc byte
+	for _, v := range b {
+		crc ^= v
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+//
+
+// tx runs the transaction on the native implementation if available,
+// otherwise emulates it with plain I²C transactions.
+func (d *Dev) tx(read bool, cmd byte, p Protocol, data []byte) error {
+	if b, ok := d.Bus.(Bus); ok {
+		return b.SMBusTx(d.Addr, read, cmd, p, data, d.PEC)
+	}
+	return Emulate(d.Bus, d.Addr, read, cmd, p, data, d.PEC)
+}
+
+// Emulate does a single SMBus transaction with plain I²C transactions.
+//
+// The arguments are the same as Bus.SMBusTx(). Reading with Quick is not
+// supported.
+//
+// It is meant to be used by implementations of Bus that can do arbitrary I²C
+// transactions but natively support only some of the transaction types.
+func Emulate(b i2c.Bus, addr uint16, read bool, cmd byte, p Protocol, data []byte, pec bool) error {
+	aw := byte(addr << 1)
+	ar := aw | 1
+	switch p {
+	case Quick:
+		if read {
+			return errors.New("smbus: quick read is not supported over I²C")
+		}
+		return i2c.TxMessages(b, []i2c.Msg{{Addr: addr}})
+	case Byte:
+		if len(data) != 1 {
+			return fmt.Errorf("smbus: %s requires 1 byte, got %d", p, len(data))
+		}
+		if read {
+			return readPEC(b, addr, nil, data, pec, []byte{ar})
+		}
+		return writePEC(b, addr, data, pec, aw)
+	case ByteData, WordData:
+		l := 1
+		if p == WordData {
+			l = 2
+		}
+		if len(data) != l {
+			return fmt.Errorf("smbus: %s requires %d bytes, got %d", p, l, len(data))
+		}
+		if read {
+			return readPEC(b, addr, []byte{cmd}, data, pec, []byte{aw, cmd, ar})
+		}
+		return writePEC(b, addr, append([]byte{cmd}, data...), pec, aw)
+	case ProcCall:
+		if len(data) != 2 {
+			return fmt.Errorf("smbus: %s requires 2 bytes, got %d", p, len(data))
+		}
+		w := []byte{cmd, data[0], data[1]}
+		return readPEC(b, addr, w, data, pec, []byte{aw, cmd, data[0], data[1], ar})
+	case BlockData, BlockProcCall:
+		if err := checkBlock(data, p == BlockData && read); err != nil {
+			return err
+		}
+		w := []byte{cmd}
+		if p == BlockProcCall || !read {
+			w = append(w, data[:1+data[0]]...)
+		}
+		if p == BlockData && !read {
+			return writePEC(b, addr, w, pec, aw)
+		}
+		return readBlockPEC(b, addr, w, data, pec, append(append([]byte{aw}, w...), ar))
+	case I2CBlockData:
+		if err := checkBlock(data, false); err != nil {
+			return err
+		}
+		if read {
+			return b.Tx(addr, []byte{cmd}, data[1:1+data[0]])
+		}
+		return b.Tx(addr, append([]byte{cmd}, data[1:1+data[0]]...), nil)
+	default:
+		return fmt.Errorf("smbus: invalid protocol %s", p)
+	}
+}
+
+// blockData returns b prefixed with its length, in a buffer large enough to
+// hold a reply of max bytes.
+func blockData(b []byte, max int) ([]byte, error) {
+	if len(b) == 0 || len(b) > MaxBlockSize {
+		return nil, fmt.Errorf("smbus: invalid block size %d", len(b))
+	}
+	if max < len(b) {
+		max = len(b)
+	}
+	data := make([]byte, 1+max)
+	data[0] = byte(len(b))
+	copy(data[1:], b)
+	return data, nil
+}
+
+// checkBlock verifies the block buffer layout.
+//
+// When sizeOnly is true, data[0] is ignored as it is only an output.
+func checkBlock(data []byte, sizeOnly bool) error {
+	if len(data) < 2 || len(data) > MaxBlockSize+1 {
+		return fmt.Errorf("smbus: invalid block buffer size %d", len(data))
+	}
+	if !sizeOnly && (data[0] == 0 || int(data[0]) >= len(data)) {
+		return fmt.Errorf("smbus: invalid block length %d", data[0])
+	}
+	return nil
+}
+
+// writePEC writes w, appending the PEC if requested.
+func writePEC(b i2c.Bus, addr uint16, w []byte, pec bool, aw byte) error {
+	if pec {
+		w = append(w, PEC(append([]byte{aw}, w...)))
+	}
+	return b.Tx(addr, w, nil)
+}
+
+// readPEC writes w then reads into r, verifying the PEC if requested.
+//
+// hdr is the bytes preceding r on the wire, including the address bytes.
+func readPEC(b i2c.Bus, addr uint16, w, r []byte, pec bool, hdr []byte) error {
+	if !pec {
+		return b.Tx(addr, w, r)
+	}
+	rr := make([]byte, len(r)+1)
+	if err := b.Tx(addr, w, rr); err != nil {
+		return err
+	}
+	if c := PEC(append(hdr, rr[:len(r)]...)); c != rr[len(r)] {
+		return fmt.Errorf("smbus: invalid PEC 0x%02x; expected 0x%02x", rr[len(r)], c)
+	}
+	copy(r, rr)
+	return nil
+}
+
+// readBlockPEC writes w then reads a length prefixed block into data,
+// verifying the PEC if requested.
+//
+// Since the length isn't known in advance, len(data) bytes are read.
+func readBlockPEC(b i2c.Bus, addr uint16, w, data []byte, pec bool, hdr []byte) error {
+	r := make([]byte, len(data)+1)
+	if !pec {
+		r = r[:len(data)]
+	}
+	if err := b.Tx(addr, w, r); err != nil {
+		return err
+	}
+	n := int(r[0])
+	if n == 0 || n >= len(data) {
+		return fmt.Errorf("smbus: device sent invalid block length %d", n)
+	}
+	if pec {
+		if c := PEC(append(hdr, r[:1+n]...)); c != r[1+n] {
+			return fmt.Errorf("smbus: invalid PEC 0x%02x; expected 0x%02x", r[1+n], c)
+		}
+	}
+	copy(data, r[:1+n])
+	return nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+)
+
+func ExampleDev() {
+	//b, err := i2creg.Open("")
+	//defer b.Close()
+	var b i2c.Bus
+
+	// Read the battery voltage of a smart battery.
+	d := &Dev{Bus: b, Addr: 0x0B, PEC: true}
+	v, err := d.ReadWordData(0x09)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%dmV", v)
+}
+
+//
+
+func TestPEC(t *testing.T) {
+	// Standard check value for CRC-8/SMBUS.
+	if c := PEC([]byte("123456789")); c != 0xF4 {
+		t.Fatalf("0x%02x", c)
+	}
+}
+
+func TestProtocol_String(t *testing.T) {
+	if s := I2CBlockData.String(); s != "I2CBlockData" {
+		t.Fatal(s)
+	}
+	if s := Protocol(10).String(); s != "Protocol(10)" {
+		t.Fatal(s)
+	}
+}
+
+func TestDev_emulated(t *testing.T) {
+	p := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x10},
+			{Addr: 0x10, W: []byte{0x42}},
+			{Addr: 0x10, R: []byte{0x43}},
+			{Addr: 0x10, W: []byte{0x01, 0x44}},
+			{Addr: 0x10, W: []byte{0x02}, R: []byte{0x45}},
+			{Addr: 0x10, W: []byte{0x03, 0x34, 0x12}},
+			{Addr: 0x10, W: []byte{0x04}, R: []byte{0x78, 0x56}},
+			{Addr: 0x10, W: []byte{0x05, 0x01, 0x00}, R: []byte{0x02, 0x00}},
+			{Addr: 0x10, W: []byte{0x06, 0x02, 0xAA, 0xBB}},
+			{Addr: 0x10, W: []byte{0x07}, R: []byte{0x02, 0xCC, 0xDD, 0xFF}},
+			{Addr: 0x10, W: []byte{0x08, 0x01, 0xEE}, R: []byte{0x01, 0x11, 0xFF}},
+			{Addr: 0x10, W: []byte{0x09, 0x22, 0x33}},
+			{Addr: 0x10, W: []byte{0x0A}, R: []byte{0x44, 0x55}},
+		},
+	}
+	d := Dev{Bus: p, Addr: 0x10}
+	if s := d.String(); s != "playback(16)" {
+		t.Fatal(s)
+	}
+	if err := d.QuickCommand(false); err != nil {
+		t.Fatal(err)
+	}
+	if d.QuickCommand(true) == nil {
+		t.Fatal("quick read is not supported")
+	}
+	if err := d.SendByte(0x42); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.ReceiveByte(); err != nil || v != 0x43 {
+		t.Fatal(v, err)
+	}
+	if err := d.WriteByteData(0x01, 0x44); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.ReadByteData(0x02); err != nil || v != 0x45 {
+		t.Fatal(v, err)
+	}
+	if err := d.WriteWordData(0x03, 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.ReadWordData(0x04); err != nil || v != 0x5678 {
+		t.Fatal(v, err)
+	}
+	if v, err := d.ProcessCall(0x05, 1); err != nil || v != 2 {
+		t.Fatal(v, err)
+	}
+	if err := d.WriteBlockData(0x06, []byte{0xAA, 0xBB}); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 3)
+	if n, err := d.ReadBlockData(0x07, b); err != nil || n != 2 || !bytes.Equal(b[:n], []byte{0xCC, 0xDD}) {
+		t.Fatal(n, err, b)
+	}
+	if n, err := d.BlockProcessCall(0x08, []byte{0xEE}, b[:2]); err != nil || n != 1 || b[0] != 0x11 {
+		t.Fatal(n, err, b)
+	}
+	if err := d.WriteI2CBlockData(0x09, []byte{0x22, 0x33}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ReadI2CBlockData(0x0A, b[:2]); err != nil || !bytes.Equal(b[:2], []byte{0x44, 0x55}) {
+		t.Fatal(err, b)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDev_emulated_PEC(t *testing.T) {
+	const aw, ar = 0x10 << 1, 0x10<<1 | 1
+	p := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x10, W: []byte{0x01, 0x44, PEC([]byte{aw, 0x01, 0x44})}},
+			{Addr: 0x10, W: []byte{0x02}, R: []byte{0x45, PEC([]byte{aw, 0x02, ar, 0x45})}},
+			{Addr: 0x10, W: []byte{0x02}, R: []byte{0x45, 0}},
+			{Addr: 0x10, W: []byte{0x07}, R: []byte{0x01, 0xCC, PEC([]byte{aw, 0x07, ar, 0x01, 0xCC}), 0xFF}},
+		},
+		DontPanic: true,
+	}
+	d := Dev{Bus: p, Addr: 0x10, PEC: true}
+	if err := d.WriteByteData(0x01, 0x44); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.ReadByteData(0x02); err != nil || v != 0x45 {
+		t.Fatal(v, err)
+	}
+	if _, err := d.ReadByteData(0x02); err == nil {
+		t.Fatal("invalid PEC")
+	}
+	b := make([]byte, 2)
+	if n, err := d.ReadBlockData(0x07, b); err != nil || n != 1 || b[0] != 0xCC {
+		t.Fatal(n, err, b)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDev_invalid(t *testing.T) {
+	d := Dev{Bus: &i2ctest.Playback{DontPanic: true}, Addr: 0x10}
+	if d.WriteBlockData(0, nil) == nil {
+		t.Fatal("empty block")
+	}
+	if d.WriteBlockData(0, make([]byte, MaxBlockSize+1)) == nil {
+		t.Fatal("block too large")
+	}
+	if _, err := d.ReadBlockData(0, nil); err == nil {
+		t.Fatal("empty block")
+	}
+	if _, err := d.BlockProcessCall(0, []byte{1}, nil); err == nil {
+		t.Fatal("empty block")
+	}
+	// The reply fits in the buffer sized for w but not in r.
+	p := &i2ctest.Playback{
+		Ops: []i2ctest.IO{{Addr: 0x10, W: []byte{0x08, 0x03, 1, 2, 3}, R: []byte{0x02, 0x11, 0x22, 0xFF}}},
+	}
+	r := make([]byte, 1)
+	if n, err := (&Dev{Bus: p, Addr: 0x10}).BlockProcessCall(0x08, []byte{1, 2, 3}, r); err == nil || n != 0 || r[0] != 0 {
+		t.Fatal("reply too large", n, err, r)
+	}
+	if Emulate(d.Bus, 0x10, false, 0, Protocol(10), nil, false) == nil {
+		t.Fatal("invalid protocol")
+	}
+	if Emulate(d.Bus, 0x10, false, 0, WordData, []byte{1}, false) == nil {
+		t.Fatal("invalid word")
+	}
+	if Emulate(d.Bus, 0x10, false, 0, BlockData, []byte{5, 1}, false) == nil {
+		t.Fatal("invalid block length")
+	}
+}
+
+func TestDev_native(t *testing.T) {
+	b := &nativeBus{}
+	d := Dev{Bus: b, Addr: 0x10, PEC: true}
+	if v, err := d.ReadWordData(0x04); err != nil || v != 0x0201 {
+		t.Fatal(v, err)
+	}
+	if b.p != WordData || !b.read || b.cmd != 0x04 || !b.pec {
+		t.Fatal(b)
+	}
+	if err := d.QuickCommand(true); err != nil {
+		t.Fatal(err)
+	}
+	b.length = MaxBlockSize
+	if n, err := d.ReadBlockData(0x07, make([]byte, 2)); err == nil || n != 0 {
+		t.Fatal("block too large", n, err)
+	}
+}
+
+func TestProbeQuick(t *testing.T) {
+	b := &nativeBus{}
+	if err := ProbeQuick(b, 0x20); err != nil {
+		t.Fatal(err)
+	}
+	if b.p != Quick || b.read {
+		t.Fatal(b)
+	}
+}
+
+//
+
+type nativeBus struct {
+	i2ctest.Playback
+	read bool
+	cmd  byte
+	p    Protocol
+	pec  bool
+	// length, if not 0, is the block length returned.
+	length byte
+}
+
+func (n *nativeBus) SMBusTx(addr uint16, read bool, cmd byte, p Protocol, data []byte, pec bool) error {
+	n.read = read
+	n.cmd = cmd
+	n.p = p
+	n.pec = pec
+	for i := range data {
+		data[i] = byte(i + 1)
+	}
+	if n.length != 0 && len(data) != 0 {
+		data[0] = n.length
+	}
+	return nil
+}
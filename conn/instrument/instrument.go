@@ -0,0 +1,571 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package instrument observes the traffic on buses.
+//
+// A Monitor wraps connections and buses to count the transactions, the bytes
+// transferred and the errors, to record a latency histogram and optionally
+// to write a human readable trace of each transaction.
+//
+// Use Install() to instrument all the I²C buses and SPI ports opened via
+// i2creg.Open() and spireg.Open() without modifying the application.
+package instrument
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/smbus"
+	"periph.io/x/periph/conn/onewire"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spireg"
+)
+
+// LatencyBuckets are the upper bounds of the buckets of Stats.Latency.
+var LatencyBuckets = [...]time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// Stats is the metrics collected for one bus.
+type Stats struct {
+	Tx      int64 // Number of transactions
+	Errors  int64 // Number of transactions that failed
+	Written int64 // Number of bytes written
+	Read    int64 // Number of bytes read
+	// Latency is the latency histogram. Latency[i] is the number of
+	// transactions that took less than LatencyBuckets[i]; the last item is the
+	// number of transactions that took longer than all the buckets.
+	Latency [len(LatencyBuckets) + 1]int64
+	Total   time.Duration // Cumulative duration of all the transactions
+	Max     time.Duration // Duration of the slowest transaction
+}
+
+func (s Stats) String() string {
+	var avg time.Duration
+	if s.Tx != 0 {
+		avg = s.Total / time.Duration(s.Tx)
+	}
+	return fmt.Sprintf("%d Tx, %d errors, %d bytes written, %d bytes read, avg %s, max %s", s.Tx, s.Errors, s.Written, s.Read, avg, s.Max)
+}
+
+// Monitor collects the metrics of the buses it wraps.
+//
+// A Monitor is safe to use concurrently. Each bus is identified by a name;
+// buses wrapped with the same name share the same Stats.
+type Monitor struct {
+	// Trace, if not nil, receives one line per transaction.
+	//
+	// The lines are written one at a time but without holding the lock
+	// protecting the metrics, so a slow writer doesn't block Stats().
+	Trace io.Writer
+
+	mu      sync.Mutex
+	stats   map[string]*Stats
+	traceMu sync.Mutex // Serializes the writes to Trace
+}
+
+// Stats returns a copy of the metrics collected for the bus name.
+func (m *Monitor) Stats(name string) Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s := m.stats[name]; s != nil {
+		return *s
+	}
+	return Stats{}
+}
+
+// Names returns the sorted names of the buses that had transactions.
+func (m *Monitor) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]string, 0, len(m.stats))
+	for n := range m.stats {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Reset clears all the metrics.
+func (m *Monitor) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats = nil
+}
+
+// Conn returns an instrumented c.
+func (m *Monitor) Conn(name string, c conn.Conn) conn.Conn {
+	return &instConn{m: m, name: name, c: c}
+}
+
+// I2C returns an instrumented b.
+//
+// The returned bus implements i2c.TxContexter. It implements i2c.TxMessager,
+// smbus.Bus and i2c.Pins only if b does, so that the users selecting their
+// code path based on these interfaces behave the same as with b.
+func (m *Monitor) I2C(name string, b i2c.BusCloser) i2c.BusCloser {
+	i := &instI2C{m: m, name: name, b: b}
+	x, y := instI2CMessager{i}, instI2CSMBus{i}
+	k := 0
+	if _, ok := b.(i2c.TxMessager); ok {
+		k |= 1
+	}
+	if _, ok := b.(smbus.Bus); ok {
+		k |= 2
+	}
+	p, ok := b.(i2c.Pins)
+	if ok {
+		k |= 4
+	}
+	switch k {
+	case 1:
+		return &struct {
+			*instI2C
+			instI2CMessager
+		}{i, x}
+	case 2:
+		return &struct {
+			*instI2C
+			instI2CSMBus
+		}{i, y}
+	case 3:
+		return &struct {
+			*instI2C
+			instI2CMessager
+			instI2CSMBus
+		}{i, x, y}
+	case 4:
+		return &struct {
+			*instI2C
+			i2c.Pins
+		}{i, p}
+	case 5:
+		return &struct {
+			*instI2C
+			instI2CMessager
+			i2c.Pins
+		}{i, x, p}
+	case 6:
+		return &struct {
+			*instI2C
+			instI2CSMBus
+			i2c.Pins
+		}{i, y, p}
+	case 7:
+		return &struct {
+			*instI2C
+			instI2CMessager
+			instI2CSMBus
+			i2c.Pins
+		}{i, x, y, p}
+	default:
+		return i
+	}
+}
+
+// SPI returns an instrumented p. The connections returned by Connect() are
+// instrumented.
+//
+// The returned port implements spi.Pins only if p does.
+func (m *Monitor) SPI(name string, p spi.PortCloser) spi.PortCloser {
+	i := &instPort{m: m, name: name, p: p}
+	if pins, ok := p.(spi.Pins); ok {
+		return &struct {
+			*instPort
+			spi.Pins
+		}{i, pins}
+	}
+	return i
+}
+
+// SPIConn returns an instrumented c.
+//
+// The returned connection implements spi.TxContexter. It implements
+// io.Reader, io.Writer and spi.Pins only if c does.
+func (m *Monitor) SPIConn(name string, c spi.Conn) spi.Conn {
+	i := &instSPIConn{instConn{m: m, name: name, c: c}, c}
+	x, y := instSPIReader{i}, instSPIWriter{i}
+	k := 0
+	if _, ok := c.(io.Reader); ok {
+		k |= 1
+	}
+	if _, ok := c.(io.Writer); ok {
+		k |= 2
+	}
+	p, ok := c.(spi.Pins)
+	if ok {
+		k |= 4
+	}
+	switch k {
+	case 1:
+		return &struct {
+			*instSPIConn
+			instSPIReader
+		}{i, x}
+	case 2:
+		return &struct {
+			*instSPIConn
+			instSPIWriter
+		}{i, y}
+	case 3:
+		return &struct {
+			*instSPIConn
+			instSPIReader
+			instSPIWriter
+		}{i, x, y}
+	case 4:
+		return &struct {
+			*instSPIConn
+			spi.Pins
+		}{i, p}
+	case 5:
+		return &struct {
+			*instSPIConn
+			instSPIReader
+			spi.Pins
+		}{i, x, p}
+	case 6:
+		return &struct {
+			*instSPIConn
+			instSPIWriter
+			spi.Pins
+		}{i, y, p}
+	case 7:
+		return &struct {
+			*instSPIConn
+			instSPIReader
+			instSPIWriter
+			spi.Pins
+		}{i, x, y, p}
+	default:
+		return i
+	}
+}
+
+// OneWire returns an instrumented b.
+func (m *Monitor) OneWire(name string, b onewire.Bus) onewire.Bus {
+	return &instOneWire{m: m, name: name, b: b}
+}
+
+// Install instruments all the I²C buses and SPI ports opened via
+// i2creg.Open() and spireg.Open() with m.
+//
+// Use nil to stop instrumenting the buses opened afterward.
+func Install(m *Monitor) {
+	if m == nil {
+		i2creg.SetWrapper(nil)
+		spireg.SetWrapper(nil)
+		return
+	}
+	i2creg.SetWrapper(m.I2C)
+	spireg.SetWrapper(m.SPI)
+}
+
+//
+
+// record accounts one transaction and writes its trace.
+//
+// op is only evaluated when tracing is enabled.
+func (m *Monitor) record(name string, w, r int, d time.Duration, err error, op func() string) {
+	m.mu.Lock()
+	if m.stats == nil {
+		m.stats = map[string]*Stats{}
+	}
+	s := m.stats[name]
+	if s == nil {
+		s = &Stats{}
+		m.stats[name] = s
+	}
+	s.Tx++
+	if err != nil {
+		s.Errors++
+	} else {
+		s.Written += int64(w)
+		s.Read += int64(r)
+	}
+	s.Total += d
+	if d > s.Max {
+		s.Max = d
+	}
+	i := 0
+	for ; i < len(LatencyBuckets) && d >= LatencyBuckets[i]; i++ {
+	}
+	s.Latency[i]++
+	m.mu.Unlock()
+	if m.Trace != nil {
+		line := fmt.Sprintf("%s %s %s", name, op(), d)
+		if err != nil {
+			line += ": " + err.Error()
+		}
+		m.traceMu.Lock()
+		io.WriteString(m.Trace, line+"\n")
+		m.traceMu.Unlock()
+	}
+}
+
+// hex formats b as hex bytes.
+func hex(b []byte) string {
+	return fmt.Sprintf("[% x]", b)
+}
+
+// instConn implements conn.Conn.
+type instConn struct {
+	m    *Monitor
+	name string
+	c    conn.Conn
+}
+
+func (i *instConn) String() string {
+	return fmt.Sprint(i.c)
+}
+
+func (i *instConn) Tx(w, r []byte) error {
+	return i.TxContext(context.Background(), w, r)
+}
+
+func (i *instConn) TxContext(ctx context.Context, w, r []byte) error {
+	start := time.Now()
+	err := conn.TxContext(ctx, i.c, w, r)
+	i.m.record(i.name, len(w), len(r), time.Since(start), err, func() string {
+		return fmt.Sprintf("Tx(w=%s, r=%s)", hex(w), hex(r))
+	})
+	return err
+}
+
+func (i *instConn) Duplex() conn.Duplex {
+	return i.c.Duplex()
+}
+
+func (i *instConn) MaxTxSize() int {
+	if l, ok := i.c.(conn.Limits); ok {
+		return l.MaxTxSize()
+	}
+	return 0
+}
+
+// instSPIConn implements spi.Conn.
+type instSPIConn struct {
+	instConn
+	s spi.Conn
+}
+
+func (i *instSPIConn) Tx(w, r []byte) error {
+	return i.TxContext(context.Background(), w, r)
+}
+
+func (i *instSPIConn) TxContext(ctx context.Context, w, r []byte) error {
+	start := time.Now()
+	err := spi.TxContext(ctx, i.s, w, r)
+	i.m.record(i.name, len(w), len(r), time.Since(start), err, func() string {
+		return fmt.Sprintf("Tx(w=%s, r=%s)", hex(w), hex(r))
+	})
+	return err
+}
+
+func (i *instSPIConn) TxPackets(p []spi.Packet) error {
+	return i.TxPacketsContext(context.Background(), p)
+}
+
+func (i *instSPIConn) TxPacketsContext(ctx context.Context, p []spi.Packet) error {
+	start := time.Now()
+	err := spi.TxPacketsContext(ctx, i.s, p)
+	lw, lr := 0, 0
+	for j := range p {
+		lw += len(p[j].W)
+		lr += len(p[j].R)
+	}
+	i.m.record(i.name, lw, lr, time.Since(start), err, func() string {
+		s := make([]string, len(p))
+		for j := range p {
+			s[j] = fmt.Sprintf("{w=%s, r=%s}", hex(p[j].W), hex(p[j].R))
+		}
+		return "TxPackets(" + strings.Join(s, ", ") + ")"
+	})
+	return err
+}
+
+// instSPIReader adds io.Reader to instSPIConn.
+type instSPIReader struct {
+	i *instSPIConn
+}
+
+func (x instSPIReader) Read(b []byte) (int, error) {
+	start := time.Now()
+	n, err := x.i.s.(io.Reader).Read(b)
+	x.i.m.record(x.i.name, 0, n, time.Since(start), err, func() string {
+		return fmt.Sprintf("Read(r=%s)", hex(b[:n]))
+	})
+	return n, err
+}
+
+// instSPIWriter adds io.Writer to instSPIConn.
+type instSPIWriter struct {
+	i *instSPIConn
+}
+
+func (x instSPIWriter) Write(b []byte) (int, error) {
+	start := time.Now()
+	n, err := x.i.s.(io.Writer).Write(b)
+	x.i.m.record(x.i.name, n, 0, time.Since(start), err, func() string {
+		return fmt.Sprintf("Write(w=%s)", hex(b))
+	})
+	return n, err
+}
+
+// instPort implements spi.PortCloser.
+type instPort struct {
+	m    *Monitor
+	name string
+	p    spi.PortCloser
+}
+
+func (i *instPort) String() string {
+	return fmt.Sprint(i.p)
+}
+
+func (i *instPort) Close() error {
+	return i.p.Close()
+}
+
+func (i *instPort) Connect(maxHz int64, mode spi.Mode, bits int) (spi.Conn, error) {
+	c, err := i.p.Connect(maxHz, mode, bits)
+	if err != nil {
+		return nil, err
+	}
+	return i.m.SPIConn(i.name, c), nil
+}
+
+func (i *instPort) LimitSpeed(maxHz int64) error {
+	return i.p.LimitSpeed(maxHz)
+}
+
+// instI2C implements i2c.BusCloser.
+type instI2C struct {
+	m    *Monitor
+	name string
+	b    i2c.BusCloser
+}
+
+func (i *instI2C) String() string {
+	return fmt.Sprint(i.b)
+}
+
+func (i *instI2C) Close() error {
+	return i.b.Close()
+}
+
+func (i *instI2C) Tx(addr uint16, w, r []byte) error {
+	return i.TxContext(context.Background(), addr, w, r)
+}
+
+func (i *instI2C) TxContext(ctx context.Context, addr uint16, w, r []byte) error {
+	start := time.Now()
+	err := i2c.TxContext(ctx, i.b, addr, w, r)
+	i.m.record(i.name, len(w), len(r), time.Since(start), err, func() string {
+		return fmt.Sprintf("Tx(%#x, w=%s, r=%s)", addr, hex(w), hex(r))
+	})
+	return err
+}
+
+func (i *instI2C) SetSpeed(hz int64) error {
+	return i.b.SetSpeed(hz)
+}
+
+// instI2CMessager adds i2c.TxMessager to instI2C.
+type instI2CMessager struct {
+	i *instI2C
+}
+
+func (x instI2CMessager) TxMessages(msgs []i2c.Msg) error {
+	i := x.i
+	start := time.Now()
+	err := i2c.TxMessages(i.b, msgs)
+	lw, lr := 0, 0
+	for j := range msgs {
+		lw += len(msgs[j].W)
+		lr += len(msgs[j].R)
+	}
+	i.m.record(i.name, lw, lr, time.Since(start), err, func() string {
+		s := make([]string, len(msgs))
+		for j := range msgs {
+			s[j] = fmt.Sprintf("{%#x, w=%s, r=%s}", msgs[j].Addr, hex(msgs[j].W), hex(msgs[j].R))
+		}
+		return "TxMessages(" + strings.Join(s, ", ") + ")"
+	})
+	return err
+}
+
+// instI2CSMBus adds smbus.Bus to instI2C.
+type instI2CSMBus struct {
+	i *instI2C
+}
+
+func (x instI2CSMBus) SMBusTx(addr uint16, read bool, cmd byte, p smbus.Protocol, data []byte, pec bool) error {
+	i := x.i
+	start := time.Now()
+	err := i.b.(smbus.Bus).SMBusTx(addr, read, cmd, p, data, pec)
+	lw, lr := len(data), 0
+	if read {
+		lw, lr = 0, len(data)
+	}
+	i.m.record(i.name, lw, lr, time.Since(start), err, func() string {
+		return fmt.Sprintf("SMBusTx(%#x, read=%t, cmd=%#x, %s, data=%s, pec=%t)", addr, read, cmd, p, hex(data), pec)
+	})
+	return err
+}
+
+// instOneWire implements onewire.Bus.
+type instOneWire struct {
+	m    *Monitor
+	name string
+	b    onewire.Bus
+}
+
+func (i *instOneWire) String() string {
+	return fmt.Sprint(i.b)
+}
+
+func (i *instOneWire) Tx(w, r []byte, power onewire.Pullup) error {
+	return i.TxContext(context.Background(), w, r, power)
+}
+
+func (i *instOneWire) TxContext(ctx context.Context, w, r []byte, power onewire.Pullup) error {
+	start := time.Now()
+	err := onewire.TxContext(ctx, i.b, w, r, power)
+	i.m.record(i.name, len(w), len(r), time.Since(start), err, func() string {
+		return fmt.Sprintf("Tx(w=%s, r=%s, %s)", hex(w), hex(r), power)
+	})
+	return err
+}
+
+func (i *instOneWire) Search(alarmOnly bool) ([]onewire.Address, error) {
+	return i.b.Search(alarmOnly)
+}
+
+var _ conn.Conn = &instConn{}
+var _ conn.TxContexter = &instConn{}
+var _ conn.Limits = &instConn{}
+var _ spi.Conn = &instSPIConn{}
+var _ spi.TxContexter = &instSPIConn{}
+var _ io.Reader = &instSPIReader{}
+var _ io.Writer = &instSPIWriter{}
+var _ spi.PortCloser = &instPort{}
+var _ i2c.BusCloser = &instI2C{}
+var _ i2c.TxContexter = &instI2C{}
+var _ i2c.TxMessager = &instI2CMessager{}
+var _ onewire.Bus = &instOneWire{}
+var _ onewire.TxContexter = &instOneWire{}
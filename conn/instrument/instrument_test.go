@@ -0,0 +1,401 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package instrument
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/i2c/smbus"
+	"periph.io/x/periph/conn/onewire"
+	"periph.io/x/periph/conn/onewire/onewiretest"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spireg"
+	"periph.io/x/periph/conn/spi/spitest"
+)
+
+func Example() {
+	// Instrument all the buses opened from now on and print a trace of the
+	// transactions.
+	m := &Monitor{Trace: os.Stderr}
+	Install(m)
+	defer Install(nil)
+
+	b, err := i2creg.Open("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer b.Close()
+
+	// Use b with any device driver, then print the metrics.
+	for _, name := range m.Names() {
+		fmt.Printf("%s: %s\n", name, m.Stats(name))
+	}
+}
+
+func TestConn(t *testing.T) {
+	m := &Monitor{}
+	p := &conntest.Playback{Ops: []conntest.IO{{W: []byte{1}, R: []byte{2, 3}}}, D: conn.Half}
+	c := m.Conn("c", p)
+	if s := fmt.Sprint(c); s != "playback" {
+		t.Fatal(s)
+	}
+	if c.Duplex() != conn.Half {
+		t.Fatal(c.Duplex())
+	}
+	r := make([]byte, 2)
+	if err := c.Tx([]byte{1}, r); err != nil {
+		t.Fatal(err)
+	}
+	s := m.Stats("c")
+	if s.Tx != 1 || s.Errors != 0 || s.Written != 1 || s.Read != 2 || s.Max == 0 || s.Total != s.Max {
+		t.Fatalf("%#v", s)
+	}
+	n := int64(0)
+	for _, v := range s.Latency {
+		n += v
+	}
+	if n != 1 {
+		t.Fatal(s.Latency)
+	}
+	if l, ok := c.(conn.Limits); !ok || l.MaxTxSize() != 0 {
+		t.Fatal("limits")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m.Reset()
+	if names := m.Names(); len(names) != 0 {
+		t.Fatal(names)
+	}
+	if s := m.Stats("c"); s.Tx != 0 {
+		t.Fatal(s)
+	}
+}
+
+func TestMonitor_slowTrace(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	w := &blockingWriter{entered: entered, unblock: unblock}
+	m := &Monitor{Trace: w}
+	c := m.Conn("c", &conntest.Discard{D: conn.Half})
+	done := make(chan error)
+	go func() {
+		done <- c.Tx([]byte{1}, nil)
+	}()
+	<-entered
+	// The metrics are available while the trace is being written.
+	if s := m.Stats("c"); s.Tx != 1 {
+		t.Fatal(s)
+	}
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if s := w.buf.String(); !strings.HasPrefix(s, "c Tx(w=[01], r=[]) ") {
+		t.Fatal(s)
+	}
+}
+
+func TestI2C(t *testing.T) {
+	var buf bytes.Buffer
+	m := &Monitor{Trace: &buf}
+	p := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x76, W: []byte{0xd0}, R: []byte{0x60}},
+			{Addr: 0x76, W: []byte{0xd0}},
+			{Addr: 0x76, R: []byte{0x60}, Restart: true},
+			{Addr: 0x10, W: []byte{0x04}, R: []byte{0x01, 0x02}},
+		},
+		DontPanic: true,
+	}
+	b := m.I2C("I2C1", p)
+	r := make([]byte, 1)
+	if err := b.Tx(0x76, []byte{0xd0}, r); err != nil || r[0] != 0x60 {
+		t.Fatal(r, err)
+	}
+	if err := i2c.TxMessages(b, []i2c.Msg{{Addr: 0x76, W: []byte{0xd0}}, {Addr: 0x76, R: r}}); err != nil {
+		t.Fatal(err)
+	}
+	d := smbus.Dev{Bus: b, Addr: 0x10}
+	if v, err := d.ReadWordData(0x04); err != nil || v != 0x0201 {
+		t.Fatal(v, err)
+	}
+	if err := b.Tx(0x76, nil, nil); err == nil {
+		t.Fatal("playback is exhausted")
+	}
+	if s := m.Stats("I2C1"); s.Tx != 4 || s.Errors != 1 || s.Written != 3 || s.Read != 4 {
+		t.Fatalf("%#v", s)
+	}
+	if names := m.Names(); !reflect.DeepEqual(names, []string{"I2C1"}) {
+		t.Fatal(names)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatal(lines)
+	}
+	for i, prefix := range []string{
+		"I2C1 Tx(0x76, w=[d0], r=[60]) ",
+		"I2C1 TxMessages({0x76, w=[d0], r=[]}, {0x76, w=[], r=[60]}) ",
+		"I2C1 Tx(0x10, w=[04], r=[01 02]) ",
+		"I2C1 Tx(0x76, w=[], r=[]) ",
+	} {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Fatalf("%d: %q", i, lines[i])
+		}
+	}
+	if !strings.Contains(lines[3], ": i2ctest: unexpected Tx()") {
+		t.Fatal(lines[3])
+	}
+	if err := b.SetSpeed(100); err != nil {
+		t.Fatal(err)
+	}
+	if pins, ok := b.(i2c.Pins); !ok || pins.SCL() != nil || pins.SDA() != nil {
+		t.Fatal("pins")
+	}
+	if _, ok := b.(i2c.TxMessager); !ok {
+		t.Fatal("i2ctest.Playback implements i2c.TxMessager")
+	}
+	if _, ok := b.(smbus.Bus); ok {
+		t.Fatal("i2ctest.Playback doesn't implement smbus.Bus")
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestI2C_interfaces(t *testing.T) {
+	m := &Monitor{}
+	b := m.I2C("plain", &plainBus{})
+	if _, ok := b.(i2c.TxMessager); ok {
+		t.Fatal("TxMessager")
+	}
+	if _, ok := b.(smbus.Bus); ok {
+		t.Fatal("smbus.Bus")
+	}
+	if _, ok := b.(i2c.Pins); ok {
+		t.Fatal("Pins")
+	}
+	if _, ok := b.(i2c.TxContexter); !ok {
+		t.Fatal("TxContexter")
+	}
+	if err := i2c.TxMessages(b, []i2c.Msg{{Addr: 1, W: []byte{1}}, {Addr: 1, W: []byte{2}}, {Addr: 1, W: []byte{3}}}); err == nil {
+		t.Fatal("plainBus doesn't support 3 segments")
+	}
+
+	n := &smbusBus{}
+	b = m.I2C("native", n)
+	d := smbus.Dev{Bus: b, Addr: 0x10}
+	if err := d.WriteByteData(0x01, 0x02); err != nil {
+		t.Fatal(err)
+	}
+	if n.cmd != 0x01 {
+		t.Fatal(n.cmd)
+	}
+	if s := m.Stats("native"); s.Tx != 1 || s.Written != 1 {
+		t.Fatalf("%#v", s)
+	}
+}
+
+func TestSPI(t *testing.T) {
+	m := &Monitor{}
+	p := &spitest.Playback{
+		Playback: conntest.Playback{
+			Ops: []conntest.IO{
+				{W: []byte{1, 2}, R: []byte{3, 4}},
+				{W: []byte{5}, R: []byte{6}},
+			},
+		},
+	}
+	port := m.SPI("SPI0.0", p)
+	if err := port.LimitSpeed(1000); err != nil {
+		t.Fatal(err)
+	}
+	c, err := port.Connect(1000, spi.Mode0, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := make([]byte, 2)
+	if err := c.Tx([]byte{1, 2}, r); err != nil {
+		t.Fatal(err)
+	}
+	r = r[:1]
+	if err := c.TxPackets([]spi.Packet{{W: []byte{5}, R: r}}); err == nil {
+		// spitest.Playback doesn't implement TxPackets.
+		t.Fatal("expected error")
+	}
+	if s := m.Stats("SPI0.0"); s.Tx != 2 || s.Errors != 1 || s.Written != 2 || s.Read != 2 {
+		t.Fatalf("%#v", s)
+	}
+	if pins, ok := port.(spi.Pins); !ok || pins.CLK() != nil || pins.CS() != nil {
+		t.Fatal("pins")
+	}
+	if _, ok := c.(spi.Pins); !ok {
+		t.Fatal("conn pins")
+	}
+	if _, ok := c.(io.Writer); ok {
+		t.Fatal("spitest.Playback's Conn doesn't implement io.Writer")
+	}
+	p.Count++
+	if err := port.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSPIConn_ReadWriter(t *testing.T) {
+	m := &Monitor{}
+	c := m.SPIConn("rw", &rwConn{})
+	if _, ok := c.(spi.Pins); ok {
+		t.Fatal("Pins")
+	}
+	if n, err := c.(io.Writer).Write([]byte{1, 2}); n != 2 || err != nil {
+		t.Fatal(n, err)
+	}
+	var r [3]byte
+	if n, err := c.(io.Reader).Read(r[:]); n != 3 || err != nil {
+		t.Fatal(n, err)
+	}
+	if s := m.Stats("rw"); s.Tx != 2 || s.Written != 2 || s.Read != 3 {
+		t.Fatalf("%#v", s)
+	}
+}
+
+func TestOneWire(t *testing.T) {
+	m := &Monitor{}
+	p := &onewiretest.Playback{Ops: []onewiretest.IO{{W: []byte{0xcc, 0x44}, Pull: true}}}
+	b := m.OneWire("ow", p)
+	if err := b.Tx([]byte{0xcc, 0x44}, nil, onewire.StrongPullup); err != nil {
+		t.Fatal(err)
+	}
+	if s := m.Stats("ow"); s.Tx != 1 || s.Written != 2 {
+		t.Fatalf("%#v", s)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	if err := i2creg.Register("instrument-test", []string{"inst"}, -1, func() (i2c.BusCloser, error) {
+		return &i2ctest.Playback{Ops: []i2ctest.IO{{Addr: 1, W: []byte{2}}}}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer i2creg.Unregister("instrument-test")
+	if err := spireg.Register("instrument-test", nil, -1, func() (spi.PortCloser, error) {
+		return &spitest.Playback{}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer spireg.Unregister("instrument-test")
+
+	m := &Monitor{}
+	Install(m)
+	b, err := i2creg.Open("inst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := spireg.Open("instrument-test")
+	Install(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.(*spitest.Playback); ok {
+		t.Fatalf("%T", p)
+	}
+	if _, ok := b.(*i2ctest.Playback); ok {
+		t.Fatalf("%T", b)
+	}
+	if err := b.Tx(1, []byte{2}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := m.Stats("instrument-test"); s.Tx != 1 {
+		t.Fatal(s)
+	}
+	b, err = i2creg.Open("inst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.(*i2ctest.Playback); !ok {
+		t.Fatalf("%T", b)
+	}
+}
+
+//
+
+// plainBus only implements i2c.BusCloser.
+type plainBus struct {
+}
+
+func (p *plainBus) String() string {
+	return "plain"
+}
+
+func (p *plainBus) Close() error {
+	return nil
+}
+
+func (p *plainBus) Tx(addr uint16, w, r []byte) error {
+	return nil
+}
+
+func (p *plainBus) SetSpeed(hz int64) error {
+	return nil
+}
+
+// smbusBus natively supports SMBus.
+type smbusBus struct {
+	plainBus
+	cmd byte
+}
+
+func (s *smbusBus) SMBusTx(addr uint16, read bool, cmd byte, p smbus.Protocol, data []byte, pec bool) error {
+	s.cmd = cmd
+	return nil
+}
+
+// rwConn implements spi.Conn, io.Reader and io.Writer.
+type rwConn struct {
+	conntest.Discard
+}
+
+func (r *rwConn) TxPackets(p []spi.Packet) error {
+	return nil
+}
+
+func (r *rwConn) Read(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (r *rwConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// blockingWriter blocks the first Write until unblock is closed.
+type blockingWriter struct {
+	entered chan struct{}
+	unblock chan struct{}
+	buf     bytes.Buffer
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	if b.entered != nil {
+		close(b.entered)
+		b.entered = nil
+		<-b.unblock
+	}
+	return b.buf.Write(p)
+}
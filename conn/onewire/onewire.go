@@ -16,6 +16,7 @@
 package onewire
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -50,6 +51,43 @@ type Bus interface {
 	Search(alarmOnly bool) ([]Address, error)
 }
 
+// TxContexter is implemented by a Bus that natively supports a context for
+// its transactions.
+//
+// Users should call TxContext() instead of asserting this interface directly.
+type TxContexter interface {
+	// TxContext performs a bus transaction like Bus.Tx() but returns
+	// ctx.Err() without starting it if ctx is already done.
+	//
+	// Whether a transaction already started is interrupted when ctx is done
+	// depends on the implementation, which must document it.
+	TxContext(ctx context.Context, w, r []byte, power Pullup) error
+}
+
+// TxContext performs a bus transaction on b unless ctx is already done.
+//
+// If b implements TxContexter, its TxContext() method is used. Otherwise the
+// transaction is run via conn.RunContext(), which doesn't interrupt it once
+// started.
+func TxContext(ctx context.Context, b Bus, w, r []byte, power Pullup) error {
+	if t, ok := b.(TxContexter); ok {
+		return t.TxContext(ctx, w, r, power)
+	}
+	return conn.RunContext(ctx, w, r, func(w, r []byte) error {
+		return b.Tx(w, r, power)
+	})
+}
+
+// WithContext returns a Bus that runs every transaction through TxContext()
+// with ctx.
+//
+// This is useful to apply a deadline to the I/O done by an existing device
+// driver that doesn't accept a context.Context. Once ctx is done, the next
+// transactions fail; the one in flight completes.
+func WithContext(ctx context.Context, b Bus) Bus {
+	return &ctxBus{ctx: ctx, b: b}
+}
+
 // Address represents a 1-wire device address in little-endian format. This means
 // that the family code ends up in the lower byte, the CRC in the top byte,
 // and the variable address part in the middle 6 bytes. E.g. a DS18B20 device,
@@ -158,13 +196,14 @@ func (d *Dev) String() string {
 //
 // It's a wrapper for Dev.Bus.Tx().
 func (d *Dev) Tx(w, r []byte) error {
-	// Issue ROM match command to select the device followed by the
-	// bytes being written.
-	ww := make([]byte, 9, len(w)+9)
-	ww[0] = 0x55 // Match ROM
-	binary.LittleEndian.PutUint64(ww[1:], uint64(d.Addr))
-	ww = append(ww, w...)
-	return d.Bus.Tx(ww, r, WeakPullup)
+	return d.Bus.Tx(d.matchROM(w), r, WeakPullup)
+}
+
+// TxContext is like Tx() but returns ctx.Err() if ctx is already done.
+//
+// It's a wrapper for TxContext().
+func (d *Dev) TxContext(ctx context.Context, w, r []byte) error {
+	return TxContext(ctx, d.Bus, d.matchROM(w), r, WeakPullup)
 }
 
 // Duplex always return conn.Half for 1-wire.
@@ -179,17 +218,53 @@ func (d *Dev) Duplex() conn.Duplex {
 //
 // It's a wrapper for Dev.Bus.Tx().
 func (d *Dev) TxPower(w, r []byte) error {
-	// Issue ROM match command to select the device followed by the
-	// bytes being written.
+	return d.Bus.Tx(d.matchROM(w), r, StrongPullup)
+}
+
+// TxPowerContext is like TxPower() but returns ctx.Err() if ctx is already
+// done.
+//
+// It's a wrapper for TxContext().
+func (d *Dev) TxPowerContext(ctx context.Context, w, r []byte) error {
+	return TxContext(ctx, d.Bus, d.matchROM(w), r, StrongPullup)
+}
+
+// matchROM returns w prefixed with the ROM match command to select the
+// device.
+func (d *Dev) matchROM(w []byte) []byte {
 	ww := make([]byte, 9, len(w)+9)
 	ww[0] = 0x55 // Match ROM
 	binary.LittleEndian.PutUint64(ww[1:], uint64(d.Addr))
-	ww = append(ww, w...)
-	return d.Bus.Tx(ww, r, StrongPullup)
+	return append(ww, w...)
+}
+
+//
+
+// ctxBus implements Bus with the transactions bound to a context.
+type ctxBus struct {
+	ctx context.Context
+	b   Bus
+}
+
+func (c *ctxBus) String() string {
+	return fmt.Sprint(c.b)
+}
+
+func (c *ctxBus) Tx(w, r []byte, power Pullup) error {
+	return TxContext(c.ctx, c.b, w, r, power)
+}
+
+func (c *ctxBus) Search(alarmOnly bool) ([]Address, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.b.Search(alarmOnly)
 }
 
 // Ensure that the appropriate interfaces are implemented.
 var _ conn.Conn = &Dev{}
+var _ conn.TxContexter = &Dev{}
+var _ Bus = &ctxBus{}
 var _ NoDevicesError = noDevicesError("")
 var _ ShortedBusError = shortedBusError("")
 var _ BusError = busError("")
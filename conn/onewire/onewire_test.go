@@ -6,6 +6,7 @@ package onewire
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -117,6 +118,33 @@ func TestDevTxPower(t *testing.T) {
 	}
 }
 
+func TestDevTxContext(t *testing.T) {
+	b := &fakeBus{r: []byte{1}}
+	d := Dev{b, 12}
+	r := make([]byte, 1)
+	if err := d.TxPowerContext(context.Background(), []byte{3}, r); err != nil {
+		t.Fatal(err)
+	}
+	if r[0] != 1 || b.power != StrongPullup {
+		t.Fatal(r, b.power)
+	}
+	expected := []byte{85, 12, 0, 0, 0, 0, 0, 0, 0, 3}
+	if !bytes.Equal(b.w, expected) {
+		t.Fatal(b.w)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.TxContext(ctx, []byte{4}, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := WithContext(ctx, b).Tx(nil, nil, WeakPullup); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.w, expected) {
+		t.Fatal(b.w)
+	}
+}
+
 //
 
 type fakeBus struct {
@@ -7,6 +7,10 @@ package onewiretest
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"sync"
 
 	"periph.io/x/periph/conn/conntest"
@@ -21,6 +25,38 @@ type IO struct {
 	Pull onewire.Pullup
 }
 
+// MarshalJSON implements json.Marshaler.
+func (i IO) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ioJSON{W: i.W, R: i.R, Pull: bool(i.Pull)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (i *IO) UnmarshalJSON(b []byte) error {
+	var j ioJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	*i = IO{W: j.W, R: j.R, Pull: onewire.Pullup(j.Pull)}
+	return nil
+}
+
+// Save writes ops as a trace to w.
+//
+// Use it to save the Ops of a Record as a golden file, to be loaded back with
+// Load into a Playback. See conntest.TraceVersion for the format.
+func Save(w io.Writer, ops []IO) error {
+	return conntest.WriteTrace(w, "onewire", len(ops), func(i int) interface{} { return ops[i] })
+}
+
+// Load reads a trace written by Save.
+func Load(r io.Reader) ([]IO, error) {
+	var ops []IO
+	if err := conntest.ReadTrace(r, "onewire", &ops); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
 // Record implements onewire.Bus that records everything written to it.
 //
 // This can then be used to feed to Playback to do "replay" based unit tests.
@@ -36,6 +72,21 @@ func (r *Record) String() string {
 
 // Tx implements onewire.Bus.
 func (r *Record) Tx(w, read []byte, pull onewire.Pullup) error {
+	return r.txInternal(context.Background(), w, read, pull)
+}
+
+// TxContext implements onewire.TxContexter.
+//
+// The context is forwarded to Bus. The I/O is not recorded if the transaction
+// fails, including when ctx is already done.
+func (r *Record) TxContext(ctx context.Context, w, read []byte, pull onewire.Pullup) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.txInternal(ctx, w, read, pull)
+}
+
+func (r *Record) txInternal(ctx context.Context, w, read []byte, pull onewire.Pullup) error {
 	io := IO{Pull: pull}
 	if len(w) != 0 {
 		io.W = make([]byte, len(w))
@@ -48,7 +99,7 @@ func (r *Record) Tx(w, read []byte, pull onewire.Pullup) error {
 			return conntest.Errorf("onewiretest: read unsupported when no bus is connected")
 		}
 	} else {
-		if err := r.Bus.Tx(w, read, pull); err != nil {
+		if err := onewire.TxContext(ctx, r.Bus, w, read, pull); err != nil {
 			return err
 		}
 	}
@@ -138,6 +189,16 @@ func (p *Playback) Tx(w, r []byte, pull onewire.Pullup) error {
 	return nil
 }
 
+// TxContext implements onewire.TxContexter.
+//
+// It returns ctx.Err() without consuming an Ops entry if ctx is done.
+func (p *Playback) TxContext(ctx context.Context, w, r []byte, pull onewire.Pullup) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.Tx(w, r, pull)
+}
+
 // Q implements onewire.Pins.
 func (p *Playback) Q() gpio.PinIO {
 	p.Lock()
@@ -190,7 +251,37 @@ func (p *Playback) SearchTriplet(direction byte) (onewire.TripletResult, error)
 	return tr, nil
 }
 
+// Faulty implements onewire.Bus. It forwards the transactions to Bus and
+// injects faults.
 //
+// It is meant to exercise the error paths of device drivers.
+type Faulty struct {
+	Bus onewire.Bus
+	conntest.Faults
+}
+
+func (f *Faulty) String() string {
+	return fmt.Sprintf("faulty(%s)", f.Bus)
+}
+
+// Tx implements onewire.Bus.
+func (f *Faulty) Tx(w, r []byte, pull onewire.Pullup) error {
+	return f.Inject(r, func() error { return f.Bus.Tx(w, r, pull) })
+}
+
+// Search implements onewire.Bus.
+func (f *Faulty) Search(alarmOnly bool) ([]onewire.Address, error) {
+	return f.Bus.Search(alarmOnly)
+}
+
+//
+
+type ioJSON struct {
+	W    conntest.Hex `json:"w,omitempty"`
+	R    conntest.Hex `json:"r,omitempty"`
+	Pull bool         `json:"pull,omitempty"`
+}
+
 // errorf is the internal implementation that optionally panic.
 //
 // If dontPanic is false, it panics instead.
@@ -205,4 +296,7 @@ func errorf(dontPanic bool, format string, a ...interface{}) error {
 var _ onewire.Bus = &Record{}
 var _ onewire.Pins = &Record{}
 var _ onewire.Bus = &Playback{}
+var _ onewire.Bus = &Faulty{}
 var _ onewire.BusSearcher = &Playback{}
+var _ onewire.TxContexter = &Record{}
+var _ onewire.TxContexter = &Playback{}
@@ -5,7 +5,10 @@
 package onewiretest
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"reflect"
 	"testing"
 
 	"periph.io/x/periph/conn/conntest"
@@ -96,6 +99,31 @@ func TestPlayback(t *testing.T) {
 	}
 }
 
+func TestRecord_Playback_TxContext(t *testing.T) {
+	p := &Playback{
+		Ops: []IO{{W: []byte{10}, R: []byte{12}, Pull: onewire.StrongPullup}},
+	}
+	r := Record{Bus: p}
+	ctx, cancel := context.WithCancel(context.Background())
+	v := [1]byte{}
+	if err := r.TxContext(ctx, []byte{10}, v[:], onewire.StrongPullup); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := r.TxContext(ctx, []byte{10}, v[:], onewire.StrongPullup); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := p.TxContext(ctx, []byte{10}, v[:], onewire.StrongPullup); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if v[0] != 12 || len(r.Ops) != 1 {
+		t.Fatal(v, r.Ops)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestPlayback_Close_panic(t *testing.T) {
 	p := Playback{Ops: []IO{{W: []byte{10}}}}
 	defer func() {
@@ -239,3 +267,47 @@ match:
 		t.Fatal(err)
 	}
 }
+
+func TestFaulty(t *testing.T) {
+	p := &Playback{Ops: []IO{{W: []byte{0x33}, R: []byte{0x28}}}}
+	f := &Faulty{Bus: p, Faults: conntest.Faults{ErrorRate: 1}}
+	if s := f.String(); s != "faulty(playback)" {
+		t.Fatal(s)
+	}
+	v := [1]byte{}
+	if err := f.Tx([]byte{0x33}, v[:], onewire.WeakPullup); err != conntest.ErrInjected {
+		t.Fatal(err)
+	}
+	f.ErrorRate = 0
+	if err := f.Tx([]byte{0x33}, v[:], onewire.WeakPullup); err != nil || v[0] != 0x28 {
+		t.Fatal(v, err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSave_Load(t *testing.T) {
+	ops := []IO{
+		{W: []byte{0xcc, 0x44}, Pull: onewire.StrongPullup},
+		{W: []byte{0xcc, 0xbe}, R: []byte{0xe0, 0x01}},
+	}
+	var b bytes.Buffer
+	if err := Save(&b, ops); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "{\"version\":1,\"kind\":\"onewire\",\"ops\":[\n" +
+		"{\"w\":\"cc44\",\"pull\":true},\n" +
+		"{\"w\":\"ccbe\",\"r\":\"e001\"}\n" +
+		"]}\n"
+	if s := b.String(); s != expected {
+		t.Fatalf("%q", s)
+	}
+	got, err := Load(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ops, got) {
+		t.Fatalf("%#v != %#v", ops, got)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pinreg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/pin"
+)
+
+// Board describes the headers and the aliases of a board, like a HAT or a
+// custom carrier board, so they can be registered at runtime.
+//
+// The JSON representation looks like:
+//
+//	{
+//	  "headers": {
+//	    "J1": [["V3_3", "V5"], ["GPIO17", "GROUND"], ["LED_STATUS", "VBAT"]]
+//	  },
+//	  "aliases": {"LED_STATUS": "GPIO17", "BUTTON": "GPIO27"},
+//	  "active_low": ["BUTTON"]
+//	}
+type Board struct {
+	// Headers maps each header name to its rows of pin names, as passed to
+	// Register().
+	//
+	// A name is resolved with gpioreg.ByName(), so it can be a GPIO, a number
+	// or an alias. The names of the well known pins like GROUND or V3_3 map to
+	// the pins in package pin. Any other name is a non-GPIO pin, like VBAT
+	// above.
+	Headers map[string][][]string `json:"headers"`
+	// Aliases maps an alias name to the name of the pin it points to, as
+	// passed to gpioreg.RegisterAlias().
+	Aliases map[string]string `json:"aliases"`
+	// ActiveLow lists the aliases whose levels are inverted, as registered
+	// with gpioreg.RegisterActiveLowAlias().
+	ActiveLow []string `json:"active_low"`
+}
+
+// ParseBoard decodes a board description.
+//
+// Only JSON is supported. A YAML description must be converted to JSON
+// first. Unknown fields are rejected so a typo isn't silently ignored.
+func ParseBoard(r io.Reader) (*Board, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("pinreg: invalid board description: %v", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("pinreg: invalid board description: %v", err)
+	}
+	for k := range fields {
+		if k != "headers" && k != "aliases" && k != "active_low" {
+			return nil, fmt.Errorf("pinreg: invalid board description: unknown field %q", k)
+		}
+	}
+	b := &Board{}
+	if err := json.Unmarshal(raw, b); err != nil {
+		return nil, fmt.Errorf("pinreg: invalid board description: %v", err)
+	}
+	for _, name := range b.ActiveLow {
+		if _, ok := b.Aliases[name]; !ok {
+			return nil, fmt.Errorf("pinreg: active low pin %q is not an alias", name)
+		}
+	}
+	return b, nil
+}
+
+// RegisterBoard registers the aliases then the headers of a board.
+//
+// It must be called after host.Init(), so the GPIO pins are registered.
+func RegisterBoard(b *Board) error {
+	activeLow := make(map[string]bool, len(b.ActiveLow))
+	for _, name := range b.ActiveLow {
+		activeLow[name] = true
+	}
+	aliases := make([]string, 0, len(b.Aliases))
+	for alias := range b.Aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		var err error
+		if activeLow[alias] {
+			err = gpioreg.RegisterActiveLowAlias(alias, b.Aliases[alias])
+		} else {
+			err = gpioreg.RegisterAlias(alias, b.Aliases[alias])
+		}
+		if err != nil {
+			return fmt.Errorf("pinreg: %v", err)
+		}
+	}
+	names := make([]string, 0, len(b.Headers))
+	for name := range b.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rows := b.Headers[name]
+		allPins := make([][]pin.Pin, len(rows))
+		for i, row := range rows {
+			allPins[i] = make([]pin.Pin, len(row))
+			for j, n := range row {
+				if len(n) == 0 {
+					return fmt.Errorf("pinreg: invalid pin on header %s[%d][%d]", name, i+1, j+1)
+				}
+				allPins[i][j] = boardPin(n)
+			}
+		}
+		if err := Register(name, allPins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//
+
+// boardPin returns the pin for a name found in a board description.
+func boardPin(name string) pin.Pin {
+	if p := gpioreg.ByName(name); p != nil {
+		return p
+	}
+	for _, p := range []pin.Pin{pin.INVALID, pin.GROUND, pin.V1_8, pin.V3_3, pin.V5, pin.DC_IN, pin.BAT_PLUS} {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return &pin.BasicPin{N: name}
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pinreg
+
+import (
+	"strings"
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/pin"
+)
+
+func TestRegisterBoard(t *testing.T) {
+	defer reset()
+	led := &gpiotest.Pin{N: "BOARD100", Num: 100}
+	button := &gpiotest.Pin{N: "BOARD101", Num: 101}
+	if err := gpioreg.Register(led, true); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Unregister(led.N)
+	if err := gpioreg.Register(button, true); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Unregister(button.N)
+	const desc = `{
+		"headers": {"J1": [["V3_3", "VBAT"], ["BOARD_LED", "BOARD101"]]},
+		"aliases": {"BOARD_LED": "BOARD100", "BOARD_BUTTON": "BOARD101"},
+		"active_low": ["BOARD_LED"]
+	}`
+	b, err := ParseBoard(strings.NewReader(desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterBoard(b); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, alias := range []string{"BOARD_LED", "BOARD_BUTTON", "J1_3", "J1_4"} {
+			gpioreg.Unregister(alias)
+		}
+	}()
+	h := All()["J1"]
+	if len(h) != 2 || h[0][0] != pin.V3_3 || h[0][1].Name() != "VBAT" || h[1][1] != button {
+		t.Fatal(h)
+	}
+	if name, n := Position(led); name != "J1" || n != 3 {
+		t.Fatal(name, n)
+	}
+	if name, n := Position(gpioreg.ByName("BOARD_BUTTON")); name != "J1" || n != 4 {
+		t.Fatal(name, n)
+	}
+	if err := gpioreg.ByName("BOARD_LED").Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if led.L != gpio.Low {
+		t.Fatal("BOARD_LED is active low")
+	}
+	if err := gpioreg.ByName("J1_4").Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if button.L != gpio.High {
+		t.Fatal("BOARD_BUTTON is active high")
+	}
+	// The header is already registered.
+	if err := RegisterBoard(b); err == nil {
+		t.Fatal("expected failure")
+	}
+}
+
+func TestParseBoard_fail(t *testing.T) {
+	data := []string{
+		`{`,
+		`{"header": {}}`,
+		`{"aliases": {"A": "B"}, "active_low": ["B"]}`,
+	}
+	for i, d := range data {
+		if _, err := ParseBoard(strings.NewReader(d)); err == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+	}
+	b := &Board{Headers: map[string][][]string{"J1": {{""}}}}
+	if err := RegisterBoard(b); err == nil {
+		t.Fatal("empty pin name")
+	}
+	b = &Board{Aliases: map[string]string{"1": "BOARD100"}}
+	if err := RegisterBoard(b); err == nil {
+		t.Fatal("invalid alias")
+	}
+}
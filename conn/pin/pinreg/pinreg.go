@@ -93,6 +93,34 @@ func Register(name string, allPins [][]pin.Pin) error {
 	return nil
 }
 
+// Unregister removes a previously registered header.
+//
+// The aliases registered by Register() for the gpio pins of the header are
+// removed too.
+func Unregister(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	allPins, ok := allHeaders[name]
+	if !ok {
+		return fmt.Errorf("pinreg: can't unregister unknown header %q", name)
+	}
+	delete(allHeaders, name)
+	count := 0
+	for _, row := range allPins {
+		for _, p := range row {
+			count++
+			if pos, ok := byPin[realPin(p).Name()]; ok && pos.name == name {
+				delete(byPin, realPin(p).Name())
+			}
+			if _, ok := p.(gpio.PinIO); ok {
+				// The alias may have been unregistered already.
+				_ = gpioreg.Unregister(name + "_" + strconv.Itoa(count))
+			}
+		}
+	}
+	return nil
+}
+
 //
 
 type position struct {
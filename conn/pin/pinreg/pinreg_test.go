@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/conn/gpio/gpiotest"
 	"periph.io/x/periph/conn/pin"
 )
@@ -39,6 +40,36 @@ func TestRegister_twice(t *testing.T) {
 	}
 }
 
+func TestUnregister(t *testing.T) {
+	defer reset()
+	gpio42 := &gpiotest.Pin{N: "GPIO42", Num: 42}
+	if err := gpioreg.Register(gpio42, true); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Unregister(gpio42.N)
+	if err := Register("P9", [][]pin.Pin{{pin.GROUND, gpio42}}); err != nil {
+		t.Fatal(err)
+	}
+	if gpioreg.ByName("P9_2") == nil {
+		t.Fatal("P9_2 should be an alias")
+	}
+	if err := Unregister("P9"); err != nil {
+		t.Fatal(err)
+	}
+	if len(All()) != 0 || IsConnected(gpio42) || gpioreg.ByName("P9_2") != nil {
+		t.Fatal("P9 should be gone")
+	}
+	if Unregister("P9") == nil {
+		t.Fatal("can't unregister twice")
+	}
+	if err := Register("P9", [][]pin.Pin{{gpio42}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unregister("P9"); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestRegister_nil(t *testing.T) {
 	defer reset()
 	if err := Register("P1", [][]pin.Pin{{nil}}); err == nil {
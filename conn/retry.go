@@ -0,0 +1,126 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conn
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// IsTransient returns true if err is a failure that may not happen again when
+// the operation is retried: ErrTimeout or ErrBusBusy.
+//
+// ErrNotAcknowledged is not transient as it is what an absent or unpowered
+// device returns; see Retry.NotAcknowledged.
+func IsTransient(err error) bool {
+	return IsKind(err, ErrTimeout) || IsKind(err, ErrBusBusy)
+}
+
+// Retry is a policy to retry operations that failed with a transient error.
+//
+// The zero value is a valid policy: 3 attempts without delay, retrying the
+// errors for which IsTransient() returns true.
+type Retry struct {
+	// Attempts is the maximum number of attempts, including the first one.
+	// Defaults to 3.
+	Attempts int
+	// Backoff is the delay before the first retry. It is doubled at each
+	// subsequent retry.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between two attempts. 0 means no cap.
+	MaxBackoff time.Duration
+	// Transient returns true if the error is worth retrying. Defaults to
+	// IsTransient.
+	Transient func(err error) bool
+	// NotAcknowledged also retries the errors of kind ErrNotAcknowledged, for
+	// devices that don't acknowledge while busy, like an EEPROM during its
+	// write cycle. It is ignored when Transient is set.
+	NotAcknowledged bool
+}
+
+// Do calls f until it succeeds, it returns an error that is not transient or
+// the attempts are exhausted. It returns the last error returned by f.
+//
+// Do gives up waiting between two attempts when ctx is done and returns
+// ctx.Err().
+func (r *Retry) Do(ctx context.Context, f func() error) error {
+	attempts := r.Attempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	transient := r.Transient
+	if transient == nil {
+		transient = IsTransient
+		if r.NotAcknowledged {
+			transient = func(err error) bool {
+				return IsTransient(err) || IsKind(err, ErrNotAcknowledged)
+			}
+		}
+	}
+	backoff := r.Backoff
+	for i := 1; ; i++ {
+		err := f()
+		if err == nil || i >= attempts || !transient(err) {
+			return err
+		}
+		if backoff <= 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+		if backoff *= 2; r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+			backoff = r.MaxBackoff
+		}
+	}
+}
+
+// WithRetry returns a Conn that retries the transactions on c that failed
+// with a transient error, as specified by r.
+//
+// If r is nil, the zero value of Retry is used. The content of the read buffer
+// is undefined when the transaction eventually fails.
+//
+// This is useful to use an existing device driver over a noisy bus, like long
+// cables.
+func WithRetry(c Conn, r *Retry) Conn {
+	if r == nil {
+		r = &Retry{}
+	}
+	return &retryConn{c: c, r: r}
+}
+
+// retryConn implements Conn with the transactions retried.
+type retryConn struct {
+	c Conn
+	r *Retry
+}
+
+func (r *retryConn) String() string {
+	return fmt.Sprint(r.c)
+}
+
+func (r *retryConn) Tx(w, read []byte) error {
+	return r.r.Do(context.Background(), func() error { return r.c.Tx(w, read) })
+}
+
+func (r *retryConn) TxContext(ctx context.Context, w, read []byte) error {
+	return r.r.Do(ctx, func() error { return TxContext(ctx, r.c, w, read) })
+}
+
+func (r *retryConn) Duplex() Duplex {
+	return r.c.Duplex()
+}
+
+var _ Conn = &retryConn{}
+var _ TxContexter = &retryConn{}
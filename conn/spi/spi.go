@@ -6,6 +6,8 @@
 package spi
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"strconv"
 
@@ -113,6 +115,54 @@ type Conn interface {
 	TxPackets(p []Packet) error
 }
 
+// TxContexter is implemented by a Conn that natively supports a context for
+// its transactions.
+//
+// Users should call TxContext() and TxPacketsContext() instead of asserting
+// this interface directly.
+type TxContexter interface {
+	conn.TxContexter
+	// TxPacketsContext does multiple operations like Conn.TxPackets() but
+	// returns ctx.Err() without starting them if ctx is already done.
+	//
+	// Whether the operations already started are interrupted when ctx is done
+	// depends on the implementation, which must document it.
+	TxPacketsContext(ctx context.Context, p []Packet) error
+}
+
+// TxContext does a single transaction on c unless ctx is already done.
+//
+// It's a shorthand for conn.TxContext().
+func TxContext(ctx context.Context, c Conn, w, r []byte) error {
+	return conn.TxContext(ctx, c, w, r)
+}
+
+// TxPacketsContext does multiple operations on c unless ctx is already done.
+//
+// If c implements TxContexter, its TxPacketsContext() method is used.
+// Otherwise the packets are sent with c.TxPackets(), which cannot be
+// interrupted once started; its result is returned even if ctx is done in
+// the meantime.
+func TxPacketsContext(ctx context.Context, c Conn, p []Packet) error {
+	if t, ok := c.(TxContexter); ok {
+		return t.TxPacketsContext(ctx, p)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.TxPackets(p)
+}
+
+// WithContext returns a Port that returns connections running every
+// transaction through TxContext() or TxPacketsContext() with ctx.
+//
+// This is useful to apply a deadline to the I/O done by an existing device
+// driver that doesn't accept a context.Context. Once ctx is done, the next
+// transactions fail; the one in flight completes.
+func WithContext(ctx context.Context, p Port) Port {
+	return &ctxPort{ctx: ctx, p: p}
+}
+
 // Port is the interface to be provided to device drivers.
 //
 // The device driver, that is the driver for the peripheral connected over
@@ -166,3 +216,56 @@ type Pins interface {
 	// CS returns the CSN (chip select) pin.
 	CS() gpio.PinOut
 }
+
+//
+
+// ctxPort implements Port with the connections bound to a context.
+type ctxPort struct {
+	ctx context.Context
+	p   Port
+}
+
+func (c *ctxPort) String() string {
+	return fmt.Sprint(c.p)
+}
+
+func (c *ctxPort) Connect(maxHz int64, mode Mode, bits int) (Conn, error) {
+	cc, err := c.p.Connect(maxHz, mode, bits)
+	if err != nil {
+		return nil, err
+	}
+	return &ctxConn{ctx: c.ctx, c: cc}, nil
+}
+
+// ctxConn implements Conn with the transactions bound to a context.
+type ctxConn struct {
+	ctx context.Context
+	c   Conn
+}
+
+func (c *ctxConn) String() string {
+	return fmt.Sprint(c.c)
+}
+
+func (c *ctxConn) Tx(w, r []byte) error {
+	return TxContext(c.ctx, c.c, w, r)
+}
+
+func (c *ctxConn) TxPackets(p []Packet) error {
+	return TxPacketsContext(c.ctx, c.c, p)
+}
+
+func (c *ctxConn) Duplex() conn.Duplex {
+	return c.c.Duplex()
+}
+
+func (c *ctxConn) MaxTxSize() int {
+	if l, ok := c.c.(conn.Limits); ok {
+		return l.MaxTxSize()
+	}
+	return 0
+}
+
+var _ Port = &ctxPort{}
+var _ Conn = &ctxConn{}
+var _ conn.Limits = &ctxConn{}
@@ -5,8 +5,12 @@
 package spi
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"testing"
+
+	"periph.io/x/periph/conn"
 )
 
 func ExamplePins() {
@@ -39,3 +43,77 @@ func TestMode_String(t *testing.T) {
 		t.Fatal(s)
 	}
 }
+
+func TestTxPacketsContext(t *testing.T) {
+	c := &fakeConn{r: []byte{1, 2}}
+	p := []Packet{{W: []byte{3}}, {R: make([]byte, 2)}}
+	if err := TxPacketsContext(context.Background(), c, p); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := TxPacketsContext(ctx, c, p); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p[1].R, []byte{1, 2}) || len(c.p) != 4 {
+		t.Fatal(p[1].R, c.p)
+	}
+	cancel()
+	if err := TxPacketsContext(ctx, c, p); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if len(c.p) != 4 {
+		t.Fatal(c.p)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := WithContext(ctx, &fakePort{})
+	c, err := p.Connect(0, Mode0, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Tx([]byte{1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if l := c.(conn.Limits).MaxTxSize(); l != 0 {
+		t.Fatal(l)
+	}
+	cancel()
+	if err := c.Tx([]byte{1}, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := c.TxPackets(nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+}
+
+//
+
+type fakePort struct{}
+
+func (f *fakePort) Connect(maxHz int64, mode Mode, bits int) (Conn, error) {
+	return &fakeConn{}, nil
+}
+
+type fakeConn struct {
+	r []byte
+	p []Packet
+}
+
+func (f *fakeConn) Tx(w, r []byte) error {
+	copy(r, f.r)
+	return nil
+}
+
+func (f *fakeConn) TxPackets(p []Packet) error {
+	for i := range p {
+		copy(p[i].R, f.r)
+	}
+	f.p = append(f.p, p...)
+	return nil
+}
+
+func (f *fakeConn) Duplex() conn.Duplex {
+	return conn.Full
+}
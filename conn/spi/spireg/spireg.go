@@ -63,9 +63,11 @@ type Ref struct {
 func Open(name string) (spi.PortCloser, error) {
 	var r *Ref
 	var err error
+	var w Wrapper
 	func() {
 		mu.Lock()
 		defer mu.Unlock()
+		w = wrapper
 		if len(byName) == 0 {
 			err = wrapf("no port found; did you forget to call Init()?")
 			return
@@ -89,7 +91,26 @@ func Open(name string) (spi.PortCloser, error) {
 	if r == nil {
 		return nil, wrapf("can't open unknown port: %q", name)
 	}
-	return r.Open()
+	h, err := r.Open()
+	if err != nil || w == nil {
+		return h, err
+	}
+	return w(r.Name, h), nil
+}
+
+// Wrapper wraps a port handle returned by Open().
+//
+// name is the port name, even if it was opened by an alias or its number.
+type Wrapper func(name string, h spi.PortCloser) spi.PortCloser
+
+// SetWrapper sets a function to wrap every port handle returned by Open(), for
+// example to instrument all the ports transparently.
+//
+// Use nil to remove it. It doesn't affect the handles already opened.
+func SetWrapper(w Wrapper) {
+	mu.Lock()
+	defer mu.Unlock()
+	wrapper = w
 }
 
 // All returns a copy of all the registered references to all know SPI ports
@@ -213,6 +234,7 @@ var (
 	// Caches
 	byNumber = map[int]*Ref{}
 	byAlias  = map[string]*Ref{}
+	wrapper  Wrapper
 )
 
 // getDefault returns the Ref that should be used as the default port.
@@ -6,6 +6,7 @@
 package spitest
 
 import (
+	"context"
 	"io"
 	"log"
 	"sync"
@@ -16,6 +17,23 @@ import (
 	"periph.io/x/periph/conn/spi"
 )
 
+// Save writes ops as a trace to w.
+//
+// Use it to save the Ops of a Record as a golden file, to be loaded back with
+// Load into a Playback. See conntest.TraceVersion for the format.
+func Save(w io.Writer, ops []conntest.IO) error {
+	return conntest.WriteTrace(w, "spi", len(ops), func(i int) interface{} { return ops[i] })
+}
+
+// Load reads a trace written by Save.
+func Load(r io.Reader) ([]conntest.IO, error) {
+	var ops []conntest.IO
+	if err := conntest.ReadTrace(r, "spi", &ops); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
 // RecordRaw implements spi.PortCloser.
 //
 // It sends everything written to it to W.
@@ -72,6 +90,17 @@ func (r *recordRawConn) TxPackets(p []spi.Packet) error {
 	return conntest.Errorf("spitest: TxPackets is not implemented")
 }
 
+func (r *recordRawConn) TxContext(ctx context.Context, w, read []byte) error {
+	return r.r.TxContext(ctx, w, read)
+}
+
+func (r *recordRawConn) TxPacketsContext(ctx context.Context, p []spi.Packet) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.TxPackets(p)
+}
+
 //
 
 // Record implements spi.PortCloser that records everything written to it.
@@ -154,7 +183,7 @@ func (r *Record) CS() gpio.PinOut {
 	return gpio.INVALID
 }
 
-func (r *Record) txInternal(c spi.Conn, w, read []byte) error {
+func (r *Record) txInternal(ctx context.Context, c spi.Conn, w, read []byte) error {
 	io := conntest.IO{}
 	if len(w) != 0 {
 		io.W = make([]byte, len(w))
@@ -167,7 +196,7 @@ func (r *Record) txInternal(c spi.Conn, w, read []byte) error {
 			return conntest.Errorf("spitest: read unsupported when no port is connected")
 		}
 	} else {
-		if err := c.Tx(w, read); err != nil {
+		if err := spi.TxContext(ctx, c, w, read); err != nil {
 			return err
 		}
 	}
@@ -198,7 +227,7 @@ func (r *recordConn) Duplex() conn.Duplex {
 }
 
 func (r *recordConn) Tx(w, read []byte) error {
-	return r.r.txInternal(r.c, w, read)
+	return r.r.txInternal(context.Background(), r.c, w, read)
 }
 
 // TxPackets is not yet implemented.
@@ -206,6 +235,25 @@ func (r *recordConn) TxPackets(p []spi.Packet) error {
 	return conntest.Errorf("spitest: TxPackets is not implemented")
 }
 
+// TxContext implements spi.TxContexter.
+//
+// The I/O is not recorded if the transaction fails, including when ctx is
+// already done.
+func (r *recordConn) TxContext(ctx context.Context, w, read []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.r.txInternal(ctx, r.c, w, read)
+}
+
+// TxPacketsContext implements spi.TxContexter.
+func (r *recordConn) TxPacketsContext(ctx context.Context, p []spi.Packet) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.TxPackets(p)
+}
+
 // CLK implements spi.Pins.
 func (r *recordConn) CLK() gpio.PinOut {
 	return r.r.CLK()
@@ -302,6 +350,17 @@ func (p *playbackConn) TxPackets(packets []spi.Packet) error {
 	return conntest.Errorf("spitest: TxPackets is not implemented")
 }
 
+func (p *playbackConn) TxContext(ctx context.Context, w, r []byte) error {
+	return p.p.TxContext(ctx, w, r)
+}
+
+func (p *playbackConn) TxPacketsContext(ctx context.Context, packets []spi.Packet) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.TxPackets(packets)
+}
+
 func (p *playbackConn) CLK() gpio.PinOut {
 	return p.p.CLK()
 }
@@ -378,3 +437,6 @@ var _ spi.PortCloser = &Playback{}
 var _ spi.PortCloser = &Log{}
 var _ spi.Pins = &Record{}
 var _ spi.Pins = &Playback{}
+var _ spi.TxContexter = &recordRawConn{}
+var _ spi.TxContexter = &recordConn{}
+var _ spi.TxContexter = &playbackConn{}
@@ -6,6 +6,7 @@ package spitest
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"log"
 	"testing"
@@ -175,6 +176,36 @@ func TestPlayback_Tx(t *testing.T) {
 	}
 }
 
+func TestPlayback_TxContext(t *testing.T) {
+	p := Playback{
+		Playback: conntest.Playback{
+			Ops: []conntest.IO{{W: []byte{10}, R: []byte{12}}},
+		},
+	}
+	c, err := p.Connect(0, spi.Mode0, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	v := [1]byte{}
+	if err := spi.TxContext(ctx, c, []byte{10}, v[:]); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := spi.TxPacketsContext(ctx, c, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := spi.TxContext(context.Background(), c, []byte{10}, v[:]); err != nil {
+		t.Fatal(err)
+	}
+	if v[0] != 12 {
+		t.Fatalf("expected 12, got %v", v)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestRecord_Playback(t *testing.T) {
 	r := Record{
 		Port: &Playback{
@@ -276,3 +307,31 @@ func TestLog_Playback(t *testing.T) {
 func init() {
 	log.SetOutput(ioutil.Discard)
 }
+
+func TestSave_Load(t *testing.T) {
+	ops := []conntest.IO{{W: []byte{0x80, 0}, R: []byte{0, 0x58}}}
+	var b bytes.Buffer
+	if err := Save(&b, ops); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "{\"version\":1,\"kind\":\"spi\",\"ops\":[\n{\"w\":\"8000\",\"r\":\"0058\"}\n]}\n"
+	if s := b.String(); s != expected {
+		t.Fatalf("%q", s)
+	}
+	got, err := Load(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Playback{Playback: conntest.Playback{Ops: got}}
+	c, err := p.Connect(10000, spi.Mode0, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := [2]byte{}
+	if err := c.Tx([]byte{0x80, 0}, v[:]); err != nil || v != [2]byte{0, 0x58} {
+		t.Fatal(err, v)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
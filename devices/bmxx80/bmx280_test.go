@@ -13,7 +13,9 @@ import (
 	"testing"
 	"time"
 
+	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/i2c"
 	"periph.io/x/periph/conn/i2c/i2creg"
 	"periph.io/x/periph/conn/i2c/i2ctest"
 	"periph.io/x/periph/conn/spi"
@@ -176,6 +178,66 @@ func TestNewI2CBME280_fail_io(t *testing.T) {
 	}
 }
 
+func TestNewI2CBME280_fail_bitflip(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			// Chip ID detection.
+			{Addr: 0x76, W: []byte{0xd0}, R: []byte{0x60}},
+		},
+	}
+	f := i2ctest.Faulty{Bus: &bus, Faults: conntest.Faults{FlipRate: 1}}
+	if dev, err := NewI2C(&f, 0x76, nil); dev != nil || err == nil {
+		t.Fatal("chip id should be corrupted")
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestI2CSenseBMP280_faulty(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			// Chip ID detection.
+			{Addr: 0x76, W: []byte{0xd0}, R: []byte{0x58}},
+			// Calibration data.
+			{
+				Addr: 0x76,
+				W:    []byte{0x88},
+				R:    []byte{0x10, 0x6e, 0x6c, 0x66, 0x32, 0x0, 0x5d, 0x95, 0xb8, 0xd5, 0xd0, 0xb, 0x77, 0x1e, 0x9d, 0xff, 0xf9, 0xff, 0xac, 0x26, 0xa, 0xd8, 0xbd, 0x10, 0x0, 0x4b},
+			},
+			// Configuration.
+			{Addr: 0x76, W: []byte{0xf4, 0x6c, 0xf5, 0xa0, 0xf4, 0x6c}, R: nil},
+			// Forced mode.
+			{Addr: 0x76, W: []byte{0xF4, 0x6d}},
+			// Check if idle.
+			{Addr: 0x76, W: []byte{0xF3}, R: []byte{0}},
+			// Read.
+			{Addr: 0x76, W: []byte{0xf7}, R: []byte{0x4a, 0x52, 0xc0, 0x80, 0x96, 0xc0}},
+		},
+	}
+	f := i2ctest.Faulty{Bus: &bus, Faults: conntest.Faults{Seed: 1, ErrorRate: 1}}
+	if _, err := NewI2C(&f, 0x76, nil); err == nil {
+		t.Fatal("expected injected error")
+	}
+	// Transient errors are recovered by retrying. The failed transactions are
+	// not sent to the bus.
+	f.ErrorRate = 0.3
+	dev, err := NewI2C(i2c.WithRetry(&f, &conn.Retry{Attempts: 10}), 0x76, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := devices.Environment{}
+	if err := dev.Sense(&env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Temperature != 23720 {
+		t.Fatalf("temp %d", env.Temperature)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestNewI2CBME280_fail_read_calib1(t *testing.T) {
 	bus := i2ctest.Playback{
 		Ops: []i2ctest.IO{
@@ -403,6 +465,43 @@ func TestI2CSenseBME280_success(t *testing.T) {
 	}
 }
 
+func TestI2CSenseBME280_sim(t *testing.T) {
+	bme280 := newBME280Sim()
+	bus := i2ctest.Sim{Devices: map[uint16]i2ctest.Device{0x76: bme280}}
+	dev, err := NewI2C(&bus, 0x76, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := dev.String(); s != "BME280{sim(118)}" {
+		t.Fatal(s)
+	}
+	for i := 0; i < 2; i++ {
+		env := devices.Environment{}
+		if err := dev.Sense(&env); err != nil {
+			t.Fatal(err)
+		}
+		if env.Temperature != 23720 {
+			t.Fatalf("temp %d", env.Temperature)
+		}
+		if env.Pressure != 100943 {
+			t.Fatalf("pressure %d", env.Pressure)
+		}
+		if env.Humidity != 6531 {
+			t.Fatalf("humidity %d", env.Humidity)
+		}
+	}
+	if err := dev.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	// Back to sleep mode after the forced measurement.
+	if m := bme280.Data[0xf4] & 3; m != 0 {
+		t.Fatal(m)
+	}
+	if _, err := NewI2C(&bus, 0x77, nil); err == nil {
+		t.Fatal("no device at 0x77")
+	}
+}
+
 func TestI2CSense280_idle_fail(t *testing.T) {
 	bus := i2ctest.Playback{
 		Ops: []i2ctest.IO{
@@ -922,3 +1021,41 @@ type spiFail struct {
 func (s *spiFail) Connect(maxHz int64, mode spi.Mode, bits int) (spi.Conn, error) {
 	return nil, errors.New("failing")
 }
+
+// newBME280Sim returns a register level model of a BME280 loaded with real
+// data extracted from a device.
+func newBME280Sim() *i2ctest.Regs {
+	d := &i2ctest.Regs{
+		Data:       make([]byte, 256),
+		Flags:      map[uint8]i2ctest.RegFlag{0xd0: i2ctest.ReadOnly, 0xe0: i2ctest.WriteOnly, 0xf3: i2ctest.ReadOnly | i2ctest.ClearOnRead},
+		WritePairs: true,
+	}
+	d.Data[0xd0] = 0x60
+	copy(d.Data[0x88:], []byte{0x10, 0x6e, 0x6c, 0x66, 0x32, 0x0, 0x5d, 0x95, 0xb8, 0xd5, 0xd0, 0xb, 0x77, 0x1e, 0x9d, 0xff, 0xf9, 0xff, 0xac, 0x26, 0xa, 0xd8, 0xbd, 0x10, 0x0, 0x4b})
+	copy(d.Data[0xe1:], []byte{0x6e, 0x1, 0x0, 0x13, 0x5, 0x0, 0x1e})
+	for i := 0x88; i < 0xa2; i++ {
+		d.Flags[uint8(i)] = i2ctest.ReadOnly
+	}
+	for i := 0xe1; i < 0xe8; i++ {
+		d.Flags[uint8(i)] = i2ctest.ReadOnly
+	}
+	for i := 0xf7; i < 0xff; i++ {
+		d.Flags[uint8(i)] = i2ctest.ReadOnly
+	}
+	d.OnWrite = func(reg uint8, v byte) error {
+		if reg == 0xf4 && v&3 == 1 {
+			// Forced mode; measuring until the status register is read.
+			d.Data[0xf3] = 8
+			copy(d.Data[0xf7:], []byte{0x4a, 0x52, 0xc0, 0x80, 0x96, 0xc0, 0x7a, 0x76})
+		}
+		return nil
+	}
+	d.OnRead = func(reg uint8) error {
+		if reg == 0xf3 && d.Data[0xf3]&8 != 0 {
+			// The measurement is done; back to sleep mode.
+			d.Data[0xf4] &^= 3
+		}
+		return nil
+	}
+	return d
+}
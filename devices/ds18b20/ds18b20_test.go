@@ -8,6 +8,8 @@ import (
 	"testing"
 	"time"
 
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/conntest"
 	"periph.io/x/periph/conn/onewire"
 	"periph.io/x/periph/conn/onewire/onewiretest"
 	"periph.io/x/periph/devices"
@@ -29,6 +31,53 @@ func TestNew_fail_read(t *testing.T) {
 	}
 }
 
+func TestNew_fail_crc(t *testing.T) {
+	ops := []onewiretest.IO{
+		// Match ROM + Read Scratchpad (init)
+		{
+			W: []uint8{0x55, 0x28, 0xac, 0x41, 0xe, 0x7, 0x0, 0x0, 0x74, 0xbe},
+			R: []uint8{0xe0, 0x1, 0x0, 0x0, 0x3f, 0xff, 0x10, 0x10, 0x3f},
+		},
+	}
+	bus := onewiretest.Playback{Ops: ops}
+	f := onewiretest.Faulty{Bus: &bus, Faults: conntest.Faults{FlipRate: 1}}
+	var addr onewire.Address = 0x740000070e41ac28
+	d, err := New(&f, addr, 10)
+	if d != nil || err == nil {
+		t.Fatal("expected CRC error")
+	}
+	if b, ok := err.(onewire.BusError); !ok || !b.BusError() {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTemperature_fail_io(t *testing.T) {
+	ops := []onewiretest.IO{
+		// Match ROM + Read Scratchpad (init)
+		{
+			W: []uint8{0x55, 0x28, 0xac, 0x41, 0xe, 0x7, 0x0, 0x0, 0x74, 0xbe},
+			R: []uint8{0xe0, 0x1, 0x0, 0x0, 0x3f, 0xff, 0x10, 0x10, 0x3f},
+		},
+	}
+	bus := onewiretest.Playback{Ops: ops}
+	f := onewiretest.Faulty{Bus: &bus}
+	var addr onewire.Address = 0x740000070e41ac28
+	dev, err := New(&f, addr, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ErrorRate = 1
+	if _, err := dev.Temperature(); !conn.IsKind(err, conn.ErrBusBusy) {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestTemperature tests a temperature conversion on a ds18b20 using
 // recorded bus transactions.
 func TestTemperature(t *testing.T) {
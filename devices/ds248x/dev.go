@@ -61,7 +61,7 @@ func (d *Dev) Tx(w, r []byte, power onewire.Pullup) error {
 	if present, err := d.reset(); err != nil {
 		return err
 	} else if !present {
-		return busError("ds248x: no device present")
+		return presenceError("ds248x: no device present")
 	}
 
 	// Send bytes onto 1-wire bus.
@@ -175,7 +175,7 @@ func (d *Dev) waitIdle(delay time.Duration) byte {
 		// If we're timing out return error. This is an error with the ds248x, not with
 		// devices on the 1-wire bus, hence it is persistent.
 		if time.Now().After(tOut) {
-			d.err = fmt.Errorf("ds248x: timeout waiting for bus cycle to finish")
+			d.err = &conn.Error{Kind: conn.ErrTimeout, Msg: "ds248x: timeout waiting for bus cycle to finish"}
 			return 0
 		}
 		// Try not to hog the kernel thread.
@@ -190,11 +190,15 @@ func (e shortedBusError) Error() string   { return string(e) }
 func (e shortedBusError) IsShorted() bool { return true }
 func (e shortedBusError) BusError() bool  { return true }
 
-// busError implements error and onewire.BusError.
-type busError string
+// presenceError implements error and onewire.BusError. It is returned when no
+// device answered the reset pulse.
+type presenceError string
 
-func (e busError) Error() string  { return string(e) }
-func (e busError) BusError() bool { return true }
+func (e presenceError) Error() string  { return string(e) }
+func (e presenceError) BusError() bool { return true }
+
+// Is returns true for conn.ErrNotAcknowledged.
+func (e presenceError) Is(target error) bool { return target == conn.ErrNotAcknowledged }
 
 var _ conn.Resource = &Dev{}
 var _ fmt.Stringer = &Dev{}
@@ -16,6 +16,7 @@ import (
 	"fmt"
 	"time"
 
+	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/i2c"
 )
 
@@ -104,13 +105,13 @@ func (d *Dev) makeDev(opts *Opts) error {
 
 	// Issue a reset command.
 	if err := d.i2c.Tx([]byte{cmdReset}, nil); err != nil {
-		return fmt.Errorf("ds248x: error while resetting: %s", err)
+		return wrapErr("error while resetting", err)
 	}
 
 	// Read the status register to confirm that we have a responding ds248x
 	var stat [1]byte
 	if err := d.i2c.Tx([]byte{cmdSetReadPtr, regStatus}, stat[:]); err != nil {
-		return fmt.Errorf("ds248x: error while reading status register: %s", err)
+		return wrapErr("error while reading status register", err)
 	}
 	if stat[0] != 0x18 {
 		return fmt.Errorf("ds248x: invalid status register value: %#x, expected 0x18", stat[0])
@@ -124,7 +125,7 @@ func (d *Dev) makeDev(opts *Opts) error {
 	}
 	var dcr [1]byte
 	if err := d.i2c.Tx([]byte{cmdWriteConfig, d.confReg}, dcr[:]); err != nil {
-		return fmt.Errorf("ds248x: error while writing device config register: %s", err)
+		return wrapErr("error while writing device config register", err)
 	}
 	// When reading back we only get the bottom nibble
 	if dcr[0] != d.confReg&0x0f {
@@ -147,7 +148,7 @@ func (d *Dev) makeDev(opts *Opts) error {
 			byte(0x80 + (opts.PullupRes & 0x0f)),
 		}
 		if err := d.i2c.Tx(buf, nil); err != nil {
-			return fmt.Errorf("ds248x: error while setting port config values: %s", err)
+			return wrapErr("error while setting port config values", err)
 		}
 	}
 
@@ -170,3 +171,11 @@ const (
 	regRDR    = 0xe1 // read ptr for read-data register
 	regPCR    = 0xb4 // read ptr for port configuration register
 )
+
+// wrapErr prefixes err with msg, preserving the kind of a *conn.Error.
+func wrapErr(msg string, err error) error {
+	if e, ok := err.(*conn.Error); ok {
+		return &conn.Error{Kind: e.Kind, Msg: "ds248x: " + msg, Err: err}
+	}
+	return fmt.Errorf("ds248x: %s: %v", msg, err)
+}
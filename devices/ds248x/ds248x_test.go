@@ -9,8 +9,10 @@ import (
 	"log"
 	"testing"
 
+	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/i2c/i2creg"
 	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/onewire"
 )
 
 func Example() {
@@ -84,6 +86,60 @@ func TestNew_opts(t *testing.T) {
 	}
 }
 
+func TestTx_noDevice(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x18, W: []byte{0xf0}},
+			{Addr: 0x18, W: []byte{0xe1, 0xf0}, R: []byte{0x18}},
+			{Addr: 0x18, W: []byte{0xd2, 0xe1}, R: []byte{0x1}},
+			{Addr: 0x18, W: []byte{0xe1, 0xb4}},
+			{Addr: 0x18, W: []byte{0xc3, 0x6, 0x26, 0x46, 0x66, 0x86}},
+			// Reset without presence pulse.
+			{Addr: 0x18, W: []byte{0xb4}},
+			{Addr: 0x18, R: []byte{0x00}},
+		},
+	}
+	d, err := New(&bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Tx([]byte{0xcc}, nil, onewire.WeakPullup)
+	if !conn.IsKind(err, conn.ErrNotAcknowledged) {
+		t.Fatal(err)
+	}
+	if b, ok := err.(onewire.BusError); !ok || !b.BusError() {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTx_timeout(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x18, W: []byte{0xf0}},
+			{Addr: 0x18, W: []byte{0xe1, 0xf0}, R: []byte{0x18}},
+			{Addr: 0x18, W: []byte{0xd2, 0xe1}, R: []byte{0x1}},
+			{Addr: 0x18, W: []byte{0xe1, 0xb4}},
+			{Addr: 0x18, W: []byte{0xc3, 0x6, 0x26, 0x46, 0x66, 0x86}},
+			// Reset, the 1-wire bus stays busy.
+			{Addr: 0x18, W: []byte{0xb4}},
+			{Addr: 0x18, R: []byte{0x01}, Repeat: 1000000},
+		},
+	}
+	d, err := New(&bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Tx([]byte{0xcc}, nil, onewire.WeakPullup); !conn.IsKind(err, conn.ErrTimeout) {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 /* Commented out in order not to import periph/host, need to move to smoke test
 // TestRecordInit tests and records the initialization of a ds248x by accessing
 // real hardware and outputs the recording ready to use for playback in
@@ -226,6 +226,30 @@ func TestI2C_Write_fail(t *testing.T) {
 	}
 }
 
+func TestI2C_Write_faulty(t *testing.T) {
+	bus := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			// Startup initialization.
+			{Addr: 0x3c, W: initCmdI2C()},
+		},
+	}
+	f := i2ctest.Faulty{Bus: &bus}
+	dev, err := NewI2C(&f, 128, 64, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ErrorRate = 1
+	if n, err := dev.Write(make([]byte, 1024)); n != 0 || err != conntest.ErrInjected {
+		t.Fatalf("expected injected error: %v", err)
+	}
+	if err := dev.Halt(); err != conntest.ErrInjected {
+		t.Fatalf("expected injected error: %v", err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestI2C_Draw_fail(t *testing.T) {
 	bus := i2ctest.Playback{
 		Ops: []i2ctest.IO{
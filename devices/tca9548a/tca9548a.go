@@ -0,0 +1,226 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package tca9548a controls a TCA9548A or PCA954x I²C multiplexer.
+//
+// Each downstream channel is exposed as its own i2c.BusCloser, so that device
+// drivers can be used unchanged behind the multiplexer. Only one channel is
+// enabled at a time; the multiplexer is switched on demand before each
+// transaction.
+//
+// The TCA9548A, PCA9548A (8 channels), TCA9546A, PCA9546A, PCA9545A (4
+// channels) and PCA9543A (2 channels) are supported. The PCA9542A and PCA9544A
+// use a different control register layout and are not supported.
+//
+// Datasheet
+//
+// http://www.ti.com/lit/ds/symlink/tca9548a.pdf
+package tca9548a
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+)
+
+// Opts contains options to pass to the constructor.
+type Opts struct {
+	Addr     uint16 // I²C address, 0x70 to 0x77; default 0x70
+	Channels int    // Number of downstream channels, 2, 4 or 8; default 8
+}
+
+// New returns a handle to a multiplexer connected on the upstream bus b.
+//
+// It reads the control register to confirm the device is present.
+func New(b i2c.Bus, opts *Opts) (*Dev, error) {
+	d := &Dev{c: i2c.Dev{Bus: b, Addr: 0x70}, channels: 8}
+	if opts != nil {
+		switch {
+		case opts.Addr == 0:
+		case opts.Addr >= 0x70 && opts.Addr <= 0x77:
+			d.c.Addr = opts.Addr
+		default:
+			return nil, errors.New("tca9548a: given address not supported by device")
+		}
+		switch opts.Channels {
+		case 0:
+		case 2, 4, 8:
+			d.channels = opts.Channels
+		default:
+			return nil, errors.New("tca9548a: number of channels must be 2, 4 or 8")
+		}
+	}
+	var ctl [1]byte
+	if err := d.c.Tx(nil, ctl[:]); err != nil {
+		return nil, fmt.Errorf("tca9548a: error while reading control register: %v", err)
+	}
+	d.ctl = ctl[0]
+	d.valid = true
+	return d, nil
+}
+
+// Dev is a handle to a multiplexer.
+//
+// It is safe to use the channels concurrently from multiple goroutines; each
+// transaction selects its channel and runs to completion while holding a lock
+// on the multiplexer.
+type Dev struct {
+	c        i2c.Dev
+	channels int
+
+	mu         sync.Mutex
+	ctl        byte // Last value written to the control register.
+	valid      bool // false if ctl may not reflect the device's state.
+	registered []string
+}
+
+func (d *Dev) String() string {
+	return fmt.Sprintf("TCA9548A{%s}", &d.c)
+}
+
+// Channels returns the number of downstream channels.
+func (d *Dev) Channels() int {
+	return d.channels
+}
+
+// Bus returns the downstream bus for channel ch, which starts at 0.
+//
+// Closing the returned bus doesn't close the upstream bus.
+func (d *Dev) Bus(ch int) (i2c.BusCloser, error) {
+	if ch < 0 || ch >= d.channels {
+		return nil, fmt.Errorf("tca9548a: invalid channel %d", ch)
+	}
+	return &channel{d: d, ch: uint8(ch)}, nil
+}
+
+// Register registers every channel in i2creg.
+//
+// Each channel is named after the upstream bus, the multiplexer address and
+// the channel number, e.g. "I2C1-mux70-3".
+func (d *Dev) Register() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.registered != nil {
+		return errors.New("tca9548a: already registered")
+	}
+	for ch := 0; ch < d.channels; ch++ {
+		c := &channel{d: d, ch: uint8(ch)}
+		name := c.String()
+		if err := i2creg.Register(name, nil, -1, func() (i2c.BusCloser, error) { return c, nil }); err != nil {
+			for _, n := range d.registered {
+				i2creg.Unregister(n)
+			}
+			d.registered = nil
+			return err
+		}
+		d.registered = append(d.registered, name)
+	}
+	return nil
+}
+
+// Unregister removes the channels registered by Register from i2creg.
+func (d *Dev) Unregister() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	for _, n := range d.registered {
+		if err1 := i2creg.Unregister(n); err == nil {
+			err = err1
+		}
+	}
+	d.registered = nil
+	return err
+}
+
+// Halt implements conn.Resource.
+//
+// It disables all the downstream channels.
+func (d *Dev) Halt() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.setCtl(0)
+}
+
+//
+
+// setCtl writes the control register if needed.
+//
+// d.mu must be held.
+func (d *Dev) setCtl(v byte) error {
+	if d.valid && d.ctl == v {
+		return nil
+	}
+	if err := d.c.Tx([]byte{v}, nil); err != nil {
+		d.valid = false
+		return fmt.Errorf("tca9548a: error while selecting channel: %v", err)
+	}
+	d.ctl = v
+	d.valid = true
+	return nil
+}
+
+// channel is a downstream bus.
+type channel struct {
+	d  *Dev
+	ch uint8
+}
+
+func (c *channel) String() string {
+	return fmt.Sprintf("%s-mux%02x-%d", c.d.c.Bus, c.d.c.Addr, c.ch)
+}
+
+// Close implements i2c.BusCloser.
+func (c *channel) Close() error {
+	return nil
+}
+
+// Tx implements i2c.Bus.
+func (c *channel) Tx(addr uint16, w, r []byte) error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if err := c.d.setCtl(1 << c.ch); err != nil {
+		return err
+	}
+	return c.d.c.Bus.Tx(addr, w, r)
+}
+
+// TxContext implements i2c.TxContexter.
+func (c *channel) TxContext(ctx context.Context, addr uint16, w, r []byte) error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.d.setCtl(1 << c.ch); err != nil {
+		return err
+	}
+	return i2c.TxContext(ctx, c.d.c.Bus, addr, w, r)
+}
+
+// TxMessages implements i2c.TxMessager.
+func (c *channel) TxMessages(msgs []i2c.Msg) error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if err := c.d.setCtl(1 << c.ch); err != nil {
+		return err
+	}
+	return i2c.TxMessages(c.d.c.Bus, msgs)
+}
+
+// SetSpeed implements i2c.Bus.
+//
+// It changes the speed of the upstream bus, thus affecting all the channels.
+func (c *channel) SetSpeed(hz int64) error {
+	return c.d.c.Bus.SetSpeed(hz)
+}
+
+var _ conn.Resource = &Dev{}
+var _ i2c.BusCloser = &channel{}
+var _ i2c.TxContexter = &channel{}
+var _ i2c.TxMessager = &channel{}
@@ -0,0 +1,262 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package tca9548a
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+)
+
+func Example() {
+	// Open the I²C bus to which the multiplexer is connected.
+	b, err := i2creg.Open("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer b.Close()
+
+	mux, err := New(b, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Register the channels so they can be opened by name, e.g. "I2C1-mux70-3".
+	if err := mux.Register(); err != nil {
+		log.Fatal(err)
+	}
+	defer mux.Unregister()
+
+	// Use the channel as any other bus, e.g. with bmxx80.NewI2C().
+	ch3, err := mux.Bus(3)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s\n", ch3)
+}
+
+func TestNew(t *testing.T) {
+	b := i2ctest.Playback{Ops: []i2ctest.IO{{Addr: 0x71, R: []byte{0}}}}
+	d, err := New(&b, &Opts{Addr: 0x71, Channels: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := d.String(); s != "TCA9548A{playback(113)}" {
+		t.Fatal(s)
+	}
+	if d.Channels() != 4 {
+		t.Fatal(d.Channels())
+	}
+	if _, err := d.Bus(4); err == nil {
+		t.Fatal("invalid channel")
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew_fail(t *testing.T) {
+	if _, err := New(&i2ctest.Playback{}, &Opts{Addr: 0x20}); err == nil {
+		t.Fatal("invalid address")
+	}
+	if _, err := New(&i2ctest.Playback{}, &Opts{Channels: 3}); err == nil {
+		t.Fatal("invalid channels")
+	}
+	if _, err := New(&i2ctest.Playback{DontPanic: true}, nil); err == nil {
+		t.Fatal("no device")
+	}
+}
+
+func TestChannel(t *testing.T) {
+	b := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x70, R: []byte{0}},
+			{Addr: 0x70, W: []byte{0x08}},
+			{Addr: 0x76, W: []byte{0xD0}, R: []byte{0x60}},
+			{Addr: 0x76, W: []byte{0xD0}, R: []byte{0x60}},
+			{Addr: 0x70, W: []byte{0x01}},
+			{Addr: 0x76, W: []byte{0xD0}},
+			{Addr: 0x76, R: []byte{0x58}, Restart: true},
+			{Addr: 0x70, W: []byte{0x08}},
+			{Addr: 0x76, W: []byte{0xD0}, R: []byte{0x60}},
+			{Addr: 0x70, W: []byte{0x00}},
+		},
+	}
+	d, err := New(&b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch3, err := d.Bus(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprintf("%s", ch3); s != "playback-mux70-3" {
+		t.Fatal(s)
+	}
+	r := make([]byte, 1)
+	for i := 0; i < 2; i++ {
+		if err := ch3.Tx(0x76, []byte{0xD0}, r); err != nil || r[0] != 0x60 {
+			t.Fatal(r, err)
+		}
+	}
+	ch0, err := d.Bus(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i2c.TxMessages(ch0, []i2c.Msg{{Addr: 0x76, W: []byte{0xD0}}, {Addr: 0x76, R: r}}); err != nil || r[0] != 0x58 {
+		t.Fatal(r, err)
+	}
+	if err := i2c.TxContext(context.Background(), ch3, 0x76, []byte{0xD0}, r); err != nil || r[0] != 0x60 {
+		t.Fatal(r, err)
+	}
+	if err := d.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch3.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChannel_TxContext_canceled(t *testing.T) {
+	b := i2ctest.Playback{Ops: []i2ctest.IO{{Addr: 0x70, R: []byte{0}}}}
+	d, err := New(&b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, _ := d.Bus(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := i2c.TxContext(ctx, ch, 0x76, nil, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChannel_select_fail(t *testing.T) {
+	m := &muxBus{}
+	d, err := New(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, _ := d.Bus(2)
+	m.err = errors.New("oops")
+	if err := ch.Tx(0x20, nil, nil); err == nil {
+		t.Fatal("select failed")
+	}
+	m.err = nil
+	// The control register must be written again.
+	m.ctl = 0
+	if err := ch.Tx(0x22, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChannel_concurrent(t *testing.T) {
+	m := &muxBus{}
+	d, err := New(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for ch := 0; ch < 8; ch++ {
+		b, err := d.Bus(ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(b i2c.Bus, addr uint16) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if err := b.Tx(addr, nil, nil); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(b, uint16(0x20+ch))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	b := i2ctest.Playback{Ops: []i2ctest.IO{{Addr: 0x70, R: []byte{0}}}}
+	d, err := New(&b, &Opts{Channels: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Register(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Register(); err == nil {
+		t.Fatal("double registration")
+	}
+	bus, err := i2creg.Open("playback-mux70-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprintf("%s", bus); s != "playback-mux70-1" {
+		t.Fatal(s)
+	}
+	if err := d.Unregister(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i2creg.Open("playback-mux70-1"); err == nil {
+		t.Fatal("unregistered")
+	}
+}
+
+//
+
+// muxBus simulates a multiplexer at 0x70 with a device at 0x20+n on channel
+// n.
+type muxBus struct {
+	mu  sync.Mutex
+	ctl byte
+	err error
+}
+
+func (m *muxBus) String() string {
+	return "mux"
+}
+
+func (m *muxBus) Tx(addr uint16, w, r []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	if addr == 0x70 {
+		if len(w) == 1 {
+			m.ctl = w[0]
+		}
+		if len(r) == 1 {
+			r[0] = m.ctl
+		}
+		return nil
+	}
+	if addr < 0x20 || addr >= 0x28 || m.ctl != 1<<(addr-0x20) {
+		return fmt.Errorf("no device at %#x with control %#x", addr, m.ctl)
+	}
+	return nil
+}
+
+func (m *muxBus) SetSpeed(hz int64) error {
+	return nil
+}
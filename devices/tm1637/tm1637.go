@@ -4,6 +4,9 @@
 
 // Package tm1637 controls a TM1637 device over GPIO pins.
 //
+// The pins are marked as used in gpioreg while the device is open, so calling
+// New() twice with the same pins fails until Close() is called.
+//
 // Datasheet
 //
 // http://olimex.cl/website_MCI/static/documents/Datasheet_TM1637.pdf
@@ -12,11 +15,13 @@ package tm1637
 import (
 	"errors"
 	"fmt"
+	"io"
 	"runtime"
 	"time"
 
 	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/host/cpu"
 )
 
@@ -65,8 +70,9 @@ const (
 
 // Dev represents an handle to a tm1637.
 type Dev struct {
-	clk  gpio.PinOut
-	data gpio.PinIO
+	clk    gpio.PinOut
+	data   gpio.PinIO
+	closed bool
 }
 
 func (d *Dev) String() string {
@@ -120,19 +126,43 @@ func (d *Dev) Write(seg []byte) (int, error) {
 }
 
 // Halt turns the display off.
+//
+// The pins are kept, so the display can be turned back on with Write().
 func (d *Dev) Halt() error {
 	b := [6]byte{}
 	_, err := d.Write(b[:])
 	return err
 }
 
+// Close turns the display off and releases the pins.
+//
+// The Dev must not be used afterward. Calling Close more than once is a
+// no-op.
+func (d *Dev) Close() error {
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	err := d.Halt()
+	gpioreg.Release(d.clk, d.data)
+	return err
+}
+
 // New returns an object that communicates over two pins to a TM1637.
+//
+// The pins are acquired in gpioreg until Close() is called. It fails if a pin
+// is already used, including by another Dev that was not closed.
 func New(clk gpio.PinOut, data gpio.PinIO) (*Dev, error) {
+	if err := gpioreg.Acquire("tm1637", clk, data); err != nil {
+		return nil, err
+	}
 	// Spec calls to idle at high.
 	if err := clk.Out(gpio.High); err != nil {
+		gpioreg.Release(clk, data)
 		return nil, err
 	}
 	if err := data.Out(gpio.High); err != nil {
+		gpioreg.Release(clk, data)
 		return nil, err
 	}
 	d := &Dev{clk: clk, data: data}
@@ -202,3 +232,4 @@ func (d *Dev) sleepHalfCycle() {
 
 var _ conn.Resource = &Dev{}
 var _ fmt.Stringer = &Dev{}
+var _ io.Closer = &Dev{}
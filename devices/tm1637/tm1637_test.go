@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"errors"
 	"log"
+	"reflect"
 	"testing"
 
 	"periph.io/x/periph/conn/gpio"
@@ -52,8 +53,30 @@ func TestNew(t *testing.T) {
 	if err := dev.Halt(); err != nil {
 		t.Fatal(err)
 	}
-	// TODO(maruel): Check the state of the pins. That's hard since it has to
-	// emulate the quasi-I²C protocol.
+}
+
+func TestWrite_sim(t *testing.T) {
+	clk := &gpiotest.Pin{N: "CLK"}
+	data := &gpiotest.Pin{N: "DIO"}
+	p := newPeer(t, clk, data)
+	dev, err := New(clk, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dev.Write(Clock(12, 00, true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.SetBrightness(Brightness10); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]byte{
+		{0x40},
+		{0xC0, 0x06, 0xDB, 0x3F, 0x3F, 0x00, 0x00},
+		{0x8B},
+	}
+	if !reflect.DeepEqual(p.frames, expected) {
+		t.Fatalf("%#v != %#v", p.frames, expected)
+	}
 }
 
 func TestDigits(t *testing.T) {
@@ -79,6 +102,56 @@ func TestNew_data_fail(t *testing.T) {
 	}
 }
 
+func TestNew_conflict(t *testing.T) {
+	clk := &gpiotest.Pin{N: "TM1637_CLK", Num: 1637}
+	data := &gpiotest.Pin{N: "TM1637_DIO", Num: 1638}
+	if err := gpioreg.Register(clk, true); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Unregister(clk.N)
+	if err := gpioreg.Register(data, true); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Unregister(data.N)
+	dev, err := New(clk, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := gpioreg.Owner(data); s != "tm1637" {
+		t.Fatal(s)
+	}
+	if _, err := New(clk, data); err == nil {
+		t.Fatal("pins are already used")
+	}
+	// Halt keeps the pins.
+	if err := dev.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if s := gpioreg.Owner(data); s != "tm1637" {
+		t.Fatal(s)
+	}
+	if err := dev.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s := gpioreg.Owner(data); s != "" {
+		t.Fatal(s)
+	}
+	other, err := New(clk, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Closing the first Dev again doesn't release the pins of the second.
+	if err := dev.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s := gpioreg.Owner(data); s != "tm1637" {
+		t.Fatal(s)
+	}
+	if err := other.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestWrite_fail(t *testing.T) {
 	dev, err := New(&gpiotest.Pin{}, &gpiotest.Pin{})
 	if err != nil {
@@ -91,6 +164,55 @@ func TestWrite_fail(t *testing.T) {
 
 //
 
+// peer decodes the frames sent to a TM1637 over simulated wires.
+type peer struct {
+	clk, data gpiotest.Net
+	frames    [][]byte
+	active    bool
+	n         uint // bit number in the current byte; 8 is the ACK
+	b         byte
+}
+
+func newPeer(t *testing.T, clk, data *gpiotest.Pin) *peer {
+	p := &peer{}
+	if err := p.clk.Connect(clk); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.data.Connect(data); err != nil {
+		t.Fatal(err)
+	}
+	p.clk.Watch(func(l gpio.Level) {
+		if !p.active || l == gpio.Low {
+			return
+		}
+		if p.n == 8 {
+			i := len(p.frames) - 1
+			p.frames[i] = append(p.frames[i], p.b)
+			p.n = 0
+			p.b = 0
+			return
+		}
+		// LSB first.
+		if p.data.Read() == gpio.High {
+			p.b |= 1 << p.n
+		}
+		p.n++
+	})
+	p.data.Watch(func(l gpio.Level) {
+		if p.clk.Read() == gpio.Low {
+			return
+		}
+		// Start when falling, stop when rising.
+		p.active = l == gpio.Low
+		if p.active {
+			p.frames = append(p.frames, nil)
+			p.n = 0
+			p.b = 0
+		}
+	})
+	return p
+}
+
 type failPin struct {
 	gpiotest.Pin
 	fail bool
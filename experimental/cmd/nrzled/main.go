@@ -27,6 +27,7 @@ import (
 	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/conn/gpio/gpiostream"
 	"periph.io/x/periph/devices"
+	"periph.io/x/periph/experimental/devices/bitbang"
 	"periph.io/x/periph/experimental/devices/nrzled"
 	"periph.io/x/periph/host"
 )
@@ -143,7 +144,9 @@ func mainImpl() error {
 	}
 	s, ok := p.(gpiostream.PinOut)
 	if !ok {
-		return fmt.Errorf("pin %s doesn't support arbitrary bit stream", p)
+		// Fall back to software; the timing may not be precise enough on a
+		// loaded host.
+		s = bitbang.NewStreamOut(p)
 	}
 	display, err := nrzled.New(s, *numPixels, *hz, *channels)
 	if err != nil {
@@ -9,12 +9,14 @@
 package bitbang
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
 	"sync"
 	"time"
 
+	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/i2c"
 	"periph.io/x/periph/host/cpu"
@@ -42,6 +44,18 @@ func (i *I2C) Close() error {
 
 // Tx implements i2c.Bus.
 func (i *I2C) Tx(addr uint16, w, r []byte) error {
+	return i.TxContext(context.Background(), addr, w, r)
+}
+
+// TxContext implements i2c.TxContexter.
+//
+// ctx is verified between each byte and while the slave is stretching the
+// clock. When ctx is done, a STOP condition is sent and ctx.Err() is
+// returned.
+func (i *I2C) TxContext(ctx context.Context, addr uint16, w, r []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	i.mu.Lock()
 	defer i.mu.Unlock()
 	runtime.LockOSThread()
@@ -54,31 +68,37 @@ func (i *I2C) Tx(addr uint16, w, r []byte) error {
 		if addr > 0xFF {
 			// Page 15, section 3.1.11 10-bit addressing
 			// TODO(maruel): Implement if desired; prefix 0b11110xx.
-			return errors.New("bitbang-i2c: invalid address")
+			return &conn.Error{Kind: conn.ErrUnsupported, Msg: "bitbang-i2c: 10 bits addressing is not supported"}
 		}
 		// Page 13, section 3.1.10 The slave address and R/W bit
 		addr <<= 1
 		if len(r) == 0 {
 			addr |= 1
 		}
-		ack, err := i.writeByte(byte(addr))
+		ack, err := i.writeByte(ctx, byte(addr))
 		if err != nil {
 			return err
 		}
 		if !ack {
-			return errors.New("bitbang-i2c: got NACK")
+			return errNACK
 		}
 	}
 	for _, b := range w {
-		ack, err := i.writeByte(b)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ack, err := i.writeByte(ctx, b)
 		if err != nil {
 			return err
 		}
 		if !ack {
-			return errors.New("bitbang-i2c: got NACK")
+			return errNACK
 		}
 	}
 	for x := range r {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		var err error
 		r[x], err = i.readByte()
 		if err != nil {
@@ -88,6 +108,67 @@ func (i *I2C) Tx(addr uint16, w, r []byte) error {
 	return nil
 }
 
+// TxMessages implements i2c.TxMessager.
+//
+// Each segment after the first one is preceded by a repeated START
+// condition. SkipAddr can be used as the address of a segment.
+func (i *I2C) TxMessages(msgs []i2c.Msg) error {
+	if len(msgs) == 0 {
+		return errors.New("bitbang-i2c: no message")
+	}
+	for x := range msgs {
+		if len(msgs[x].W) != 0 && len(msgs[x].R) != 0 {
+			return fmt.Errorf("bitbang-i2c: message #%d has both W and R set", x)
+		}
+		if msgs[x].Addr != SkipAddr && msgs[x].Addr > 0x7F {
+			return &conn.Error{Kind: conn.ErrUnsupported, Msg: "bitbang-i2c: 10 bits addressing is not supported"}
+		}
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	i.start()
+	defer i.stop()
+	ctx := context.Background()
+	for x, m := range msgs {
+		if x != 0 {
+			i.restart()
+		}
+		if m.Addr != SkipAddr {
+			// Page 13, section 3.1.10 The slave address and R/W bit
+			a := byte(m.Addr << 1)
+			if len(m.R) != 0 {
+				a |= 1
+			}
+			ack, err := i.writeByte(ctx, a)
+			if err != nil {
+				return err
+			}
+			if !ack {
+				return errNACK
+			}
+		}
+		for _, b := range m.W {
+			ack, err := i.writeByte(ctx, b)
+			if err != nil {
+				return err
+			}
+			if !ack {
+				return errNACK
+			}
+		}
+		for y := range m.R {
+			var err error
+			if m.R[y], err = i.readByte(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // SetSpeed implements i2c.Bus.
 func (i *I2C) SetSpeed(hz int64) error {
 	i.mu.Lock()
@@ -141,6 +222,10 @@ func New(clk gpio.PinIO, data gpio.PinIO, speedHz int) (*I2C, error) {
 
 //
 
+// errNACK is returned when the device doesn't acknowledge the address or a
+// byte.
+var errNACK = &conn.Error{Kind: conn.ErrNotAcknowledged, Msg: "bitbang-i2c: got NACK"}
+
 // "When CLK is a high level and DIO changes from high to low level, data input
 // starts."
 //
@@ -155,6 +240,22 @@ func (i *I2C) start() {
 	i.scl.Out(gpio.Low)
 }
 
+// restart sends a repeated START condition.
+//
+// Expects SCL low. Ends with SDA and SCL low.
+//
+// Lasts 3/2 cycle.
+func (i *I2C) restart() {
+	// Page 9, section 3.1.4 START and STOP conditions
+	// "The START (S) and repeated START (Sr) conditions are functionally
+	// identical."
+	i.sda.Out(gpio.High)
+	i.sleepHalfCycle()
+	i.scl.Out(gpio.High)
+	i.sleepHalfCycle()
+	i.start()
+}
+
 // "When CLK is a high level and DIO changes from low level to high level, data
 // input ends."
 //
@@ -178,7 +279,7 @@ func (i *I2C) stop() {
 // Ends with SDA low and SCL high.
 //
 // Lasts 9 cycles.
-func (i *I2C) writeByte(b byte) (bool, error) {
+func (i *I2C) writeByte(ctx context.Context, b byte) (bool, error) {
 	// Page 9, section 3.1.3 Data validity
 	// "The data on te SDA line must be stable during the high period of the
 	// clock."
@@ -205,6 +306,9 @@ func (i *I2C) writeByte(b byte) (bool, error) {
 	}
 	// Implement clock stretching, the device may keep the line low.
 	for i.scl.Read() == gpio.Low {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
 		i.sleepHalfCycle()
 	}
 	// ACK == Low.
@@ -255,4 +359,6 @@ func (i *I2C) sleepHalfCycle() {
 }
 
 var _ i2c.Bus = &I2C{}
+var _ i2c.TxContexter = &I2C{}
+var _ i2c.TxMessager = &I2C{}
 var _ fmt.Stringer = &I2C{}
@@ -0,0 +1,128 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bitbang
+
+import (
+	"reflect"
+	"testing"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/i2c"
+)
+
+func TestI2C_TxMessages_sim(t *testing.T) {
+	scl := &gpiotest.Pin{N: "SCL"}
+	sda := &gpiotest.Pin{N: "SDA"}
+	s := newI2CSlave(t, 0x42, scl, sda)
+	b, err := New(scl, sda, 1000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []i2c.Msg{{Addr: 0x42, W: []byte{0x10, 0xA5}}, {Addr: 0x42, W: []byte{0xFF}}}
+	if err := b.TxMessages(msgs); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]byte{{0x10, 0xA5}, {0xFF}}
+	if !reflect.DeepEqual(s.frames, expected) {
+		t.Fatalf("%#v != %#v", s.frames, expected)
+	}
+	if err := b.TxMessages([]i2c.Msg{{Addr: 0x43, W: []byte{1}}}); !conn.IsKind(err, conn.ErrNotAcknowledged) {
+		t.Fatal(err)
+	}
+	if l := s.sda.Read(); l != gpio.High {
+		t.Fatal("SDA is not released")
+	}
+	if err := s.sda.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//
+
+// i2cSlave is a I²C slave receiver over simulated wires. It records the bytes
+// written to its address.
+type i2cSlave struct {
+	addr      byte
+	scl, sda  gpiotest.Net
+	pin       gpiotest.Pin // the slave's SDA driver
+	frames    [][]byte
+	active    bool // between START and STOP
+	addressed bool
+	first     bool // the current byte is the address
+	n         uint // bit number in the current byte
+	b         byte
+}
+
+func newI2CSlave(t *testing.T, addr byte, scl, sda *gpiotest.Pin) *i2cSlave {
+	s := &i2cSlave{
+		addr: addr,
+		scl:  gpiotest.Net{Pull: gpio.PullUp, OpenDrain: true},
+		sda:  gpiotest.Net{Pull: gpio.PullUp, OpenDrain: true},
+		pin:  gpiotest.Pin{N: "slave"},
+	}
+	if err := s.scl.Connect(scl); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.sda.Connect(sda, &s.pin); err != nil {
+		t.Fatal(err)
+	}
+	s.scl.Watch(s.onSCL)
+	s.sda.Watch(s.onSDA)
+	return s
+}
+
+func (s *i2cSlave) onSDA(l gpio.Level) {
+	if s.scl.Read() == gpio.Low {
+		return
+	}
+	// START or repeated START when falling, STOP when rising.
+	s.active = l == gpio.Low
+	s.addressed = false
+	s.first = true
+	s.n = 0
+	s.b = 0
+}
+
+func (s *i2cSlave) onSCL(l gpio.Level) {
+	if !s.active {
+		return
+	}
+	if l == gpio.High {
+		if s.n < 8 {
+			// MSB first.
+			s.b <<= 1
+			if s.sda.Read() == gpio.High {
+				s.b |= 1
+			}
+			s.n++
+		}
+		return
+	}
+	switch s.n {
+	case 8:
+		// End of the byte; ACK it if addressed.
+		if s.first {
+			s.first = false
+			s.addressed = s.b>>1 == s.addr && s.b&1 == 0
+			if s.addressed {
+				s.frames = append(s.frames, nil)
+			}
+		} else if s.addressed {
+			i := len(s.frames) - 1
+			s.frames[i] = append(s.frames[i], s.b)
+		}
+		if s.addressed {
+			s.pin.Out(gpio.Low)
+		}
+		s.n++
+	case 9:
+		// End of the ACK.
+		s.pin.Out(gpio.High)
+		s.n = 0
+		s.b = 0
+	}
+}
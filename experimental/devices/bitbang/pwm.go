@@ -0,0 +1,260 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bitbang
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/host/cpu"
+)
+
+// PWM implements gpio.PinPWM in software on any gpio.PinOut.
+//
+// All the PWM pins share a single scheduler goroutine, which runs as long as
+// at least one pin is generating a PWM. The edges are timed with host timers
+// and cpu.Nanospin() for the last microseconds, so the jitter depends on the
+// host load and on the speed of the pin driver.
+type PWM struct {
+	gpio.PinOut
+
+	// Protected by scheduler.mu.
+	duty   gpio.Duty
+	period time.Duration
+	high   time.Duration // Time spent high during a period
+	level  gpio.Level    // Current level
+	next   time.Time     // Time of the next edge
+	active bool          // Set when scheduled
+}
+
+// NewPWM returns a software PWM on p.
+func NewPWM(p gpio.PinOut) *PWM {
+	return &PWM{PinOut: p}
+}
+
+func (p *PWM) String() string {
+	return fmt.Sprintf("bitbang/pwm(%s)", p.PinOut)
+}
+
+// PWM implements gpio.PinPWM.
+//
+// Using 0 as period selects a period of 10ms. A duty of 0 or gpio.DutyMax
+// sets the pin to a constant level without using the scheduler.
+func (p *PWM) PWM(duty gpio.Duty, period time.Duration) error {
+	if !duty.Valid() {
+		return fmt.Errorf("bitbang-pwm: invalid duty %d", duty)
+	}
+	if period == 0 {
+		period = defaultPWMPeriod
+	}
+	if period < minPWMPeriod {
+		return fmt.Errorf("bitbang-pwm: period %s is too short; minimum is %s", period, minPWMPeriod)
+	}
+	if duty == 0 || duty == gpio.DutyMax {
+		pwmScheduler.remove(p)
+		if err := p.PinOut.Out(duty == gpio.DutyMax); err != nil {
+			return fmt.Errorf("bitbang-pwm: %v", err)
+		}
+		pwmScheduler.mu.Lock()
+		p.duty = duty
+		p.period = period
+		pwmScheduler.mu.Unlock()
+		return nil
+	}
+	return pwmScheduler.add(p, duty, period)
+}
+
+// Duty returns the current duty cycle and period.
+func (p *PWM) Duty() (gpio.Duty, time.Duration) {
+	pwmScheduler.mu.Lock()
+	defer pwmScheduler.mu.Unlock()
+	return p.duty, p.period
+}
+
+// Out implements gpio.PinOut.
+//
+// It stops the PWM.
+func (p *PWM) Out(l gpio.Level) error {
+	pwmScheduler.remove(p)
+	return p.PinOut.Out(l)
+}
+
+// Halt implements conn.Resource.
+//
+// It stops the PWM and sets the pin low. Once it returns, the pin is not
+// accessed by the scheduler anymore. The scheduler goroutine exits with the
+// last PWM stopped.
+func (p *PWM) Halt() error {
+	pwmScheduler.remove(p)
+	if err := p.PinOut.Out(gpio.Low); err != nil {
+		return fmt.Errorf("bitbang-pwm: %v", err)
+	}
+	if r, ok := p.PinOut.(conn.Resource); ok {
+		return r.Halt()
+	}
+	return nil
+}
+
+//
+
+const (
+	defaultPWMPeriod = 10 * time.Millisecond
+	minPWMPeriod     = 100 * time.Microsecond
+	// spinThreshold is the time before an edge below which the scheduler spins
+	// instead of sleeping, since sleeping is not precise.
+	spinThreshold = 100 * time.Microsecond
+)
+
+// pwmScheduler is shared by all the PWM pins.
+var pwmScheduler = scheduler{}
+
+// scheduler toggles the pins of the active PWMs.
+type scheduler struct {
+	mu   sync.Mutex
+	pins []*PWM
+	wake chan struct{} // Signals a change to pins; nil when not running
+	done chan struct{} // Closed when the goroutine exits
+}
+
+// add schedules p with a new duty cycle, starting the goroutine if needed.
+func (s *scheduler) add(p *PWM, duty gpio.Duty, period time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.duty = duty
+	p.period = period
+	p.high = time.Duration(int64(period) * int64(duty) / int64(gpio.DutyMax))
+	if !p.active {
+		// Start a new cycle right away.
+		if err := p.PinOut.Out(gpio.High); err != nil {
+			return fmt.Errorf("bitbang-pwm: %v", err)
+		}
+		p.level = gpio.High
+		p.next = time.Now().Add(p.high)
+		p.active = true
+		s.pins = append(s.pins, p)
+	}
+	if s.wake == nil {
+		s.wake = make(chan struct{}, 1)
+		s.done = make(chan struct{})
+		go s.run(s.wake, s.done)
+	} else {
+		s.signal()
+	}
+	return nil
+}
+
+// remove unschedules p. It waits for the goroutine to exit when p was the last
+// pin.
+func (s *scheduler) remove(p *PWM) {
+	s.mu.Lock()
+	p.duty = 0
+	p.period = 0
+	if !p.active {
+		s.mu.Unlock()
+		return
+	}
+	p.active = false
+	for i, q := range s.pins {
+		if q == p {
+			copy(s.pins[i:], s.pins[i+1:])
+			s.pins = s.pins[:len(s.pins)-1]
+			break
+		}
+	}
+	s.signal()
+	var done chan struct{}
+	if len(s.pins) == 0 {
+		// Retire the goroutine. A new one is started by the next add().
+		done = s.done
+		s.wake = nil
+		s.done = nil
+	}
+	s.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// signal wakes up the goroutine.
+//
+// lock must be held.
+func (s *scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run toggles the pins until it is retired by remove().
+func (s *scheduler) run(wake chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	t := time.NewTimer(time.Hour)
+	defer t.Stop()
+	for {
+		s.mu.Lock()
+		if s.wake != wake {
+			s.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		next := now.Add(time.Hour)
+		for _, p := range s.pins {
+			if !p.next.After(now) {
+				p.toggle(now)
+			}
+			if p.next.Before(next) {
+				next = p.next
+			}
+		}
+		s.mu.Unlock()
+
+		w := next.Sub(time.Now())
+		if w > spinThreshold {
+			if !t.Stop() {
+				select {
+				case <-t.C:
+				default:
+				}
+			}
+			t.Reset(w - spinThreshold)
+			select {
+			case <-wake:
+			case <-t.C:
+			}
+		} else if w > 0 {
+			cpu.Nanospin(w)
+		}
+	}
+}
+
+// toggle generates the edge due at p.next.
+//
+// scheduler.mu must be held.
+func (p *PWM) toggle(now time.Time) {
+	var err error
+	if p.level == gpio.High {
+		err = p.PinOut.Out(gpio.Low)
+		p.level = gpio.Low
+		p.next = p.next.Add(p.period - p.high)
+	} else {
+		err = p.PinOut.Out(gpio.High)
+		p.level = gpio.High
+		p.next = p.next.Add(p.high)
+	}
+	if err != nil || now.Sub(p.next) > p.period {
+		// The pin failed or the scheduler fell behind; skip the missed cycles.
+		p.next = now.Add(p.period - p.high)
+		if p.level == gpio.High {
+			p.next = now.Add(p.high)
+		}
+	}
+}
+
+var _ gpio.PinPWM = &PWM{}
+var _ gpio.PinOut = &PWM{}
+var _ conn.Resource = &PWM{}
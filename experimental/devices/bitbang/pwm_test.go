@@ -0,0 +1,156 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bitbang
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+)
+
+func TestPWM(t *testing.T) {
+	p1 := &edgeCounter{Pin: gpiotest.Pin{N: "GPIO1"}}
+	p2 := &edgeCounter{Pin: gpiotest.Pin{N: "GPIO2"}}
+	a := NewPWM(p1)
+	b := NewPWM(p2)
+	if s := a.String(); s != "bitbang/pwm(GPIO1(0))" {
+		t.Fatal(s)
+	}
+	if err := a.PWM(gpio.DutyHalf, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.PWM(gpio.DutyMax/4, 0); err != nil {
+		t.Fatal(err)
+	}
+	if d, p := b.Duty(); d != gpio.DutyMax/4 || p != 10*time.Millisecond {
+		t.Fatal(d, p)
+	}
+	// Both pins are toggled by the same goroutine.
+	for start := time.Now(); p1.count() < 10 || p2.count() < 4; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Minute {
+			t.Fatal(p1.count(), p2.count())
+		}
+	}
+	if err := a.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if l := p1.Read(); l != gpio.Low {
+		t.Fatal(l)
+	}
+	if err := b.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	// The scheduler stopped with the last PWM.
+	pwmScheduler.mu.Lock()
+	wake := pwmScheduler.wake
+	pwmScheduler.mu.Unlock()
+	if wake != nil {
+		t.Fatal("scheduler is still running")
+	}
+	n1, n2 := p1.count(), p2.count()
+	time.Sleep(20 * time.Millisecond)
+	if p1.count() != n1 || p2.count() != n2 {
+		t.Fatal("pins are still toggled")
+	}
+	if l := p2.Read(); l != gpio.High {
+		t.Fatal(l)
+	}
+	if d, p := b.Duty(); d != 0 || p != 0 {
+		t.Fatal(d, p)
+	}
+	// It can be restarted.
+	if err := a.PWM(gpio.DutyHalf, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	for start := time.Now(); p1.count() < n1+4; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Minute {
+			t.Fatal(p1.count())
+		}
+	}
+	if err := a.Halt(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPWM_constant(t *testing.T) {
+	p := &edgeCounter{Pin: gpiotest.Pin{N: "GPIO1"}}
+	a := NewPWM(p)
+	if err := a.PWM(gpio.DutyMax, 0); err != nil {
+		t.Fatal(err)
+	}
+	if l := p.Read(); l != gpio.High {
+		t.Fatal(l)
+	}
+	if d, period := a.Duty(); d != gpio.DutyMax || period != defaultPWMPeriod {
+		t.Fatal(d, period)
+	}
+	if err := a.PWM(0, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if l := p.Read(); l != gpio.Low {
+		t.Fatal(l)
+	}
+	if d, period := a.Duty(); d != 0 || period != time.Millisecond {
+		t.Fatal(d, period)
+	}
+	pwmScheduler.mu.Lock()
+	defer pwmScheduler.mu.Unlock()
+	if pwmScheduler.wake != nil {
+		t.Fatal("scheduler must not be used")
+	}
+}
+
+func TestPWM_fail(t *testing.T) {
+	p := &edgeCounter{Pin: gpiotest.Pin{N: "GPIO1"}}
+	a := NewPWM(p)
+	if a.PWM(-1, 0) == nil {
+		t.Fatal("invalid duty")
+	}
+	if a.PWM(gpio.DutyHalf, time.Microsecond) == nil {
+		t.Fatal("period too short")
+	}
+	p.err = errors.New("injected")
+	if a.PWM(gpio.DutyHalf, 0) == nil {
+		t.Fatal("expected failure")
+	}
+	if a.PWM(gpio.DutyMax, 0) == nil {
+		t.Fatal("expected failure")
+	}
+	if a.Halt() == nil {
+		t.Fatal("expected failure")
+	}
+}
+
+//
+
+// edgeCounter counts the changes of level.
+type edgeCounter struct {
+	gpiotest.Pin
+	mu  sync.Mutex
+	n   int
+	err error
+}
+
+func (e *edgeCounter) Out(l gpio.Level) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	if e.Pin.Read() != l {
+		e.n++
+	}
+	return e.Pin.Out(l)
+}
+
+func (e *edgeCounter) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.n
+}
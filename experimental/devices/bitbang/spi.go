@@ -12,6 +12,7 @@
 package bitbang
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -73,7 +74,7 @@ func (s *SPI) Connect(maxHz int64, mode spi.Mode, bits int) error {
 		return errors.New("bitbang-spi: invalid maxHz")
 	}
 	if mode != spi.Mode3 {
-		return fmt.Errorf("bitbang-spi: mode %v is not implemented", mode)
+		return &conn.Error{Kind: conn.ErrUnsupported, Msg: fmt.Sprintf("bitbang-spi: mode %v is not implemented", mode)}
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -92,6 +93,17 @@ func (s *SPI) Connect(maxHz int64, mode spi.Mode, bits int) error {
 // BUG(maruel): Implement bits.
 // BUG(maruel): Test if read works.
 func (s *SPI) Tx(w, r []byte) error {
+	return s.TxContext(context.Background(), w, r)
+}
+
+// TxContext implements spi.TxContexter.
+//
+// ctx is verified between each byte. When ctx is done, CS is deasserted and
+// ctx.Err() is returned.
+func (s *SPI) TxContext(ctx context.Context, w, r []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if len(r) != 0 && len(w) != len(r) {
 		return errors.New("bitbang-spi: write and read buffers must be the same length")
 	}
@@ -101,7 +113,17 @@ func (s *SPI) Tx(w, r []byte) error {
 		s.csn.Out(gpio.Low)
 		s.sleepHalfCycle()
 	}
+	defer func() {
+		if s.csn != nil {
+			s.csn.Out(gpio.High)
+		}
+	}()
 	for i := uint(0); i < uint(len(w)*8); i++ {
+		if i%8 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
 		s.sdo.Out(w[i/8]&(1<<(i%8)) != 0)
 		s.sleepHalfCycle()
 		s.sck.Out(gpio.Low)
@@ -113,15 +135,20 @@ func (s *SPI) Tx(w, r []byte) error {
 		}
 		s.sck.Out(gpio.Low)
 	}
-	if s.csn != nil {
-		s.csn.Out(gpio.High)
-	}
 	return nil
 }
 
 // TxPackets implements spi.Conn.
 func (s *SPI) TxPackets(p []spi.Packet) error {
-	return errors.New("bitbang-spi: not implemented")
+	return &conn.Error{Kind: conn.ErrUnsupported, Msg: "bitbang-spi: not implemented"}
+}
+
+// TxPacketsContext implements spi.TxContexter.
+func (s *SPI) TxPacketsContext(ctx context.Context, p []spi.Packet) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.TxPackets(p)
 }
 
 // Write implements io.Writer.
@@ -195,4 +222,5 @@ func (s *SPI) sleepHalfCycle() {
 }
 
 var _ spi.Conn = &SPI{}
+var _ spi.TxContexter = &SPI{}
 var _ fmt.Stringer = &SPI{}
@@ -0,0 +1,296 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bitbang
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiostream"
+	"periph.io/x/periph/host/cpu"
+)
+
+// StreamOut implements gpiostream.PinOut in software on any gpio.PinOut.
+//
+// The edges are timed with host timers and cpu.Nanospin(), so the achievable
+// resolution depends on the host load and on the speed of the pin driver.
+// StreamOut() returns an error when an edge was late by more than the
+// resolution of the stream.
+type StreamOut struct {
+	gpio.PinOut
+
+	mu   sync.Mutex // Held while streaming
+	halt int32      // Set by Halt() to stop the stream
+}
+
+// NewStreamOut returns a software gpiostream.PinOut on p.
+func NewStreamOut(p gpio.PinOut) *StreamOut {
+	return &StreamOut{PinOut: p}
+}
+
+func (s *StreamOut) String() string {
+	return fmt.Sprintf("bitbang/stream(%s)", s.PinOut)
+}
+
+// StreamOut implements gpiostream.PinOut.
+//
+// It supports BitStreamLSB, BitStreamMSB, BitStream, EdgeStream and Program.
+// The pin is left at the last level of the stream. An infinite Program runs
+// until Halt() is called.
+func (s *StreamOut) StreamOut(st gpiostream.Stream) error {
+	if err := validate(st); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// This helps reduce jitter a little.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	pl := player{p: s.PinOut, halt: &s.halt, start: time.Now()}
+	if pl.play(st) {
+		// Hold the last level for its duration.
+		waitUntil(pl.start.Add(pl.t))
+	}
+	if pl.err != nil {
+		return fmt.Errorf("bitbang-stream: %v", pl.err)
+	}
+	if res := st.Resolution(); pl.late > res {
+		return fmt.Errorf("bitbang-stream: jitter of %s exceeded the resolution of %s", pl.late, res)
+	}
+	return nil
+}
+
+// Halt implements conn.Resource.
+//
+// It stops the stream being played, if any. Once it returns, the pin is not
+// accessed by StreamOut() anymore.
+func (s *StreamOut) Halt() error {
+	atomic.StoreInt32(&s.halt, 1)
+	s.mu.Lock()
+	atomic.StoreInt32(&s.halt, 0)
+	s.mu.Unlock()
+	if r, ok := s.PinOut.(conn.Resource); ok {
+		return r.Halt()
+	}
+	return nil
+}
+
+// StreamIn implements gpiostream.PinIn in software on any gpio.PinIn.
+//
+// The pin is sampled with host timers and cpu.Nanospin(), so the achievable
+// resolution depends on the host load and on the speed of the pin driver.
+// StreamIn() returns an error when a sample was late by more than the
+// resolution of the stream.
+type StreamIn struct {
+	gpio.PinIn
+
+	mu sync.Mutex // Held while sampling
+}
+
+// NewStreamIn returns a software gpiostream.PinIn on p.
+func NewStreamIn(p gpio.PinIn) *StreamIn {
+	return &StreamIn{PinIn: p}
+}
+
+func (s *StreamIn) String() string {
+	return fmt.Sprintf("bitbang/stream(%s)", s.PinIn)
+}
+
+// StreamIn implements gpiostream.PinIn.
+//
+// It supports BitStreamLSB, BitStreamMSB and BitStream. The pin is set as
+// input with the pull specified first.
+func (s *StreamIn) StreamIn(pull gpio.Pull, b gpiostream.Stream) error {
+	var bits []byte
+	var res time.Duration
+	msb := false
+	switch st := b.(type) {
+	case *gpiostream.BitStreamLSB:
+		bits, res = st.Bits, st.Res
+	case *gpiostream.BitStreamMSB:
+		bits, res, msb = st.Bits, st.Res, true
+	case *gpiostream.BitStream:
+		bits, res = st.Bits, st.Res
+	default:
+		return fmt.Errorf("bitbang-stream: unsupported stream %T", b)
+	}
+	if res <= 0 {
+		return errors.New("bitbang-stream: invalid resolution")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.PinIn.In(pull, gpio.NoEdge); err != nil {
+		return fmt.Errorf("bitbang-stream: %v", err)
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	var late time.Duration
+	start := time.Now()
+	for i := range bits {
+		var v byte
+		for j := uint(0); j < 8; j++ {
+			t := time.Duration(8*i+int(j)) * res
+			waitUntil(start.Add(t))
+			l := s.PinIn.Read()
+			if d := time.Since(start) - t; d > late {
+				late = d
+			}
+			if l {
+				if msb {
+					v |= 0x80 >> j
+				} else {
+					v |= 1 << j
+				}
+			}
+		}
+		bits[i] = v
+	}
+	if late > res {
+		return fmt.Errorf("bitbang-stream: jitter of %s exceeded the resolution of %s", late, res)
+	}
+	return nil
+}
+
+//
+
+// validate verifies that a stream can be played.
+func validate(st gpiostream.Stream) error {
+	switch s := st.(type) {
+	case *gpiostream.BitStreamLSB:
+		if len(s.Bits) != 0 && s.Res <= 0 {
+			return errors.New("bitbang-stream: invalid resolution")
+		}
+	case *gpiostream.BitStreamMSB:
+		if len(s.Bits) != 0 && s.Res <= 0 {
+			return errors.New("bitbang-stream: invalid resolution")
+		}
+	case *gpiostream.BitStream:
+		if len(s.Bits) != 0 && s.Res <= 0 {
+			return errors.New("bitbang-stream: invalid resolution")
+		}
+	case *gpiostream.EdgeStream:
+		for _, e := range s.Edges {
+			if e < 0 {
+				return errors.New("bitbang-stream: invalid edge duration")
+			}
+		}
+	case *gpiostream.Program:
+		for _, p := range s.Parts {
+			if err := validate(p); err != nil {
+				return err
+			}
+		}
+		if s.Loops < 0 && s.Duration() == 0 {
+			return errors.New("bitbang-stream: infinite loop of an empty program")
+		}
+	default:
+		return fmt.Errorf("bitbang-stream: unsupported stream %T", st)
+	}
+	return nil
+}
+
+// player plays a stream on a pin, keeping track of the scheduled time.
+type player struct {
+	p     gpio.PinOut
+	halt  *int32
+	start time.Time
+	t     time.Duration // Scheduled time of the next level, relative to start
+	l     gpio.Level    // Current level
+	set   bool          // true once the pin was set
+	late  time.Duration // Maximum lateness of an edge
+	err   error
+}
+
+// play plays a stream. It returns false when the playback must stop.
+func (pl *player) play(st gpiostream.Stream) bool {
+	switch s := st.(type) {
+	case *gpiostream.BitStreamLSB:
+		return pl.bits(s.Bits, s.Res, false)
+	case *gpiostream.BitStreamMSB:
+		return pl.bits(s.Bits, s.Res, true)
+	case *gpiostream.BitStream:
+		return pl.bits(s.Bits, s.Res, false)
+	case *gpiostream.EdgeStream:
+		l := gpio.High
+		for _, e := range s.Edges {
+			if !pl.level(l, e) {
+				return false
+			}
+			l = !l
+		}
+	case *gpiostream.Program:
+		for i := 0; s.Loops < 0 || i < s.Loops; i++ {
+			if atomic.LoadInt32(pl.halt) != 0 {
+				return false
+			}
+			for _, p := range s.Parts {
+				if !pl.play(p) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (pl *player) bits(b []byte, res time.Duration, msb bool) bool {
+	for _, v := range b {
+		for j := uint(0); j < 8; j++ {
+			var l gpio.Level
+			if msb {
+				l = v&(0x80>>j) != 0
+			} else {
+				l = v&(1<<j) != 0
+			}
+			if !pl.level(l, res) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// level sets the pin to l for d. The pin is only accessed on level change.
+func (pl *player) level(l gpio.Level, d time.Duration) bool {
+	if atomic.LoadInt32(pl.halt) != 0 {
+		return false
+	}
+	if d == 0 {
+		return true
+	}
+	if !pl.set || l != pl.l {
+		waitUntil(pl.start.Add(pl.t))
+		if pl.err = pl.p.Out(l); pl.err != nil {
+			return false
+		}
+		if late := time.Since(pl.start) - pl.t; late > pl.late {
+			pl.late = late
+		}
+		pl.l = l
+		pl.set = true
+	}
+	pl.t += d
+	return true
+}
+
+// waitUntil sleeps then spins until t.
+func waitUntil(t time.Time) {
+	if w := t.Sub(time.Now()); w > spinThreshold {
+		time.Sleep(w - spinThreshold)
+	}
+	for w := t.Sub(time.Now()); w > 0; w = t.Sub(time.Now()) {
+		cpu.Nanospin(w)
+	}
+}
+
+var _ gpiostream.PinOut = &StreamOut{}
+var _ gpiostream.PinIn = &StreamIn{}
+var _ conn.Resource = &StreamOut{}
@@ -0,0 +1,234 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bitbang
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiostream"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+)
+
+func TestStreamOut_bits(t *testing.T) {
+	p := &levelRecorder{Pin: gpiotest.Pin{N: "GPIO1"}}
+	s := NewStreamOut(p)
+	if str := s.String(); str != "bitbang/stream(GPIO1(0))" {
+		t.Fatal(str)
+	}
+	const res = 10 * time.Millisecond
+	start := time.Now()
+	checkJitter(t, s.StreamOut(&gpiostream.BitStreamMSB{Bits: gpiostream.BitsMSB{0xC2}, Res: res}))
+	// The last level is held for its duration.
+	if d := time.Since(start); d < 8*res {
+		t.Fatal(d)
+	}
+	// Only the changes of level are written.
+	expected := []gpio.Level{gpio.High, gpio.Low, gpio.High, gpio.Low}
+	if !reflect.DeepEqual(p.get(), expected) {
+		t.Fatal(p.get())
+	}
+	p.reset()
+	checkJitter(t, s.StreamOut(&gpiostream.BitStreamLSB{Bits: gpiostream.BitsLSB{0xFE}, Res: res}))
+	if !reflect.DeepEqual(p.get(), []gpio.Level{gpio.Low, gpio.High}) {
+		t.Fatal(p.get())
+	}
+}
+
+func TestStreamOut_edges(t *testing.T) {
+	p := &levelRecorder{Pin: gpiotest.Pin{N: "GPIO1"}}
+	s := NewStreamOut(p)
+	const res = 10 * time.Millisecond
+	// A duration of 0 starts with Low.
+	e := &gpiostream.EdgeStream{Edges: []time.Duration{0, res, 2 * res}, Res: res}
+	prog := &gpiostream.Program{Parts: []gpiostream.Stream{e}, Loops: 2}
+	checkJitter(t, s.StreamOut(prog))
+	expected := []gpio.Level{gpio.Low, gpio.High, gpio.Low, gpio.High}
+	if !reflect.DeepEqual(p.get(), expected) {
+		t.Fatal(p.get())
+	}
+}
+
+func TestStreamOut_halt(t *testing.T) {
+	p := &levelRecorder{Pin: gpiotest.Pin{N: "GPIO1"}}
+	s := NewStreamOut(p)
+	const res = time.Millisecond
+	e := &gpiostream.EdgeStream{Edges: []time.Duration{res, res}, Res: res}
+	done := make(chan error)
+	go func() {
+		done <- s.StreamOut(&gpiostream.Program{Parts: []gpiostream.Stream{e}, Loops: -1})
+	}()
+	for start := time.Now(); len(p.get()) < 4; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Minute {
+			t.Fatal(p.get())
+		}
+	}
+	if err := s.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	// The infinite loop is interrupted without an error.
+	checkJitter(t, <-done)
+	n := len(p.get())
+	time.Sleep(5 * res)
+	if len(p.get()) != n {
+		t.Fatal("the pin is still accessed")
+	}
+}
+
+func TestStreamOut_halt_loop(t *testing.T) {
+	// A loop stops on halt even when its parts don't access the pin.
+	halt := int32(1)
+	pl := &player{p: &gpiotest.Pin{N: "GPIO1"}, halt: &halt}
+	if pl.play(&gpiostream.Program{Parts: []gpiostream.Stream{&gpiostream.EdgeStream{}}, Loops: 2}) {
+		t.Fatal("expected the playback to stop")
+	}
+}
+
+func TestStreamOut_jitter(t *testing.T) {
+	p := &levelRecorder{Pin: gpiotest.Pin{N: "GPIO1"}, delay: 5 * time.Millisecond}
+	s := NewStreamOut(p)
+	err := s.StreamOut(&gpiostream.BitStreamLSB{Bits: gpiostream.BitsLSB{0x55}, Res: time.Microsecond})
+	if err == nil || !strings.Contains(err.Error(), "jitter") {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamOut_fail(t *testing.T) {
+	p := &levelRecorder{Pin: gpiotest.Pin{N: "GPIO1"}}
+	s := NewStreamOut(p)
+	data := []gpiostream.Stream{
+		&gpiostream.BitStreamLSB{Bits: gpiostream.BitsLSB{1}},
+		&gpiostream.BitStreamMSB{Bits: gpiostream.BitsMSB{1}},
+		&gpiostream.BitStream{Bits: gpiostream.Bits{1}},
+		&gpiostream.EdgeStream{Edges: []time.Duration{-1}},
+		&gpiostream.Program{Parts: []gpiostream.Stream{&gpiostream.EdgeStream{Edges: []time.Duration{-1}}}, Loops: 1},
+		&gpiostream.Program{Loops: -1},
+		&gpiostream.Program{Parts: []gpiostream.Stream{&gpiostream.EdgeStream{}}, Loops: -1},
+		&fakeStream{},
+	}
+	for i, d := range data {
+		if s.StreamOut(d) == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+	}
+	p.err = errors.New("injected")
+	if s.StreamOut(&gpiostream.BitStream{Bits: gpiostream.Bits{1}, Res: time.Millisecond}) == nil {
+		t.Fatal("expected failure")
+	}
+}
+
+func TestStreamIn(t *testing.T) {
+	p := &sampler{Pin: gpiotest.Pin{N: "GPIO1"}}
+	s := NewStreamIn(p)
+	if str := s.String(); str != "bitbang/stream(GPIO1(0))" {
+		t.Fatal(str)
+	}
+	const res = 5 * time.Millisecond
+	p.levels = []gpio.Level{true, true, false, false, false, false, true, false}
+	msb := gpiostream.BitStreamMSB{Bits: make(gpiostream.BitsMSB, 1), Res: res}
+	checkJitter(t, s.StreamIn(gpio.PullUp, &msb))
+	if msb.Bits[0] != 0xC2 {
+		t.Fatalf("0x%02x", msb.Bits[0])
+	}
+	if p.P != gpio.PullUp {
+		t.Fatal(p.P)
+	}
+	p.levels = []gpio.Level{true, true, false, false, false, false, true, false}
+	lsb := gpiostream.BitStreamLSB{Bits: make(gpiostream.BitsLSB, 1), Res: res}
+	checkJitter(t, s.StreamIn(gpio.PullDown, &lsb))
+	if lsb.Bits[0] != 0x43 {
+		t.Fatalf("0x%02x", lsb.Bits[0])
+	}
+}
+
+func TestStreamIn_fail(t *testing.T) {
+	p := &sampler{Pin: gpiotest.Pin{N: "GPIO1"}}
+	s := NewStreamIn(p)
+	if s.StreamIn(gpio.PullNoChange, &gpiostream.EdgeStream{}) == nil {
+		t.Fatal("unsupported stream")
+	}
+	if s.StreamIn(gpio.PullNoChange, &gpiostream.BitStream{Bits: make(gpiostream.Bits, 1)}) == nil {
+		t.Fatal("invalid resolution")
+	}
+	p.delay = 5 * time.Millisecond
+	err := s.StreamIn(gpio.PullNoChange, &gpiostream.BitStream{Bits: make(gpiostream.Bits, 1), Res: time.Microsecond})
+	if err == nil || !strings.Contains(err.Error(), "jitter") {
+		t.Fatal(err)
+	}
+}
+
+//
+
+// checkJitter fails on any error but a jitter error, since the timing depends
+// on the load of the host running the test.
+func checkJitter(t *testing.T, err error) {
+	if err != nil && !strings.Contains(err.Error(), "jitter") {
+		t.Fatal(err)
+	}
+}
+
+// levelRecorder records the levels written to the pin.
+type levelRecorder struct {
+	gpiotest.Pin
+	mu     sync.Mutex
+	levels []gpio.Level
+	delay  time.Duration
+	err    error
+}
+
+func (l *levelRecorder) Out(level gpio.Level) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	time.Sleep(l.delay)
+	l.levels = append(l.levels, level)
+	return l.Pin.Out(level)
+}
+
+func (l *levelRecorder) get() []gpio.Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]gpio.Level(nil), l.levels...)
+}
+
+func (l *levelRecorder) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.levels = nil
+}
+
+// sampler returns the levels in order on each Read().
+type sampler struct {
+	gpiotest.Pin
+	levels []gpio.Level
+	delay  time.Duration
+}
+
+func (s *sampler) Read() gpio.Level {
+	time.Sleep(s.delay)
+	if len(s.levels) == 0 {
+		return gpio.Low
+	}
+	l := s.levels[0]
+	s.levels = s.levels[1:]
+	return l
+}
+
+type fakeStream struct{}
+
+func (f *fakeStream) Resolution() time.Duration {
+	return 0
+}
+
+func (f *fakeStream) Duration() time.Duration {
+	return 0
+}
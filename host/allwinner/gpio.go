@@ -202,6 +202,21 @@ func (p *Pin) WaitForEdge(timeout time.Duration) bool {
 	return false
 }
 
+// Edges implements gpio.PinEdges.
+//
+// The edges are delivered by gpio sysfs, see sysfs.Pin.Edges for the
+// precision of the timestamps.
+func (p *Pin) Edges(n int) (<-chan gpio.EdgeEvent, error) {
+	if !p.usingEdge {
+		return nil, p.wrap(errors.New("edge detection is not enabled"))
+	}
+	c, err := p.edge.Edges(n)
+	if err != nil {
+		return nil, p.wrap(err)
+	}
+	return c, nil
+}
+
 // Pull returns the current pull-up/down registor setting.
 func (p *Pin) Pull() gpio.Pull {
 	if gpioMemory == nil || !p.available {
@@ -292,6 +307,79 @@ func (p *Pin) FastOut(l gpio.Level) {
 	}
 }
 
+// Group implements gpio.Group for pins of the same port, like PB0 to PB9.
+//
+// Read() reads the data register once. Out() reads, modifies and writes back
+// the data register, so the pins of the group change with a single write.
+// The sequence is not atomic: a pin of the same port changed by someone else
+// between the read and the write is reverted.
+type Group struct {
+	pins  []gpio.PinIO
+	group uint8
+	bits  []uint32 // Register bit of each pin
+}
+
+// NewGroup returns a Group of the pins, which must be in the same port.
+//
+// The pins must be set as output with Out() before calling Group.Out().
+func NewGroup(pins ...*Pin) (*Group, error) {
+	if len(pins) == 0 || len(pins) > 32 {
+		return nil, errors.New("allwinner: a group must have between 1 and 32 pins")
+	}
+	g := &Group{pins: make([]gpio.PinIO, len(pins)), group: pins[0].group, bits: make([]uint32, len(pins))}
+	for i, p := range pins {
+		if !p.available {
+			return nil, fmt.Errorf("allwinner: %s is not available on this CPU architecture", p)
+		}
+		if p.group != g.group {
+			return nil, fmt.Errorf("allwinner: %s and %s are not in the same port", pins[0], p)
+		}
+		g.pins[i] = p
+		g.bits[i] = 1 << p.offset
+	}
+	return g, nil
+}
+
+// Pins implements gpio.Group.
+func (g *Group) Pins() []gpio.PinIO {
+	return g.pins
+}
+
+// Out implements gpio.Group.
+func (g *Group) Out(v, mask uint32) error {
+	if gpioMemory == nil {
+		return errors.New("allwinner: subsystem not initialized")
+	}
+	var set, clear uint32
+	for i, b := range g.bits {
+		if mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		if v&(1<<uint(i)) != 0 {
+			set |= b
+		} else {
+			clear |= b
+		}
+	}
+	gpioMemory.groups[g.group].data = gpioMemory.groups[g.group].data&^clear | set
+	return nil
+}
+
+// Read implements gpio.Group.
+func (g *Group) Read(mask uint32) uint32 {
+	if gpioMemory == nil {
+		return 0
+	}
+	d := gpioMemory.groups[g.group].data
+	var v uint32
+	for i, b := range g.bits {
+		if d&b != 0 {
+			v |= 1 << uint(i)
+		}
+	}
+	return v & mask
+}
+
 // DefaultPull returns the default pull for the pin.
 func (p *Pin) DefaultPull() gpio.Pull {
 	return p.defaultPull
@@ -723,7 +811,9 @@ func getBaseAddress() uint64 {
 
 // Ensure that the various structs implement the interfaces they're supposed to.
 
+var _ gpio.Group = &Group{}
 var _ gpio.PinDefaultPull = &Pin{}
+var _ gpio.PinEdges = &Pin{}
 var _ gpio.PinIO = &Pin{}
 var _ gpio.PinIn = &Pin{}
 var _ gpio.PinOut = &Pin{}
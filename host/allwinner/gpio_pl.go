@@ -162,6 +162,18 @@ func (p *PinPL) WaitForEdge(timeout time.Duration) bool {
 	return false
 }
 
+// Edges implements gpio.PinEdges. See Pin.Edges for more information.
+func (p *PinPL) Edges(n int) (<-chan gpio.EdgeEvent, error) {
+	if !p.usingEdge {
+		return nil, p.wrap(errors.New("edge detection is not enabled"))
+	}
+	c, err := p.edge.Edges(n)
+	if err != nil {
+		return nil, p.wrap(err)
+	}
+	return c, nil
+}
+
 // Pull implements gpio.PinIn. See Pin.Pull for more information.
 func (p *PinPL) Pull() gpio.Pull {
 	if gpioMemoryPL == nil {
@@ -395,6 +407,7 @@ func init() {
 }
 
 var _ gpio.PinDefaultPull = &Pin{}
+var _ gpio.PinEdges = &PinPL{}
 var _ gpio.PinIO = &PinPL{}
 var _ gpio.PinIn = &PinPL{}
 var _ gpio.PinOut = &PinPL{}
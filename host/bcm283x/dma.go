@@ -45,8 +45,11 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"periph.io/x/periph"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiostream"
 	"periph.io/x/periph/host/pmem"
 	"periph.io/x/periph/host/videocore"
 )
@@ -54,6 +57,13 @@ import (
 var (
 	dmaMemory    *dmaMap
 	dmaChannel15 *dmaChannel
+	// pwmBaseAddr is needed for DMA transfers paced by the PWM FIFO.
+	pwmBaseAddr uint32
+	// dmaBufAllocator allocates the physical memory used for the control blocks
+	// and the data of streams. It is overridden in unit tests.
+	dmaBufAllocator = func(s int) (pmem.Mem, error) {
+		return videocore.Alloc(s)
+	}
 )
 
 const (
@@ -655,6 +665,303 @@ func physToBus(p uint32) uint32 {
 	return (p & periphMask) | periphBus
 }
 
+// levelRun is a level held for a number of ticks of the stream resolution.
+type levelRun struct {
+	l gpio.Level
+	n uint32
+}
+
+// streamRuns flattens a stream into runs of levels, expressed in ticks of
+// res.
+//
+// Durations are rounded to the closest tick. Consecutive runs of the same
+// level are merged and empty runs are skipped.
+func streamRuns(s gpiostream.Stream, res time.Duration) ([]levelRun, error) {
+	if res <= 0 {
+		return nil, errors.New("invalid resolution")
+	}
+	r := runsBuilder{res: res}
+	if err := r.add(s); err != nil {
+		return nil, err
+	}
+	return r.runs, nil
+}
+
+// runsBuilder accumulates the runs of a stream.
+type runsBuilder struct {
+	res  time.Duration
+	t    time.Duration // Absolute time of the end of the last level
+	runs []levelRun
+}
+
+func (r *runsBuilder) add(s gpiostream.Stream) error {
+	switch st := s.(type) {
+	case *gpiostream.BitStreamLSB:
+		return r.bits(st.Bits, st.Res, false)
+	case *gpiostream.BitStreamMSB:
+		return r.bits(st.Bits, st.Res, true)
+	case *gpiostream.BitStream:
+		return r.bits(st.Bits, st.Res, false)
+	case *gpiostream.EdgeStream:
+		l := gpio.High
+		for _, e := range st.Edges {
+			if e < 0 {
+				return errors.New("invalid edge duration")
+			}
+			r.level(l, e)
+			l = !l
+		}
+	case *gpiostream.Program:
+		if st.Loops < 0 {
+			return errors.New("infinite Program is not supported")
+		}
+		for i := 0; i < st.Loops; i++ {
+			for _, p := range st.Parts {
+				if err := r.add(p); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported stream %T", s)
+	}
+	return nil
+}
+
+func (r *runsBuilder) bits(b []byte, res time.Duration, msb bool) error {
+	if len(b) != 0 && res <= 0 {
+		return errors.New("invalid resolution")
+	}
+	for _, v := range b {
+		for j := uint(0); j < 8; j++ {
+			if msb {
+				r.level(v&(0x80>>j) != 0, res)
+			} else {
+				r.level(v&(1<<j) != 0, res)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *runsBuilder) level(l gpio.Level, d time.Duration) {
+	start := (r.t + r.res/2) / r.res
+	r.t += d
+	n := uint32((r.t+r.res/2)/r.res - start)
+	if n == 0 {
+		return
+	}
+	if i := len(r.runs) - 1; i >= 0 && r.runs[i].l == l {
+		r.runs[i].n += n
+		return
+	}
+	r.runs = append(r.runs, levelRun{l, n})
+}
+
+// maxPacing is the maximum number of ticks paced by a single control block;
+// the transfer length is 30 bits on the full channels.
+const maxPacing = (1<<30 - 1) / 4
+
+// dmaBufSize returns the size of the buffer needed to hold cbs control blocks
+// and words of data, rounded to 4096 bytes.
+func dmaBufSize(cbs, words int) int {
+	return (cbs*32 + words*4 + 0xFFF) &^ 0xFFF
+}
+
+// initStreamOutCBs writes in buf the chain of control blocks to play runs on
+// the pins in mask of the GPIO bank.
+//
+// Each run is a control block copying the mask to GPSET or GPCLR, followed by
+// a control block writing to the PWM FIFO for pacing. The mask is stored right
+// after the control blocks.
+func initStreamOutCBs(buf pmem.Mem, runs []levelRun, bank int, mask uint32, waits int) error {
+	var cbs []controlBlock
+	if err := buf.AsPOD(&cbs); err != nil {
+		return err
+	}
+	var words []uint32
+	if err := buf.AsPOD(&words); err != nil {
+		return err
+	}
+	if len(cbs) < 2*len(runs)+1 {
+		return errors.New("buffer is too small")
+	}
+	base := uint32(buf.PhysAddr())
+	i := 2 * len(runs) * 8
+	words[i] = mask
+	maskAddr := base + uint32(4*i)
+	for j, r := range runs {
+		if r.n > maxPacing {
+			return errors.New("stream is too long")
+		}
+		dst := gpioBaseAddr + 0x28 + uint32(4*bank) // GPCLR
+		if r.l {
+			dst = gpioBaseAddr + 0x1C + uint32(4*bank) // GPSET
+		}
+		if err := cbs[2*j].initBlock(maskAddr, dst, 4, false, true, dmaFire, 0); err != nil {
+			return err
+		}
+		if err := cbs[2*j+1].initBlock(0, pwmBaseAddr+0x18, 4*r.n, false, true, dmaPWM, waits); err != nil {
+			return err
+		}
+	}
+	chainCBs(cbs[:2*len(runs)], base)
+	return nil
+}
+
+// initStreamInCBs writes in buf the chain of control blocks to sample n times
+// the GPIO bank.
+//
+// Each sample is a control block copying GPLEV into the data, followed by a
+// control block writing to the PWM FIFO for pacing. The data is stored right
+// after the control blocks and is returned.
+func initStreamInCBs(buf pmem.Mem, n, bank, waits int) ([]uint32, error) {
+	var cbs []controlBlock
+	if err := buf.AsPOD(&cbs); err != nil {
+		return nil, err
+	}
+	var words []uint32
+	if err := buf.AsPOD(&words); err != nil {
+		return nil, err
+	}
+	i := 2 * n * 8
+	if len(words) < i+n {
+		return nil, errors.New("buffer is too small")
+	}
+	base := uint32(buf.PhysAddr())
+	for j := 0; j < n; j++ {
+		if err := cbs[2*j].initBlock(gpioBaseAddr+0x34+uint32(4*bank), base+uint32(4*(i+j)), 4, true, false, dmaFire, 0); err != nil {
+			return nil, err
+		}
+		if err := cbs[2*j+1].initBlock(0, pwmBaseAddr+0x18, 4, false, true, dmaPWM, waits); err != nil {
+			return nil, err
+		}
+	}
+	chainCBs(cbs[:2*n], base)
+	return words[i : i+n], nil
+}
+
+// chainCBs links the control blocks located at physical address base.
+func chainCBs(cbs []controlBlock, base uint32) {
+	for i := range cbs {
+		if i != len(cbs)-1 {
+			cbs[i].nextCB = base + uint32(32*(i+1))
+		} else {
+			cbs[i].nextCB = 0
+		}
+	}
+}
+
+// decodeSamples converts the GPLEV samples into bits for the pins in mask.
+func decodeSamples(samples []uint32, mask uint32, bits []byte, msb bool) {
+	for i := range bits {
+		var v byte
+		for j := uint(0); j < 8; j++ {
+			if samples[8*i+int(j)]&mask != 0 {
+				if msb {
+					v |= 0x80 >> j
+				} else {
+					v |= 1 << j
+				}
+			}
+		}
+		bits[i] = v
+	}
+}
+
+// dmaStartPWMPacing sets the PWM clock so the PWM FIFO consumes one word per
+// res and returns the wait cycles to use in the control blocks.
+func dmaStartPWMPacing(res time.Duration) (int, error) {
+	if res <= 0 {
+		return 0, errors.New("invalid resolution")
+	}
+	hz := uint64(time.Second / res)
+	actual, waits, err := setPWMClockSource(hz)
+	if err != nil {
+		return 0, err
+	}
+	if actual != hz {
+		return 0, fmt.Errorf("asked for %dHz, got %dHz", hz, actual)
+	}
+	return waits, nil
+}
+
+// dmaWriteStream plays a stream on a pin via DMA.
+//
+// The pin must already be set as output to the level of the first run.
+func dmaWriteStream(p *Pin, runs []levelRun, res time.Duration) error {
+	if len(runs) == 0 {
+		return nil
+	}
+	waits, err := dmaStartPWMPacing(res)
+	if err != nil {
+		return err
+	}
+	buf, err := dmaBufAllocator(dmaBufSize(2*len(runs), 1))
+	if err != nil {
+		return err
+	}
+	defer buf.Close()
+	if err := initStreamOutCBs(buf, runs, p.number/32, 1<<uint(p.number&31), waits); err != nil {
+		return err
+	}
+	return runIO(buf, false)
+}
+
+// dmaReadStream samples pins of a GPIO bank via DMA at the resolution of the
+// streams.
+//
+// The bank is sampled once per tick and the samples are demultiplexed into
+// each stream with the corresponding mask. The streams must have the same
+// resolution and length.
+func dmaReadStream(bank int, masks []uint32, streams []gpiostream.Stream) error {
+	bits := make([][]byte, len(streams))
+	msb := make([]bool, len(streams))
+	var res time.Duration
+	for i, b := range streams {
+		var r time.Duration
+		switch st := b.(type) {
+		case *gpiostream.BitStreamLSB:
+			bits[i], r = st.Bits, st.Res
+		case *gpiostream.BitStreamMSB:
+			bits[i], r, msb[i] = st.Bits, st.Res, true
+		case *gpiostream.BitStream:
+			bits[i], r = st.Bits, st.Res
+		default:
+			return fmt.Errorf("unsupported stream %T", b)
+		}
+		if i == 0 {
+			res = r
+		} else if r != res || len(bits[i]) != len(bits[0]) {
+			return errors.New("the streams must have the same resolution and length")
+		}
+	}
+	if len(bits) == 0 || len(bits[0]) == 0 {
+		return nil
+	}
+	waits, err := dmaStartPWMPacing(res)
+	if err != nil {
+		return err
+	}
+	n := 8 * len(bits[0])
+	buf, err := dmaBufAllocator(dmaBufSize(2*n, n))
+	if err != nil {
+		return err
+	}
+	defer buf.Close()
+	samples, err := initStreamInCBs(buf, n, bank, waits)
+	if err != nil {
+		return err
+	}
+	if err := runIO(buf, false); err != nil {
+		return err
+	}
+	for i, m := range masks {
+		decodeSamples(samples, m, bits[i], msb[i])
+	}
+	return nil
+}
+
 // smokeTest allocates two physical pages, ask the DMA controller to copy the
 // data from one page to another and make sure the content is as expected.
 //
@@ -746,7 +1053,8 @@ func (d *driverDMA) Init() (bool, error) {
 	if err := pmem.MapAsPOD(uint64(baseAddr+0x203000), &pcmMemory); err != nil {
 		return true, err
 	}
-	if err := pmem.MapAsPOD(uint64(baseAddr+0x20C000), &pwmMemory); err != nil {
+	pwmBaseAddr = baseAddr + 0x20C000
+	if err := pmem.MapAsPOD(uint64(pwmBaseAddr), &pwmMemory); err != nil {
 		return true, err
 	}
 	if err := pmem.MapAsPOD(uint64(baseAddr+0x101000), &clockMemory); err != nil {
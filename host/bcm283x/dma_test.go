@@ -7,6 +7,11 @@ package bcm283x
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiostream"
+	"periph.io/x/periph/host/pmem"
 )
 
 func TestDmaStatus_String(t *testing.T) {
@@ -147,5 +152,190 @@ func TestStructSizes(t *testing.T) {
 	if s := reflect.TypeOf((*dmaChannel)(nil)).Elem().Size(); s != 0x100 {
 		t.Fatalf("dmaChannel size: %d", s)
 	}
+}
+
+func TestStreamRuns(t *testing.T) {
+	const res = time.Microsecond
+	runs, err := streamRuns(&gpiostream.BitStreamMSB{Bits: gpiostream.BitsMSB{0xC2}, Res: res}, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []levelRun{{gpio.High, 2}, {gpio.Low, 4}, {gpio.High, 1}, {gpio.Low, 1}}
+	if !reflect.DeepEqual(runs, expected) {
+		t.Fatal(runs)
+	}
+
+	// Durations are rounded to the resolution relative to the start of the
+	// stream and consecutive levels are merged.
+	e := &gpiostream.EdgeStream{Edges: []time.Duration{1400 * time.Nanosecond, 1600 * time.Nanosecond, 0, 300 * time.Nanosecond}, Res: res}
+	lsb := &gpiostream.BitStreamLSB{Bits: gpiostream.BitsLSB{0x0F}, Res: 2 * res}
+	prog := &gpiostream.Program{Parts: []gpiostream.Stream{e, lsb}, Loops: 2}
+	if runs, err = streamRuns(prog, res); err != nil {
+		t.Fatal(err)
+	}
+	expected = []levelRun{{gpio.High, 1}, {gpio.Low, 2}, {gpio.High, 8}, {gpio.Low, 8}, {gpio.High, 2}, {gpio.Low, 2}, {gpio.High, 8}, {gpio.Low, 8}}
+	if !reflect.DeepEqual(runs, expected) {
+		t.Fatal(runs)
+	}
+}
+
+func TestStreamRuns_fail(t *testing.T) {
+	data := []gpiostream.Stream{
+		&gpiostream.BitStream{Bits: gpiostream.Bits{1}},
+		&gpiostream.EdgeStream{Edges: []time.Duration{-1}, Res: time.Microsecond},
+		&gpiostream.Program{Parts: []gpiostream.Stream{&gpiostream.BitStream{Bits: gpiostream.Bits{1}, Res: time.Microsecond}}, Loops: -1},
+		&gpiostream.Program{Parts: []gpiostream.Stream{&gpiostream.BitStreamLSB{Bits: gpiostream.BitsLSB{1}}}, Loops: 1},
+		&fakeStream{},
+	}
+	for i, d := range data {
+		if _, err := streamRuns(d, time.Microsecond); err == nil {
+			t.Fatalf("#%d: expected failure", i)
+		}
+	}
+	if _, err := streamRuns(&gpiostream.BitStream{}, 0); err == nil {
+		t.Fatal("invalid resolution")
+	}
+}
+
+func TestDmaBufSize(t *testing.T) {
+	if s := dmaBufSize(2, 1); s != 4096 {
+		t.Fatal(s)
+	}
+	if s := dmaBufSize(128, 1); s != 8192 {
+		t.Fatal(s)
+	}
+}
+
+func TestInitStreamOutCBs(t *testing.T) {
+	defer resetStreamAddrs()
+	setStreamAddrs()
+	buf := newFakeMem(dmaBufSize(4, 1))
+	runs := []levelRun{{gpio.High, 2}, {gpio.Low, 300}}
+	if err := initStreamOutCBs(buf, runs, 1, 1<<3, 7); err != nil {
+		t.Fatal(err)
+	}
+	var cbs []controlBlock
+	if err := buf.AsPOD(&cbs); err != nil {
+		t.Fatal(err)
+	}
+	var words []uint32
+	if err := buf.AsPOD(&words); err != nil {
+		t.Fatal(err)
+	}
+	// The mask is stored right after the 4 control blocks.
+	if words[32] != 1<<3 {
+		t.Fatalf("0x%x", words[32])
+	}
+	expected := []controlBlock{
+		{dmaNoWideBursts | dmaWaitResp | dmaSrcInc, 0xC0010080, 0x7E200020, 4, 0, 0x10020, [2]uint32{}},
+		{dmaNoWideBursts | dmaWaitResp | dmaSrcIgnore | dmaDstDReq | dmaPWM | 7<<dmaWaitCyclesShift, 0, 0x7E20C018, 8, 0, 0x10040, [2]uint32{}},
+		{dmaNoWideBursts | dmaWaitResp | dmaSrcInc, 0xC0010080, 0x7E20002C, 4, 0, 0x10060, [2]uint32{}},
+		{dmaNoWideBursts | dmaWaitResp | dmaSrcIgnore | dmaDstDReq | dmaPWM | 7<<dmaWaitCyclesShift, 0, 0x7E20C018, 1200, 0, 0, [2]uint32{}},
+	}
+	if !reflect.DeepEqual(cbs[:4], expected) {
+		for i := range expected {
+			t.Logf("%d: %#v", i, &cbs[i])
+		}
+		t.Fatal("unexpected control blocks")
+	}
+
+	if err := initStreamOutCBs(buf, []levelRun{{gpio.High, maxPacing + 1}}, 0, 1, 0); err == nil {
+		t.Fatal("stream is too long")
+	}
+	if err := initStreamOutCBs(buf, make([]levelRun, 128), 0, 1, 0); err == nil {
+		t.Fatal("buffer is too small")
+	}
+}
+
+func TestInitStreamInCBs(t *testing.T) {
+	defer resetStreamAddrs()
+	setStreamAddrs()
+	buf := newFakeMem(dmaBufSize(4, 2))
+	samples, err := initStreamInCBs(buf, 2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cbs []controlBlock
+	if err := buf.AsPOD(&cbs); err != nil {
+		t.Fatal(err)
+	}
+	expected := []controlBlock{
+		{dmaNoWideBursts | dmaWaitResp | dmaDstInc, 0x7E200034, 0xC0010080, 4, 0, 0x10020, [2]uint32{}},
+		{dmaNoWideBursts | dmaWaitResp | dmaSrcIgnore | dmaDstDReq | dmaPWM, 0, 0x7E20C018, 4, 0, 0x10040, [2]uint32{}},
+		{dmaNoWideBursts | dmaWaitResp | dmaDstInc, 0x7E200034, 0xC0010084, 4, 0, 0x10060, [2]uint32{}},
+		{dmaNoWideBursts | dmaWaitResp | dmaSrcIgnore | dmaDstDReq | dmaPWM, 0, 0x7E20C018, 4, 0, 0, [2]uint32{}},
+	}
+	if !reflect.DeepEqual(cbs[:4], expected) {
+		for i := range expected {
+			t.Logf("%d: %#v", i, &cbs[i])
+		}
+		t.Fatal("unexpected control blocks")
+	}
+	// The samples are stored right after the control blocks.
+	var words []uint32
+	if err := buf.AsPOD(&words); err != nil {
+		t.Fatal(err)
+	}
+	words[33] = 42
+	if len(samples) != 2 || samples[1] != 42 {
+		t.Fatal(samples)
+	}
+
+	if _, err := initStreamInCBs(buf, 1024, 0, 0); err == nil {
+		t.Fatal("buffer is too small")
+	}
+}
+
+func TestDecodeSamples(t *testing.T) {
+	samples := []uint32{4, 5, 0, 1, 1, 1, 1, 4}
+	bits := make([]byte, 1)
+	decodeSamples(samples, 4, bits, false)
+	if bits[0] != 0x83 {
+		t.Fatalf("0x%02x", bits[0])
+	}
+	decodeSamples(samples, 4, bits, true)
+	if bits[0] != 0xC1 {
+		t.Fatalf("0x%02x", bits[0])
+	}
+}
+
+//
+
+// fakeMem is a pmem.Mem backed by Go memory at a fake physical address.
+type fakeMem struct {
+	pmem.Slice
+}
+
+func newFakeMem(size int) *fakeMem {
+	return &fakeMem{pmem.Slice(make([]byte, size))}
+}
+
+func (f *fakeMem) Close() error {
+	return nil
+}
+
+func (f *fakeMem) PhysAddr() uint64 {
+	return 0x10000
+}
+
+func setStreamAddrs() {
+	gpioBaseAddr = 0x3F200000
+	pwmBaseAddr = 0x3F20C000
+	dramBus = 0xC0000000
+}
+
+func resetStreamAddrs() {
+	gpioBaseAddr = 0
+	pwmBaseAddr = 0
+	dramBus = 0
+}
+
+type fakeStream struct{}
+
+func (f *fakeStream) Resolution() time.Duration {
+	return 0
+}
 
+func (f *fakeStream) Duration() time.Duration {
+	return 0
 }
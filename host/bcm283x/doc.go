@@ -7,6 +7,10 @@
 // This driver implements memory-mapped GPIO pin manipulation and leverages
 // sysfs-gpio for edge detection.
 //
+// When the driver "bcm283x-dma" is loaded, the pins also implement
+// gpiostream.PinOut and gpiostream.PinIn; the streams are played and sampled
+// by the DMA controller, paced by the PWM clock.
+//
 // If you are looking at the actual implementation, open doc.go for further
 // implementation details.
 //
@@ -14,6 +14,7 @@ import (
 	"periph.io/x/periph"
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpiostream"
 	"periph.io/x/periph/host/distro"
 	"periph.io/x/periph/host/pmem"
 	"periph.io/x/periph/host/sysfs"
@@ -270,6 +271,21 @@ func (p *Pin) WaitForEdge(timeout time.Duration) bool {
 	return false
 }
 
+// Edges implements gpio.PinEdges.
+//
+// The edges are delivered by gpio sysfs, see sysfs.Pin.Edges for the
+// precision of the timestamps.
+func (p *Pin) Edges(n int) (<-chan gpio.EdgeEvent, error) {
+	if !p.usingEdge {
+		return nil, p.wrap(errors.New("edge detection is not enabled"))
+	}
+	c, err := p.edge.Edges(n)
+	if err != nil {
+		return nil, p.wrap(err)
+	}
+	return c, nil
+}
+
 // Pull implemented gpio.PinIn.
 //
 // bcm283x doesn't support querying the pull resistor of any GPIO pin.
@@ -317,6 +333,114 @@ func (p *Pin) FastOut(l gpio.Level) {
 	}
 }
 
+// Group implements gpio.Group for pins of the same bank, either GPIO0 to
+// GPIO31 or GPIO32 to GPIO53.
+//
+// Read() reads the level register GPLEV once. Out() writes the set register
+// GPSET then the clear register GPCLR. Between the two writes, the pins set
+// high already changed while the pins set low did not, so for a few tens of
+// nanoseconds the pins present a value that is neither the previous nor the
+// requested one.
+type Group struct {
+	pins []gpio.PinIO
+	bank int
+	bits []uint32 // Register bit of each pin
+}
+
+// NewGroup returns a Group of the pins, which must be in the same bank.
+//
+// The pins must be set as output with Out() before calling Group.Out().
+func NewGroup(pins ...*Pin) (*Group, error) {
+	if len(pins) == 0 || len(pins) > 32 {
+		return nil, errors.New("bcm283x: a group must have between 1 and 32 pins")
+	}
+	g := &Group{pins: make([]gpio.PinIO, len(pins)), bank: pins[0].number / 32, bits: make([]uint32, len(pins))}
+	for i, p := range pins {
+		if p.number/32 != g.bank {
+			return nil, fmt.Errorf("bcm283x: %s and %s are not in the same bank", pins[0], p)
+		}
+		g.pins[i] = p
+		g.bits[i] = 1 << uint(p.number&31)
+	}
+	return g, nil
+}
+
+// Pins implements gpio.Group.
+func (g *Group) Pins() []gpio.PinIO {
+	return g.pins
+}
+
+// Out implements gpio.Group.
+func (g *Group) Out(v, mask uint32) error {
+	if gpioMemory == nil {
+		return errors.New("bcm283x: subsystem not initialized")
+	}
+	var set, clear uint32
+	for i, b := range g.bits {
+		if mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		if v&(1<<uint(i)) != 0 {
+			set |= b
+		} else {
+			clear |= b
+		}
+	}
+	if set != 0 {
+		gpioMemory.outputSet[g.bank] = set
+	}
+	if clear != 0 {
+		gpioMemory.outputClear[g.bank] = clear
+	}
+	return nil
+}
+
+// Read implements gpio.Group.
+func (g *Group) Read(mask uint32) uint32 {
+	if gpioMemory == nil {
+		return 0
+	}
+	l := gpioMemory.level[g.bank]
+	var v uint32
+	for i, b := range g.bits {
+		if l&b != 0 {
+			v |= 1 << uint(i)
+		}
+	}
+	return v & mask
+}
+
+// StreamIn samples all the pins of the group at once, with one stream per pin
+// in the order of Pins().
+//
+// The level register of the bank is sampled once per tick by the DMA
+// controller, as done by Pin.StreamIn(), so the samples of the pins are
+// aligned. The streams must have the same type, resolution and length. The
+// pins are set as input with the pull specified first.
+func (g *Group) StreamIn(pull gpio.Pull, s []gpiostream.Stream) error {
+	if len(s) != len(g.pins) {
+		return fmt.Errorf("bcm283x: expected %d streams, got %d", len(g.pins), len(s))
+	}
+	if gpioMemory == nil {
+		return errors.New("bcm283x: subsystem not initialized")
+	}
+	if dmaMemory == nil || pwmMemory == nil || clockMemory == nil {
+		return errors.New("bcm283x: bcm283x-dma not initialized; try again as root?")
+	}
+	if pwmInUse() {
+		return fmt.Errorf("bcm283x: %v", errPWMInUse)
+	}
+	for _, p := range g.pins {
+		if err := p.In(pull, gpio.NoEdge); err != nil {
+			return err
+		}
+	}
+	if err := dmaReadStream(g.bank, g.bits, s); err != nil {
+		return fmt.Errorf("bcm283x: %v", err)
+	}
+	return nil
+}
+
 // BUG(maruel): PWM(): There is no conflict verification when multiple pins are
 // used simultaneously. The last call to PWM() will affect all pins of the same
 // type (GPCLK0, GPCLK2, PWM0 or PWM1).
@@ -451,6 +575,82 @@ func (p *Pin) PWM(duty gpio.Duty, period time.Duration) error {
 	return nil
 }
 
+// StreamOut plays a stream on the pin and implements gpiostream.PinOut.
+//
+// The stream is played by the DMA controller at the resolution of the stream,
+// paced by the PWM clock, so it doesn't use the CPU and it isn't affected by
+// the process scheduling. As such the PWM pins can't be used at the same
+// time.
+//
+// It supports BitStreamLSB, BitStreamMSB, BitStream, EdgeStream and finite
+// Program. The durations are rounded to the resolution of the stream. The pin
+// is set as output first and is left at the last level of the stream. An
+// empty stream is a no-op.
+//
+// It fails when PWM() is used on a PWM pin, as the PWM clock is needed.
+//
+// This requires the driver "bcm283x-dma" to be loaded, which requires running
+// as root.
+func (p *Pin) StreamOut(s gpiostream.Stream) error {
+	if s.Duration() == 0 {
+		return nil
+	}
+	runs, err := streamRuns(s, s.Resolution())
+	if err != nil {
+		return p.wrap(err)
+	}
+	if gpioMemory == nil {
+		return p.wrap(errors.New("subsystem not initialized"))
+	}
+	if dmaMemory == nil || pwmMemory == nil || clockMemory == nil {
+		return p.wrap(errors.New("bcm283x-dma not initialized; try again as root?"))
+	}
+	if pwmInUse() {
+		return p.wrap(errPWMInUse)
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+	if err := p.Out(runs[0].l); err != nil {
+		return err
+	}
+	if err := dmaWriteStream(p, runs, s.Resolution()); err != nil {
+		return p.wrap(err)
+	}
+	return nil
+}
+
+// StreamIn samples the pin and implements gpiostream.PinIn.
+//
+// The pin is sampled by the DMA controller at the resolution of the stream,
+// paced by the PWM clock. As such the PWM pins can't be used at the same time.
+//
+// It supports BitStreamLSB, BitStreamMSB and BitStream. The pin is set as
+// input with the pull specified first.
+//
+// It fails when PWM() is used on a PWM pin, as the PWM clock is needed.
+//
+// This requires the driver "bcm283x-dma" to be loaded, which requires running
+// as root.
+func (p *Pin) StreamIn(pull gpio.Pull, s gpiostream.Stream) error {
+	if gpioMemory == nil {
+		return p.wrap(errors.New("subsystem not initialized"))
+	}
+	if dmaMemory == nil || pwmMemory == nil || clockMemory == nil {
+		return p.wrap(errors.New("bcm283x-dma not initialized; try again as root?"))
+	}
+	if pwmInUse() {
+		return p.wrap(errPWMInUse)
+	}
+	if err := p.In(pull, gpio.NoEdge); err != nil {
+		return err
+	}
+	if err := dmaReadStream(p.number/32, []uint32{1 << uint(p.number&31)}, []gpiostream.Stream{s}); err != nil {
+		return p.wrap(err)
+	}
+	return nil
+}
+
 // DefaultPull returns the default pull for the pin.
 //
 // Implements gpio.PinDefaultPull.
@@ -462,6 +662,18 @@ func (p *Pin) DefaultPull() gpio.Pull {
 
 // Internal code.
 
+// errPWMInUse is returned by the streams, which need the PWM clock.
+var errPWMInUse = errors.New("PWM is used on GPIO12, GPIO13, GPIO18 or GPIO19; halt it first")
+
+// pwmInUse returns true if a PWM channel was enabled by PWM().
+//
+// PWM() enables the channel in M/S mode while the DMA pacing of the streams
+// only uses the FIFO of channel 1.
+func pwmInUse() bool {
+	c := pwmMemory.ctl
+	return c&(pwm1Enable|pwm1MS) == pwm1Enable|pwm1MS || c&(pwm2Enable|pwm2MS) == pwm2Enable|pwm2MS
+}
+
 // haltClock disables the GPCLK/PWM clock if used.
 func (p *Pin) haltClock() error {
 	if !p.usingClock {
@@ -925,8 +1137,12 @@ func init() {
 	}
 }
 
+var _ gpio.Group = &Group{}
 var _ gpio.PinDefaultPull = &Pin{}
+var _ gpio.PinEdges = &Pin{}
 var _ gpio.PinIO = &Pin{}
 var _ gpio.PinIn = &Pin{}
 var _ gpio.PinOut = &Pin{}
 var _ gpio.PinPWM = &Pin{}
+var _ gpiostream.PinIn = &Pin{}
+var _ gpiostream.PinOut = &Pin{}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiostream"
 )
 
 func TestPresent(t *testing.T) {
@@ -48,6 +49,9 @@ func TestPin(t *testing.T) {
 	if p.WaitForEdge(-1) {
 		t.Fatal("edge not initialized")
 	}
+	if _, err := p.Edges(1); err == nil {
+		t.Fatal("edge not initialized")
+	}
 	if p.Out(gpio.Low) == nil {
 		t.Fatal("not initialized")
 	}
@@ -137,6 +141,49 @@ func TestPin(t *testing.T) {
 	}
 }
 
+func TestGroup(t *testing.T) {
+	if _, err := NewGroup(); err == nil {
+		t.Fatal("empty group")
+	}
+	if _, err := NewGroup(&Pin{name: "GPIO4", number: 4}, &Pin{name: "GPIO40", number: 40}); err == nil {
+		t.Fatal("pins in different banks")
+	}
+	g, err := NewGroup(&Pin{name: "GPIO36", number: 36}, &Pin{name: "GPIO33", number: 33}, &Pin{name: "GPIO40", number: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(g.Pins()); l != 3 {
+		t.Fatal(l)
+	}
+	if g.Out(0, 0) == nil {
+		t.Fatal("not initialized")
+	}
+	if v := g.Read(0x7); v != 0 {
+		t.Fatal(v)
+	}
+
+	defer func() {
+		gpioMemory = nil
+	}()
+	gpioMemory = &gpioMap{}
+	if err := g.Out(0x5, 0x7); err != nil {
+		t.Fatal(err)
+	}
+	if v := gpioMemory.outputSet[1]; v != 1<<4|1<<8 {
+		t.Fatalf("0x%x", v)
+	}
+	if v := gpioMemory.outputClear[1]; v != 1<<1 {
+		t.Fatalf("0x%x", v)
+	}
+	gpioMemory.level[1] = 1<<1 | 1<<8
+	if v := g.Read(0x7); v != 0x6 {
+		t.Fatalf("0x%x", v)
+	}
+	if v := g.Read(0x1); v != 0 {
+		t.Fatalf("0x%x", v)
+	}
+}
+
 func TestPinPWM(t *testing.T) {
 	defer func() {
 		clockMemory = nil
@@ -165,6 +212,125 @@ func TestPinPWM(t *testing.T) {
 	}
 }
 
+func TestPinStreamOut(t *testing.T) {
+	defer func() {
+		clockMemory = nil
+		dmaMemory = nil
+		gpioMemory = nil
+		pwmMemory = nil
+	}()
+
+	p := Pin{name: "C1", number: 4, defaultPull: gpio.PullDown}
+	s := &gpiostream.BitStreamLSB{Bits: gpiostream.BitsLSB{0xFE}, Res: time.Microsecond}
+	if err := p.StreamOut(s); err == nil || err.Error() != "bcm283x-gpio (C1): subsystem not initialized" {
+		t.Fatal(err)
+	}
+	gpioMemory = &gpioMap{}
+	if err := p.StreamOut(s); err == nil || err.Error() != "bcm283x-gpio (C1): bcm283x-dma not initialized; try again as root?" {
+		t.Fatal(err)
+	}
+	if err := p.StreamOut(&gpiostream.Program{Parts: []gpiostream.Stream{s}, Loops: -1}); err == nil || err.Error() != "bcm283x-gpio (C1): infinite Program is not supported" {
+		t.Fatal(err)
+	}
+
+	// Empty streams are a no-op.
+	if err := p.StreamOut(&gpiostream.BitStream{}); err != nil {
+		t.Fatal(err)
+	}
+
+	clockMemory = &clockMap{}
+	dmaMemory = &dmaMap{}
+	pwmMemory = &pwmMap{ctl: pwm2Enable | pwm2MS}
+	if err := p.StreamOut(s); err == nil || err.Error() != "bcm283x-gpio (C1): PWM is used on GPIO12, GPIO13, GPIO18 or GPIO19; halt it first" {
+		t.Fatal(err)
+	}
+	pwmMemory.ctl = 0
+	// The pin is set to the first level before the stream is started.
+	// TODO(maruel): Fix test.
+	if err := p.StreamOut(s); err == nil || err.Error() != "bcm283x-gpio (C1): can't write to clock divisor CPU register" {
+		t.Fatal(err)
+	}
+	if gpioMemory.outputClear[0] != 1<<4 || gpioMemory.outputSet[0] != 0 {
+		t.Fatal(gpioMemory.outputClear[0], gpioMemory.outputSet[0])
+	}
+	if f := p.function(); f != out {
+		t.Fatal(f)
+	}
+}
+
+func TestPinStreamIn(t *testing.T) {
+	defer func() {
+		clockMemory = nil
+		dmaMemory = nil
+		gpioMemory = nil
+		pwmMemory = nil
+	}()
+
+	p := Pin{name: "C1", number: 4, defaultPull: gpio.PullDown}
+	s := &gpiostream.BitStream{Bits: make(gpiostream.Bits, 1), Res: time.Microsecond}
+	if err := p.StreamIn(gpio.PullNoChange, s); err == nil || err.Error() != "bcm283x-gpio (C1): subsystem not initialized" {
+		t.Fatal(err)
+	}
+	gpioMemory = &gpioMap{}
+	if err := p.StreamIn(gpio.PullNoChange, s); err == nil || err.Error() != "bcm283x-gpio (C1): bcm283x-dma not initialized; try again as root?" {
+		t.Fatal(err)
+	}
+
+	clockMemory = &clockMap{}
+	dmaMemory = &dmaMap{}
+	pwmMemory = &pwmMap{ctl: pwm1Enable | pwm1MS}
+	if err := p.StreamIn(gpio.PullNoChange, s); err == nil || err.Error() != "bcm283x-gpio (C1): PWM is used on GPIO12, GPIO13, GPIO18 or GPIO19; halt it first" {
+		t.Fatal(err)
+	}
+	// The DMA pacing of a previous stream is not PWM.
+	pwmMemory.ctl = pwm1UseFIFO | pwm1Enable
+	if err := p.StreamIn(gpio.PullNoChange, &gpiostream.EdgeStream{}); err == nil || err.Error() != "bcm283x-gpio (C1): unsupported stream *gpiostream.EdgeStream" {
+		t.Fatal(err)
+	}
+	if f := p.function(); f != in {
+		t.Fatal(f)
+	}
+}
+
+func TestGroupStreamIn(t *testing.T) {
+	defer func() {
+		clockMemory = nil
+		dmaMemory = nil
+		gpioMemory = nil
+		pwmMemory = nil
+	}()
+
+	g, err := NewGroup(&Pin{name: "GPIO2", number: 2}, &Pin{name: "GPIO3", number: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := []gpiostream.Stream{
+		&gpiostream.BitStream{Bits: make(gpiostream.Bits, 1), Res: time.Microsecond},
+		&gpiostream.BitStream{Bits: make(gpiostream.Bits, 1), Res: time.Microsecond},
+	}
+	if err := g.StreamIn(gpio.PullNoChange, s[:1]); err == nil || err.Error() != "bcm283x: expected 2 streams, got 1" {
+		t.Fatal(err)
+	}
+	if err := g.StreamIn(gpio.PullNoChange, s); err == nil || err.Error() != "bcm283x: subsystem not initialized" {
+		t.Fatal(err)
+	}
+	gpioMemory = &gpioMap{}
+	clockMemory = &clockMap{}
+	dmaMemory = &dmaMap{}
+	pwmMemory = &pwmMap{ctl: pwm1Enable | pwm1MS}
+	if err := g.StreamIn(gpio.PullNoChange, s); err == nil || err.Error() != "bcm283x: PWM is used on GPIO12, GPIO13, GPIO18 or GPIO19; halt it first" {
+		t.Fatal(err)
+	}
+	pwmMemory.ctl = 0
+	s[1] = &gpiostream.BitStream{Bits: make(gpiostream.Bits, 2), Res: time.Microsecond}
+	if err := g.StreamIn(gpio.PullNoChange, s); err == nil || err.Error() != "bcm283x: the streams must have the same resolution and length" {
+		t.Fatal(err)
+	}
+	if f := g.Pins()[1].Function(); f != "In/Low" {
+		t.Fatal(f)
+	}
+}
+
 func TestDriver(t *testing.T) {
 	d := driverGPIO{}
 	if s := d.String(); s != "bcm283x-gpio" {
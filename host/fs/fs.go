@@ -82,11 +82,27 @@ func (e *Event) MakeEvent(fd uintptr) error {
 	return e.event.makeEvent(fd)
 }
 
+// MakeReadEvent initializes an epoll *edge* triggered event on linux that is
+// signaled when data becomes available to read on fd, like the events queued
+// on a GPIO character device line request.
+//
+// As the event is edge triggered, the data already available when waiting
+// doesn't signal the event. It must be read first.
+func (e *Event) MakeReadEvent(fd uintptr) error {
+	return e.event.makeReadEvent(fd)
+}
+
 // Wait waits for an event or the specified amount of time.
 func (e *Event) Wait(timeoutms int) (int, error) {
 	return e.event.wait(timeoutms)
 }
 
+// Close releases the resources of an event initialized with MakeEvent or
+// MakeReadEvent. It doesn't close the file descriptor being watched.
+func (e *Event) Close() error {
+	return e.event.close()
+}
+
 //
 
 var (
@@ -17,6 +17,7 @@ func ioctl(f uintptr, op uint, arg uintptr) error {
 
 const (
 	epollET     = 1 << 31
+	epollIN     = 1
 	epollPRI    = 2
 	epollCTLAdd = 1
 	epollCTLDel = 2
@@ -36,22 +37,40 @@ type event struct {
 // syscall.EpollCreate: http://man7.org/linux/man-pages/man2/epoll_create.2.html
 // syscall.EpollCtl: http://man7.org/linux/man-pages/man2/epoll_ctl.2.html
 func (e *event) makeEvent(fd uintptr) error {
+	// EPOLLWAKEUP could be used to force the system to not go do sleep while
+	// waiting for an edge. This is generally a bad idea, as we'd instead have
+	// the system to *wake up* when an edge is triggered. Achieving this is
+	// outside the scope of this interface.
+	return e.add(fd, epollPRI|epollET)
+}
+
+// makeReadEvent creates an epoll *edge* triggered event signaled when data is
+// available to read.
+func (e *event) makeReadEvent(fd uintptr) error {
+	return e.add(fd, epollIN|epollET)
+}
+
+func (e *event) add(fd uintptr, events uint32) error {
 	epollFd, err := syscall.EpollCreate(1)
 	if err != nil {
 		return err
 	}
 	e.epollFd = epollFd
 	e.fd = int(fd)
-	// EPOLLWAKEUP could be used to force the system to not go do sleep while
-	// waiting for an edge. This is generally a bad idea, as we'd instead have
-	// the system to *wake up* when an edge is triggered. Achieving this is
-	// outside the scope of this interface.
-	e.event[0].Events = epollPRI | epollET
+	e.event[0].Events = events
 	e.event[0].Fd = int32(e.fd)
-	return syscall.EpollCtl(e.epollFd, epollCTLAdd, e.fd, &e.event[0])
+	if err := syscall.EpollCtl(e.epollFd, epollCTLAdd, e.fd, &e.event[0]); err != nil {
+		syscall.Close(e.epollFd)
+		return err
+	}
+	return nil
 }
 
 func (e *event) wait(timeoutms int) (int, error) {
 	// http://man7.org/linux/man-pages/man2/epoll_wait.2.html
 	return syscall.EpollWait(e.epollFd, e.event[:], timeoutms)
 }
+
+func (e *event) close() error {
+	return syscall.Close(e.epollFd)
+}
@@ -17,6 +17,7 @@ import (
 	"periph.io/x/periph"
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpioutil"
 	"periph.io/x/periph/host/fs"
 )
 
@@ -45,6 +46,8 @@ type Pin struct {
 	fValue     fileIO    // handle to /sys/class/gpio/gpio*/value; never closed
 	event      fs.Event  // Initialized once
 	buf        [4]byte   // scratch buffer for Function(), Read() and Out()
+
+	stream gpioutil.EdgeStream // Delivers the edges for Edges()
 }
 
 func (p *Pin) String() string {
@@ -90,6 +93,7 @@ func (p *Pin) Function() string {
 //
 // It stops edge detection if enabled.
 func (p *Pin) Halt() error {
+	p.stream.Stop()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if err := p.haltEdge(); err != nil {
@@ -103,6 +107,7 @@ func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
 	if pull != gpio.PullNoChange && pull != gpio.Float {
 		return p.wrap(errors.New("doesn't support pull-up/pull-down"))
 	}
+	p.stream.Stop()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if p.direction != dIn {
@@ -213,6 +218,34 @@ func (p *Pin) WaitForEdge(timeout time.Duration) bool {
 	}
 }
 
+// Edges implements gpio.PinEdges.
+//
+// The time of an edge is when the kernel woke up the goroutine waiting for it,
+// so it includes the interrupt and scheduling latency. When detecting both
+// edges, the level is read after the edge and may be wrong for short pulses.
+func (p *Pin) Edges(n int) (<-chan gpio.EdgeEvent, error) {
+	p.mu.Lock()
+	edge := p.edge
+	p.mu.Unlock()
+	if edge == gpio.NoEdge {
+		return nil, p.wrap(errors.New("edge detection is not enabled"))
+	}
+	c, err := p.stream.Start(n, func(timeout time.Duration) (gpio.EdgeEvent, bool) {
+		if !p.WaitForEdge(timeout) {
+			return gpio.EdgeEvent{}, false
+		}
+		e := gpio.EdgeEvent{T: time.Now(), L: edge == gpio.RisingEdge}
+		if edge == gpio.BothEdges {
+			e.L = p.Read()
+		}
+		return e, true
+	})
+	if err != nil {
+		return nil, p.wrap(err)
+	}
+	return c, nil
+}
+
 // Pull returns gpio.PullNoChange since gpio sysfs has no support for input
 // pull resistor.
 func (p *Pin) Pull() gpio.Pull {
@@ -221,6 +254,7 @@ func (p *Pin) Pull() gpio.Pull {
 
 // Out sets a pin as output; implements gpio.PinOut.
 func (p *Pin) Out(l gpio.Level) error {
+	p.stream.Stop()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if p.direction != dOut {
@@ -378,6 +412,14 @@ func (d *driverGPIO) Prerequisites() []string {
 	return nil
 }
 
+// After implements periph.DriverAfter.
+//
+// The "sysfs-gpiochip" driver is loaded first so the pins are only registered
+// when it didn't load.
+func (d *driverGPIO) After() []string {
+	return []string{"sysfs-gpiochip"}
+}
+
 // Init initializes GPIO sysfs handling code.
 //
 // Uses gpio sysfs as described at
@@ -388,6 +430,10 @@ func (d *driverGPIO) Prerequisites() []string {
 //
 // The main drawback of GPIO sysfs is that it doesn't expose internal pull
 // resistor and it is much slower than using memory mapped hardware registers.
+//
+// When the "sysfs-gpiochip" driver loaded, the pins are still available in
+// Pins but are not registered in gpioreg, as the lines of the GPIO character
+// devices are registered instead.
 func (d *driverGPIO) Init() (bool, error) {
 	items, err := filepath.Glob("/sys/class/gpio/gpiochip*")
 	if err != nil {
@@ -400,8 +446,9 @@ func (d *driverGPIO) Init() (bool, error) {
 	// There are hosts that use non-continuous pin numbering so use a map instead
 	// of an array.
 	Pins = map[int]*Pin{}
+	register := len(Lines) == 0
 	for _, item := range items {
-		if err := d.parseGPIOChip(item + "/"); err != nil {
+		if err := d.parseGPIOChip(item+"/", register); err != nil {
 			return true, err
 		}
 	}
@@ -412,7 +459,7 @@ func (d *driverGPIO) Init() (bool, error) {
 	return true, err
 }
 
-func (d *driverGPIO) parseGPIOChip(path string) error {
+func (d *driverGPIO) parseGPIOChip(path string, register bool) error {
 	base, err := readInt(path + "base")
 	if err != nil {
 		return err
@@ -433,6 +480,9 @@ func (d *driverGPIO) parseGPIOChip(path string) error {
 			root:   fmt.Sprintf("/sys/class/gpio/gpio%d/", i),
 		}
 		Pins[i] = p
+		if !register {
+			continue
+		}
 		if err := gpioreg.Register(p, false); err != nil {
 			return err
 		}
@@ -450,4 +500,6 @@ func init() {
 var _ gpio.PinIn = &Pin{}
 var _ gpio.PinOut = &Pin{}
 var _ gpio.PinIO = &Pin{}
+var _ gpio.PinEdges = &Pin{}
 var _ fmt.Stringer = &Pin{}
+var _ periph.DriverAfter = &driverGPIO{}
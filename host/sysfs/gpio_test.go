@@ -137,6 +137,28 @@ func TestPin_WaitForEdges(t *testing.T) {
 	}
 }
 
+func TestPin_Edges(t *testing.T) {
+	p := Pin{number: 42, name: "foo", root: "/tmp/gpio/priv/"}
+	if _, err := p.Edges(1); err == nil {
+		t.Fatal("edge detection is not enabled")
+	}
+	p.edge = gpio.RisingEdge
+	p.fEdge = &fakeGPIOFile{data: []byte("none")}
+	if _, err := p.Edges(-1); err == nil {
+		t.Fatal("invalid channel size")
+	}
+	c, err := p.Edges(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-c; ok {
+		t.Fatal("channel must be closed")
+	}
+}
+
 func TestPin_Pull(t *testing.T) {
 	p := Pin{number: 42, name: "foo", root: "/tmp/gpio/priv/"}
 	if pull := p.Pull(); pull != gpio.PullNoChange {
@@ -198,6 +220,9 @@ func TestGPIODriver(t *testing.T) {
 	if len((&driverGPIO{}).Prerequisites()) != 0 {
 		t.Fatal("unexpected GPIO prerequisites")
 	}
+	if a := (&driverGPIO{}).After(); len(a) != 1 || a[0] != "sysfs-gpiochip" {
+		t.Fatal(a)
+	}
 }
 
 //
@@ -0,0 +1,817 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpioutil"
+)
+
+// GPIOChips is all the GPIO controllers exposed as GPIO character devices
+// /dev/gpiochipN, in order.
+//
+// This global variable is initialized once at driver initialization and isn't
+// mutated afterward. Do not modify it.
+var GPIOChips []*GPIOChip
+
+// Lines is all the GPIO lines exposed by the GPIO character devices.
+//
+// The lines are numbered like the GPIO sysfs pins, from the global base of
+// their chip as found in /sys/class/gpio/gpiochipN/base. When GPIO sysfs is
+// not available, the lines are numbered consecutively across the chips, in the
+// order of GPIOChips.
+//
+// This global variable is initialized once at driver initialization and isn't
+// mutated afterward. Do not modify it.
+var Lines map[int]*GPIOLine
+
+// GPIOChip is a GPIO controller exposed as a GPIO character device.
+type GPIOChip struct {
+	name  string // Something like gpiochip0
+	label string // Something like pinctrl-bcm2835
+	f     chipFile
+	lines []*GPIOLine
+}
+
+func (c *GPIOChip) String() string {
+	return c.name
+}
+
+// Label returns the label of the GPIO controller as reported by the kernel
+// driver.
+func (c *GPIOChip) Label() string {
+	return c.label
+}
+
+// Lines returns the lines of the GPIO controller, ordered by offset.
+func (c *GPIOChip) Lines() []*GPIOLine {
+	return c.lines
+}
+
+// GPIOLine represents one GPIO line as found by the GPIO character device
+// interface.
+//
+// Unlike Pin, it supports pull resistors, active-low lines, hardware
+// debouncing and timestamped edge detection.
+//
+// The line is requested from the kernel on first use and released by Halt().
+type GPIOLine struct {
+	number   int
+	name     string
+	chip     *GPIOChip
+	offset   uint32
+	lineName string // Name of the line set by the kernel driver or device tree
+
+	mu        sync.Mutex
+	f         lineFile      // line request; nil when not requested
+	flags     uint64        // flags of the line request
+	edge      gpio.Edge     // Cache of the last edge used
+	activeLow bool          // Applied at the next configuration
+	debounce  time.Duration // Applied at the next configuration
+
+	stream gpioutil.EdgeStream // Delivers the edges for Edges()
+}
+
+func (p *GPIOLine) String() string {
+	return p.name
+}
+
+// Name implements pins.Pin.
+func (p *GPIOLine) Name() string {
+	return p.name
+}
+
+// Number implements pins.Pin.
+func (p *GPIOLine) Number() int {
+	return p.number
+}
+
+// Function implements pins.Pin.
+//
+// When the line is not requested, it returns the consumer of the line if it is
+// used by the kernel or another process.
+func (p *GPIOLine) Function() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.f != nil {
+		if p.flags&lineFlagOutput != 0 {
+			return "Out/" + p.read().String()
+		}
+		return "In/" + p.read().String()
+	}
+	info := lineInfo{offset: p.offset}
+	if err := p.chip.f.LineInfo(&info); err != nil {
+		return "ERR"
+	}
+	if info.flags&lineFlagUsed != 0 {
+		if c := cString(info.consumer[:]); c != "" {
+			return c
+		}
+		return "Used"
+	}
+	if info.flags&lineFlagOutput != 0 {
+		return "Out"
+	}
+	return "In"
+}
+
+// Chip returns the GPIO controller of the line.
+func (p *GPIOLine) Chip() *GPIOChip {
+	return p.chip
+}
+
+// Offset returns the offset of the line on its GPIO controller.
+func (p *GPIOLine) Offset() int {
+	return int(p.offset)
+}
+
+// LineName returns the name of the line as set by the kernel driver or the
+// device tree, which is often the name of the pin on the board. It may be
+// empty.
+func (p *GPIOLine) LineName() string {
+	return p.lineName
+}
+
+// Halt implements conn.Resource.
+//
+// It releases the line, which stops edge detection if enabled.
+func (p *GPIOLine) Halt() error {
+	p.stream.Stop()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.f == nil {
+		return nil
+	}
+	err := p.f.Close()
+	p.f = nil
+	p.flags = 0
+	p.edge = gpio.NoEdge
+	if err != nil {
+		return p.wrap(err)
+	}
+	return nil
+}
+
+// In setups a line as an input.
+func (p *GPIOLine) In(pull gpio.Pull, edge gpio.Edge) error {
+	p.stream.Stop()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	flags := uint64(lineFlagInput)
+	switch pull {
+	case gpio.PullNoChange:
+		flags |= p.flags & lineFlagBiasMask
+	case gpio.Float:
+		flags |= lineFlagBiasDisabled
+	case gpio.PullDown:
+		flags |= lineFlagBiasPullDown
+	case gpio.PullUp:
+		flags |= lineFlagBiasPullUp
+	}
+	switch edge {
+	case gpio.RisingEdge:
+		flags |= lineFlagEdgeRising
+	case gpio.FallingEdge:
+		flags |= lineFlagEdgeFalling
+	case gpio.BothEdges:
+		flags |= lineFlagEdgeRising | lineFlagEdgeFalling
+	}
+	if err := p.configure(flags, gpio.Low); err != nil {
+		return p.wrap(err)
+	}
+	p.edge = edge
+	if edge != gpio.NoEdge {
+		// Flush the edges accumulated before the reconfiguration.
+		for {
+			if _, ok := readEvent(p.f, 0); !ok {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Read implements gpio.PinIn.
+//
+// If the line is not requested yet, it is requested as an input.
+func (p *GPIOLine) Read() gpio.Level {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.f == nil {
+		if err := p.configure(lineFlagInput, gpio.Low); err != nil {
+			return gpio.Low
+		}
+	}
+	return p.read()
+}
+
+// WaitForEdge does edge detection, returns once one is detected and implements
+// gpio.PinIn.
+func (p *GPIOLine) WaitForEdge(timeout time.Duration) bool {
+	// Do not hold the lock while waiting, so Halt() can be called concurrently.
+	p.mu.Lock()
+	f := p.f
+	edge := p.edge
+	p.mu.Unlock()
+	if f == nil || edge == gpio.NoEdge {
+		return false
+	}
+	_, ok := readEvent(f, timeout)
+	return ok
+}
+
+// Edges implements gpio.PinEdges.
+//
+// The time of an edge is the timestamp taken by the kernel when the interrupt
+// occurred and the level is the one reported by the kernel, so short pulses
+// are reported correctly. It is the logical level when the line is
+// active-low.
+func (p *GPIOLine) Edges(n int) (<-chan gpio.EdgeEvent, error) {
+	p.mu.Lock()
+	f := p.f
+	edge := p.edge
+	p.mu.Unlock()
+	if f == nil || edge == gpio.NoEdge {
+		return nil, p.wrap(errors.New("edge detection is not enabled"))
+	}
+	c, err := p.stream.Start(n, func(timeout time.Duration) (gpio.EdgeEvent, bool) {
+		return readEvent(f, timeout)
+	})
+	if err != nil {
+		return nil, p.wrap(err)
+	}
+	return c, nil
+}
+
+// Pull implements gpio.PinIn.
+//
+// It returns the bias requested by the last call to In().
+func (p *GPIOLine) Pull() gpio.Pull {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.flags&lineFlagBiasPullUp != 0:
+		return gpio.PullUp
+	case p.flags&lineFlagBiasPullDown != 0:
+		return gpio.PullDown
+	case p.flags&lineFlagBiasDisabled != 0:
+		return gpio.Float
+	default:
+		return gpio.PullNoChange
+	}
+}
+
+// Out sets a line as output; implements gpio.PinOut.
+func (p *GPIOLine) Out(l gpio.Level) error {
+	p.stream.Stop()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.f != nil && p.flags&lineFlagOutput != 0 && (p.flags&lineFlagActiveLow != 0) == p.activeLow {
+		v := lineValues{mask: 1}
+		if l {
+			v.bits = 1
+		}
+		if err := p.f.SetValues(&v); err != nil {
+			return p.wrap(err)
+		}
+		return nil
+	}
+	if err := p.configure(lineFlagOutput, l); err != nil {
+		return p.wrap(err)
+	}
+	p.edge = gpio.NoEdge
+	return nil
+}
+
+// SetActiveLow sets the line as active-low, which inverts the levels read,
+// written and reported as edges.
+//
+// An input is reconfigured immediately, an output at the next call to Out().
+func (p *GPIOLine) SetActiveLow(activeLow bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.activeLow = activeLow
+	return p.reconfigureIn()
+}
+
+// SetDebounce sets the debounce period of the line when used as an input. The
+// kernel filters out the pulses shorter than d. Use 0 to disable debouncing.
+//
+// Not all GPIO controllers support debouncing. An input is reconfigured
+// immediately, otherwise it is applied at the next call to In().
+func (p *GPIOLine) SetDebounce(d time.Duration) error {
+	if d < 0 {
+		return p.wrap(errors.New("invalid debounce period"))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.debounce = d
+	return p.reconfigureIn()
+}
+
+//
+
+// reconfigureIn applies the line settings if the line is an input.
+//
+// lock must be held.
+func (p *GPIOLine) reconfigureIn() error {
+	if p.f == nil || p.flags&lineFlagInput == 0 {
+		return nil
+	}
+	if err := p.configure(p.flags&^lineFlagActiveLow, gpio.Low); err != nil {
+		return p.wrap(err)
+	}
+	return nil
+}
+
+// configure requests the line with flags, or changes the configuration of the
+// line if it is already requested. l is the initial level of an output.
+//
+// lock must be held.
+func (p *GPIOLine) configure(flags uint64, l gpio.Level) error {
+	if p.activeLow {
+		flags |= lineFlagActiveLow
+	}
+	cfg := lineConfig{flags: flags}
+	if flags&lineFlagOutput != 0 {
+		a := &cfg.attrs[cfg.numAttrs]
+		a.attr.id = lineAttrOutputValues
+		if l {
+			a.attr.value = 1
+		}
+		a.mask = 1
+		cfg.numAttrs++
+	}
+	if flags&lineFlagInput != 0 && p.debounce != 0 {
+		a := &cfg.attrs[cfg.numAttrs]
+		a.attr.id = lineAttrDebounce
+		a.attr.setDebounce(uint32((p.debounce + time.Microsecond - 1) / time.Microsecond))
+		a.mask = 1
+		cfg.numAttrs++
+	}
+	if p.f != nil {
+		if err := p.f.SetConfig(&cfg); err != nil {
+			return err
+		}
+		p.flags = flags
+		return nil
+	}
+	if p.chip == nil || p.chip.f == nil {
+		return errors.New("gpiochip is not initialized")
+	}
+	req := lineRequest{config: cfg, numLines: 1}
+	req.offsets[0] = p.offset
+	copy(req.consumer[:len(req.consumer)-1], "periph")
+	f, err := p.chip.f.GetLine(&req)
+	if err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("need more access, try as root or setup udev rules: %v", err)
+		}
+		return err
+	}
+	p.f = f
+	p.flags = flags
+	return nil
+}
+
+// read returns the current level of the requested line.
+//
+// lock must be held.
+func (p *GPIOLine) read() gpio.Level {
+	v := lineValues{mask: 1}
+	if err := p.f.GetValues(&v); err != nil {
+		return gpio.Low
+	}
+	return v.bits&1 != 0
+}
+
+func (p *GPIOLine) wrap(err error) error {
+	return fmt.Errorf("sysfs-gpiochip (%s): %v", p, err)
+}
+
+// readEvent reads one edge event from the line request.
+//
+// A negative timeout waits forever.
+func readEvent(f lineFile, timeout time.Duration) (gpio.EdgeEvent, bool) {
+	start := time.Now()
+	var e lineEvent
+	b := (*[unsafe.Sizeof(e)]byte)(unsafe.Pointer(&e))[:]
+	for {
+		if n, err := f.Read(b); err == nil && n == len(b) {
+			// The kernel timestamps the events with CLOCK_MONOTONIC.
+			t := time.Now().Add(time.Duration(e.timestampNs) - monotonicNow())
+			return gpio.EdgeEvent{T: t, L: e.id == lineEventRisingEdge}, true
+		}
+		// The wait may be signaled by an event that was already read, so loop
+		// until an event is read or the timeout expires.
+		d := time.Duration(-1)
+		if timeout >= 0 {
+			if d = timeout - time.Since(start); d <= 0 {
+				return gpio.EdgeEvent{}, false
+			}
+		}
+		if !f.Wait(d) {
+			return gpio.EdgeEvent{}, false
+		}
+	}
+}
+
+// cString returns the NUL terminated string in b.
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+//
+
+// chipFile is the GPIO character device /dev/gpiochipN.
+//
+// It is an interface so it can be replaced in unit tests.
+type chipFile interface {
+	io.Closer
+	ChipInfo(i *chipInfo) error
+	LineInfo(i *lineInfo) error
+	GetLine(r *lineRequest) (lineFile, error)
+}
+
+// lineFile is a line request returned by chipFile.GetLine.
+//
+// Read doesn't block; it fails when no event is queued. Wait waits up to
+// timeout for an event to be queued, forever if timeout is negative.
+type lineFile interface {
+	io.Closer
+	io.Reader
+	Wait(timeout time.Duration) bool
+	SetConfig(c *lineConfig) error
+	GetValues(v *lineValues) error
+	SetValues(v *lineValues) error
+}
+
+var gpioChipOpen = gpioChipOpenDefault
+
+func gpioChipOpenDefault(path string) (chipFile, error) {
+	f, err := ioctlOpen(path, os.O_RDWR)
+	if err != nil {
+		return nil, err
+	}
+	return &chipDev{f: f}, nil
+}
+
+// chipDev implements chipFile with the ioctls of the GPIO character device.
+type chipDev struct {
+	f ioctlCloser
+}
+
+func (c *chipDev) Close() error {
+	return c.f.Close()
+}
+
+func (c *chipDev) ChipInfo(i *chipInfo) error {
+	return c.f.Ioctl(ioctlGetChipInfo, uintptr(unsafe.Pointer(i)))
+}
+
+func (c *chipDev) LineInfo(i *lineInfo) error {
+	return c.f.Ioctl(ioctlGetLineInfo, uintptr(unsafe.Pointer(i)))
+}
+
+func (c *chipDev) GetLine(r *lineRequest) (lineFile, error) {
+	if err := c.f.Ioctl(ioctlGetLine, uintptr(unsafe.Pointer(r))); err != nil {
+		return nil, err
+	}
+	return newLineDev(r.fd)
+}
+
+// Structures and ioctls of the GPIO character device uAPI v2, from
+// include/uapi/linux/gpio.h. All the fields are naturally aligned so the
+// layout is the same on 32 and 64 bits architectures.
+
+const (
+	ioctlGetChipInfo     = 0x8044B401 // GPIO_GET_CHIPINFO_IOCTL
+	ioctlGetLineInfo     = 0xC100B405 // GPIO_V2_GET_LINEINFO_IOCTL
+	ioctlGetLine         = 0xC250B407 // GPIO_V2_GET_LINE_IOCTL
+	ioctlLineSetConfig   = 0xC110B40D // GPIO_V2_LINE_SET_CONFIG_IOCTL
+	ioctlLineGetValues   = 0xC010B40E // GPIO_V2_LINE_GET_VALUES_IOCTL
+	ioctlLineSetValues   = 0xC010B40F // GPIO_V2_LINE_SET_VALUES_IOCTL
+	lineAttrOutputValues = 2          // GPIO_V2_LINE_ATTR_ID_OUTPUT_VALUES
+	lineAttrDebounce     = 3          // GPIO_V2_LINE_ATTR_ID_DEBOUNCE
+	lineEventRisingEdge  = 1          // GPIO_V2_LINE_EVENT_RISING_EDGE
+)
+
+// gpio_v2_line_flag
+const (
+	lineFlagUsed         = 1 << 0
+	lineFlagActiveLow    = 1 << 1
+	lineFlagInput        = 1 << 2
+	lineFlagOutput       = 1 << 3
+	lineFlagEdgeRising   = 1 << 4
+	lineFlagEdgeFalling  = 1 << 5
+	lineFlagBiasPullUp   = 1 << 8
+	lineFlagBiasPullDown = 1 << 9
+	lineFlagBiasDisabled = 1 << 10
+	lineFlagBiasMask     = lineFlagBiasPullUp | lineFlagBiasPullDown | lineFlagBiasDisabled
+)
+
+// chipInfo is struct gpiochip_info.
+type chipInfo struct {
+	name  [32]byte
+	label [32]byte
+	lines uint32
+}
+
+// lineAttribute is struct gpio_v2_line_attribute.
+type lineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64 // union of flags, values and debounce_period_us
+}
+
+// setDebounce sets debounce_period_us, which is the first 32 bits of the
+// union.
+func (a *lineAttribute) setDebounce(us uint32) {
+	*(*uint32)(unsafe.Pointer(&a.value)) = us
+}
+
+// debounce returns debounce_period_us.
+func (a *lineAttribute) debounce() uint32 {
+	return *(*uint32)(unsafe.Pointer(&a.value))
+}
+
+// lineConfigAttribute is struct gpio_v2_line_config_attribute.
+type lineConfigAttribute struct {
+	attr lineAttribute
+	mask uint64
+}
+
+// lineConfig is struct gpio_v2_line_config.
+type lineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [10]lineConfigAttribute
+}
+
+// lineRequest is struct gpio_v2_line_request.
+type lineRequest struct {
+	offsets         [64]uint32
+	consumer        [32]byte
+	config          lineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+// lineInfo is struct gpio_v2_line_info.
+type lineInfo struct {
+	name     [32]byte
+	consumer [32]byte
+	offset   uint32
+	numAttrs uint32
+	flags    uint64
+	attrs    [10]lineAttribute
+	padding  [4]uint32
+}
+
+// lineValues is struct gpio_v2_line_values.
+type lineValues struct {
+	bits uint64
+	mask uint64
+}
+
+// lineEvent is struct gpio_v2_line_event.
+type lineEvent struct {
+	timestampNs uint64
+	id          uint32
+	offset      uint32
+	seqno       uint32
+	lineSeqno   uint32
+	padding     [6]uint32
+}
+
+//
+
+// openGPIOChip opens the GPIO character device at path and enumerates its
+// lines, numbered from the base found in b.
+func openGPIOChip(path string, b *chipBases) (*GPIOChip, error) {
+	f, err := gpioChipOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	var info chipInfo
+	if err := f.ChipInfo(&info); err != nil {
+		f.Close()
+		return nil, err
+	}
+	c := &GPIOChip{
+		name:  cString(info.name[:]),
+		label: cString(info.label[:]),
+		f:     f,
+		lines: make([]*GPIOLine, info.lines),
+	}
+	base := b.base(c.label, len(c.lines))
+	for i := range c.lines {
+		l := lineInfo{offset: uint32(i)}
+		if err := f.LineInfo(&l); err != nil {
+			f.Close()
+			return nil, err
+		}
+		n := base + i
+		c.lines[i] = &GPIOLine{
+			number:   n,
+			name:     fmt.Sprintf("GPIO%d", n),
+			chip:     c,
+			offset:   uint32(i),
+			lineName: cString(l.name[:]),
+		}
+	}
+	return c, nil
+}
+
+// sysfsChip is a GPIO controller as exposed by GPIO sysfs in
+// /sys/class/gpio/gpiochipN/, where N is the base.
+type sysfsChip struct {
+	label string
+	base  int
+	ngpio int
+}
+
+// chipBases finds the global number of the first line of each GPIO character
+// device, so the lines have the same number as the GPIO sysfs pins.
+type chipBases struct {
+	chips []sysfsChip // not yet matched
+	next  int         // base to use when no sysfs controller matches
+}
+
+// newChipBases reads the GPIO sysfs controllers.
+func newChipBases() *chipBases {
+	b := &chipBases{}
+	items, _ := filepath.Glob("/sys/class/gpio/gpiochip*")
+	for _, item := range items {
+		label, err := readString(item + "/label")
+		if err != nil {
+			continue
+		}
+		base, err := readInt(item + "/base")
+		if err != nil {
+			continue
+		}
+		ngpio, err := readInt(item + "/ngpio")
+		if err != nil {
+			continue
+		}
+		b.chips = append(b.chips, sysfsChip{label: label, base: base, ngpio: ngpio})
+		if base+ngpio > b.next {
+			b.next = base + ngpio
+		}
+	}
+	return b
+}
+
+// base returns the global number of the first line of the controller with
+// this label and number of lines.
+//
+// When GPIO sysfs is not available or doesn't expose the controller, the
+// lines are numbered after all the known ones.
+func (b *chipBases) base(label string, lines int) int {
+	for i, c := range b.chips {
+		if c.label == label && c.ngpio == lines {
+			b.chips = append(b.chips[:i], b.chips[i+1:]...)
+			return c.base
+		}
+	}
+	n := b.next
+	b.next += lines
+	return n
+}
+
+// readString reads a pseudo-file (sysfs) that is known to contain a single
+// line of text.
+func readString(path string) (string, error) {
+	f, err := fileIOOpen(path, os.O_RDONLY)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var b [64]byte
+	n, err := f.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+	raw := b[:n]
+	if len(raw) == 0 || raw[len(raw)-1] != '\n' {
+		return "", errors.New("invalid value")
+	}
+	return string(raw[:len(raw)-1]), nil
+}
+
+// gpioChipPaths returns the GPIO character devices, ordered by number.
+func gpioChipPaths() []string {
+	items, err := filepath.Glob("/dev/gpiochip*")
+	if err != nil {
+		return nil
+	}
+	num := func(s string) int {
+		i, _ := strconv.Atoi(strings.TrimPrefix(s, "/dev/gpiochip"))
+		return i
+	}
+	sort.Slice(items, func(i, j int) bool { return num(items[i]) < num(items[j]) })
+	return items
+}
+
+type driverGPIOChip struct {
+}
+
+func (d *driverGPIOChip) String() string {
+	return "sysfs-gpiochip"
+}
+
+func (d *driverGPIOChip) Prerequisites() []string {
+	return nil
+}
+
+// Init initializes GPIO character device handling code.
+//
+// Uses the GPIO character device uAPI v2 as described at
+// https://www.kernel.org/doc/html/latest/userspace-api/gpio/chardev.html
+//
+// It is available since Linux 5.10 and supersedes GPIO sysfs. When it loads,
+// the lines are registered in gpioreg instead of the sysfs pins.
+func (d *driverGPIOChip) Init() (bool, error) {
+	items := gpioChipPaths()
+	if len(items) == 0 {
+		return false, errors.New("no GPIO character device found")
+	}
+	b := newChipBases()
+	var chips []*GPIOChip
+	for _, item := range items {
+		c, err := openGPIOChip(item, b)
+		if err != nil {
+			for _, c := range chips {
+				c.f.Close()
+			}
+			if os.IsPermission(err) {
+				return true, fmt.Errorf("need more access, try as root or setup udev rules: %v", err)
+			}
+			return true, fmt.Errorf("sysfs-gpiochip: %s: %v", item, err)
+		}
+		chips = append(chips, c)
+	}
+	// Only expose the lines once they are all registered, so that driverGPIO
+	// registers the sysfs pins instead if this driver fails to load.
+	var registered []string
+	for _, c := range chips {
+		for _, l := range c.lines {
+			if err := gpioreg.Register(l, false); err != nil {
+				for _, name := range registered {
+					gpioreg.Unregister(name)
+				}
+				for _, c := range chips {
+					c.f.Close()
+				}
+				return true, err
+			}
+			registered = append(registered, l.name)
+		}
+	}
+	GPIOChips = chips
+	Lines = map[int]*GPIOLine{}
+	for _, c := range chips {
+		for _, l := range c.lines {
+			Lines[l.number] = l
+		}
+	}
+	return true, nil
+}
+
+func init() {
+	if isLinux {
+		periph.MustRegister(&driverGPIOChip{})
+	}
+}
+
+var _ gpio.PinIn = &GPIOLine{}
+var _ gpio.PinOut = &GPIOLine{}
+var _ gpio.PinIO = &GPIOLine{}
+var _ gpio.PinEdges = &GPIOLine{}
+var _ fmt.Stringer = &GPIOLine{}
+var _ fmt.Stringer = &GPIOChip{}
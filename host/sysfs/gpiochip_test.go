@@ -0,0 +1,508 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestGPIOChip_structs(t *testing.T) {
+	// The size of the struct is encoded in the ioctl number.
+	data := []struct {
+		op   uint
+		size uintptr
+	}{
+		{ioctlGetChipInfo, unsafe.Sizeof(chipInfo{})},
+		{ioctlGetLineInfo, unsafe.Sizeof(lineInfo{})},
+		{ioctlGetLine, unsafe.Sizeof(lineRequest{})},
+		{ioctlLineSetConfig, unsafe.Sizeof(lineConfig{})},
+		{ioctlLineGetValues, unsafe.Sizeof(lineValues{})},
+		{ioctlLineSetValues, unsafe.Sizeof(lineValues{})},
+	}
+	for i, line := range data {
+		if s := uintptr(line.op>>16) & 0x3FFF; s != line.size {
+			t.Fatalf("#%d: %d != %d", i, s, line.size)
+		}
+	}
+	if s := unsafe.Sizeof(lineEvent{}); s != 48 {
+		t.Fatal(s)
+	}
+}
+
+func TestGPIOChip(t *testing.T) {
+	defer reset()
+	c := newFakeChip(t)
+	chip, err := openGPIOChip("/dev/gpiochip1", &chipBases{next: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := chip.String(); s != "gpiochip1" {
+		t.Fatal(s)
+	}
+	if s := chip.Label(); s != "fake" {
+		t.Fatal(s)
+	}
+	lines := chip.Lines()
+	if len(lines) != 3 {
+		t.Fatal(len(lines))
+	}
+	p := lines[1]
+	if s := p.String(); s != "GPIO11" {
+		t.Fatal(s)
+	}
+	if s := p.Name(); s != "GPIO11" {
+		t.Fatal(s)
+	}
+	if n := p.Number(); n != 11 {
+		t.Fatal(n)
+	}
+	if n := p.Offset(); n != 1 {
+		t.Fatal(n)
+	}
+	if s := p.LineName(); s != "LED" {
+		t.Fatal(s)
+	}
+	if p.Chip() != chip {
+		t.Fatal("unexpected chip")
+	}
+	if s := p.Function(); s != "In" {
+		t.Fatal(s)
+	}
+	if s := lines[2].Function(); s != "spi0 CS0" {
+		t.Fatal(s)
+	}
+	c.err = errors.New("injected")
+	if s := p.Function(); s != "ERR" {
+		t.Fatal(s)
+	}
+	if _, err := openGPIOChip("/dev/gpiochip1", &chipBases{next: 10}); err == nil {
+		t.Fatal("expected failure")
+	}
+}
+
+func TestGPIOChip_base(t *testing.T) {
+	defer reset()
+	newFakeChip(t)
+	b := &chipBases{
+		chips: []sysfsChip{{label: "other", base: 0, ngpio: 3}, {label: "fake", base: 512, ngpio: 3}},
+		next:  515,
+	}
+	chip, err := openGPIOChip("/dev/gpiochip1", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := chip.Lines()[1].Name(); s != "GPIO513" {
+		t.Fatal(s)
+	}
+	// The sysfs controller was consumed, the next chip is numbered after the
+	// known ones.
+	chip, err = openGPIOChip("/dev/gpiochip2", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := chip.Lines()[0].Number(); n != 515 {
+		t.Fatal(n)
+	}
+	if len(b.chips) != 1 || b.next != 518 {
+		t.Fatal(b)
+	}
+}
+
+func TestGPIOLine_In(t *testing.T) {
+	defer reset()
+	c := newFakeChip(t)
+	chip, err := openGPIOChip("/dev/gpiochip0", &chipBases{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := chip.Lines()[0]
+	if pull := p.Pull(); pull != gpio.PullNoChange {
+		t.Fatal(pull)
+	}
+	if err := p.In(gpio.PullUp, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	l := c.lines[0]
+	if l.cfg.flags != lineFlagInput|lineFlagBiasPullUp {
+		t.Fatalf("0x%x", l.cfg.flags)
+	}
+	if pull := p.Pull(); pull != gpio.PullUp {
+		t.Fatal(pull)
+	}
+	l.value = true
+	if v := p.Read(); v != gpio.High {
+		t.Fatal(v)
+	}
+	if s := p.Function(); s != "In/High" {
+		t.Fatal(s)
+	}
+	// The pull is kept, and the line is reconfigured instead of requested again.
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	if c.lines[0] != l {
+		t.Fatal("line was requested again")
+	}
+	if l.cfg.flags != lineFlagInput|lineFlagBiasPullUp|lineFlagEdgeRising|lineFlagEdgeFalling {
+		t.Fatalf("0x%x", l.cfg.flags)
+	}
+	if err := p.SetDebounce(1500 * time.Microsecond); err != nil {
+		t.Fatal(err)
+	}
+	if l.cfg.numAttrs != 1 || l.cfg.attrs[0].attr.id != lineAttrDebounce || l.cfg.attrs[0].attr.debounce() != 1500 {
+		t.Fatalf("%#v", l.cfg.attrs[0])
+	}
+	if p.SetDebounce(-1) == nil {
+		t.Fatal("invalid debounce")
+	}
+	if err := p.SetActiveLow(true); err != nil {
+		t.Fatal(err)
+	}
+	if l.cfg.flags&lineFlagActiveLow == 0 {
+		t.Fatalf("0x%x", l.cfg.flags)
+	}
+	if err := p.In(gpio.Float, gpio.FallingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if l.cfg.flags != lineFlagInput|lineFlagActiveLow|lineFlagBiasDisabled|lineFlagEdgeFalling {
+		t.Fatalf("0x%x", l.cfg.flags)
+	}
+	if pull := p.Pull(); pull != gpio.Float {
+		t.Fatal(pull)
+	}
+	if err := p.In(gpio.PullDown, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if pull := p.Pull(); pull != gpio.PullDown {
+		t.Fatal(pull)
+	}
+	l.err = errors.New("injected")
+	if p.In(gpio.PullDown, gpio.NoEdge) == nil {
+		t.Fatal("expected failure")
+	}
+	if v := p.Read(); v != gpio.Low {
+		t.Fatal(v)
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if !l.closed {
+		t.Fatal("line not released")
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGPIOLine_Read(t *testing.T) {
+	defer reset()
+	c := newFakeChip(t)
+	chip, err := openGPIOChip("/dev/gpiochip0", &chipBases{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := chip.Lines()[0]
+	// Requested as an input on first read.
+	if v := p.Read(); v != gpio.Low {
+		t.Fatal(v)
+	}
+	if c.lines[0].cfg.flags != lineFlagInput {
+		t.Fatalf("0x%x", c.lines[0].cfg.flags)
+	}
+	if s := c.lines[0].consumer; s != "periph" {
+		t.Fatal(s)
+	}
+	p = chip.Lines()[1]
+	c.err = errors.New("injected")
+	if v := p.Read(); v != gpio.Low {
+		t.Fatal(v)
+	}
+	if err := p.In(gpio.PullNoChange, gpio.NoEdge); err == nil {
+		t.Fatal("expected failure")
+	}
+}
+
+func TestGPIOLine_Out(t *testing.T) {
+	defer reset()
+	c := newFakeChip(t)
+	chip, err := openGPIOChip("/dev/gpiochip0", &chipBases{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := chip.Lines()[1]
+	if err := p.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	l := c.lines[1]
+	if l.cfg.flags != lineFlagOutput {
+		t.Fatalf("0x%x", l.cfg.flags)
+	}
+	if a := l.cfg.attrs[0]; l.cfg.numAttrs != 1 || a.attr.id != lineAttrOutputValues || a.attr.value != 1 || a.mask != 1 {
+		t.Fatalf("%#v", a)
+	}
+	if !l.value {
+		t.Fatal("expected high")
+	}
+	if s := p.Function(); s != "Out/High" {
+		t.Fatal(s)
+	}
+	if err := p.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if l.value {
+		t.Fatal("expected low")
+	}
+	// Changing the polarity of an output reconfigures it.
+	if err := p.SetActiveLow(true); err != nil {
+		t.Fatal(err)
+	}
+	if l.cfg.flags != lineFlagOutput {
+		t.Fatalf("0x%x", l.cfg.flags)
+	}
+	if err := p.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if l.cfg.flags != lineFlagOutput|lineFlagActiveLow {
+		t.Fatalf("0x%x", l.cfg.flags)
+	}
+	l.err = errors.New("injected")
+	if p.Out(gpio.Low) == nil {
+		t.Fatal("expected failure")
+	}
+	if s := p.Function(); s != "Out/Low" {
+		t.Fatal(s)
+	}
+}
+
+func TestGPIOLine_WaitForEdge(t *testing.T) {
+	defer reset()
+	c := newFakeChip(t)
+	chip, err := openGPIOChip("/dev/gpiochip0", &chipBases{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := chip.Lines()[0]
+	if p.WaitForEdge(0) {
+		t.Fatal("edge detection is not enabled")
+	}
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	l := c.lines[0]
+	l.events = []lineEvent{
+		{timestampNs: 1000, id: lineEventRisingEdge, lineSeqno: 1},
+		{timestampNs: 2500, id: 2, lineSeqno: 2},
+	}
+	if !p.WaitForEdge(-1) {
+		t.Fatal("expected edge")
+	}
+	if !p.WaitForEdge(time.Second) {
+		t.Fatal("expected edge")
+	}
+	if p.WaitForEdge(time.Millisecond) {
+		t.Fatal("unexpected edge")
+	}
+	// Edges accumulated before In() are flushed.
+	l.events = []lineEvent{{timestampNs: 1000, id: lineEventRisingEdge, lineSeqno: 3}}
+	if err := p.In(gpio.PullNoChange, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.events) != 0 {
+		t.Fatal("edges were not flushed")
+	}
+	if err := p.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	l.events = []lineEvent{{timestampNs: 1000, id: lineEventRisingEdge, lineSeqno: 4}}
+	if p.WaitForEdge(0) {
+		t.Fatal("edge detection is not enabled")
+	}
+}
+
+func TestGPIOLine_Edges(t *testing.T) {
+	defer reset()
+	c := newFakeChip(t)
+	chip, err := openGPIOChip("/dev/gpiochip0", &chipBases{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := chip.Lines()[0]
+	if _, err := p.Edges(1); err == nil {
+		t.Fatal("edge detection is not enabled")
+	}
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	c.lines[0].events = []lineEvent{
+		{timestampNs: 1000, id: lineEventRisingEdge, lineSeqno: 1},
+		{timestampNs: 2500, id: 2, lineSeqno: 2},
+	}
+	ch, err := p.Edges(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e1 := <-ch
+	e2 := <-ch
+	if e1.L != gpio.High || e1.Seq != 1 || e2.L != gpio.Low || e2.Seq != 2 {
+		t.Fatalf("%#v %#v", e1, e2)
+	}
+	// The timestamps are converted from CLOCK_MONOTONIC, which adds jitter.
+	if d := e2.T.Sub(e1.T); d < 0 || d > time.Millisecond {
+		t.Fatal(d)
+	}
+	if d := time.Since(e1.T); d < 0 {
+		t.Fatal(d)
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("expected the channel to be closed")
+	}
+}
+
+func TestGPIOLine_not_initialized(t *testing.T) {
+	p := GPIOLine{number: 1, name: "GPIO1"}
+	if p.Out(gpio.Low) == nil {
+		t.Fatal("expected failure")
+	}
+	if p.In(gpio.PullNoChange, gpio.NoEdge) == nil {
+		t.Fatal("expected failure")
+	}
+}
+
+func TestGPIOChipDriver(t *testing.T) {
+	if len((&driverGPIOChip{}).Prerequisites()) != 0 {
+		t.Fatal("unexpected GPIO chip prerequisites")
+	}
+	if s := (&driverGPIOChip{}).String(); s != "sysfs-gpiochip" {
+		t.Fatal(s)
+	}
+}
+
+//
+
+// fakeChip implements chipFile with three lines.
+type fakeChip struct {
+	t     *testing.T
+	lines map[uint32]*fakeLine // requested lines
+	err   error
+}
+
+func newFakeChip(t *testing.T) *fakeChip {
+	c := &fakeChip{t: t, lines: map[uint32]*fakeLine{}}
+	gpioChipOpen = func(path string) (chipFile, error) {
+		return c, nil
+	}
+	return c
+}
+
+func (c *fakeChip) Close() error {
+	return nil
+}
+
+func (c *fakeChip) ChipInfo(i *chipInfo) error {
+	if c.err != nil {
+		return c.err
+	}
+	copy(i.name[:], "gpiochip1")
+	copy(i.label[:], "fake")
+	i.lines = 3
+	return nil
+}
+
+func (c *fakeChip) LineInfo(i *lineInfo) error {
+	if c.err != nil {
+		return c.err
+	}
+	switch i.offset {
+	case 1:
+		copy(i.name[:], "LED")
+	case 2:
+		copy(i.consumer[:], "spi0 CS0")
+		i.flags = lineFlagUsed | lineFlagOutput
+	}
+	return nil
+}
+
+func (c *fakeChip) GetLine(r *lineRequest) (lineFile, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if r.numLines != 1 {
+		c.t.Fatal(r.numLines)
+	}
+	l := &fakeLine{consumer: cString(r.consumer[:])}
+	if err := l.SetConfig(&r.config); err != nil {
+		return nil, err
+	}
+	c.lines[r.offsets[0]] = l
+	return l, nil
+}
+
+// fakeLine implements lineFile.
+type fakeLine struct {
+	consumer string
+	cfg      lineConfig
+	value    bool
+	events   []lineEvent
+	closed   bool
+	err      error
+}
+
+func (l *fakeLine) Close() error {
+	l.closed = true
+	return nil
+}
+
+func (l *fakeLine) Read(b []byte) (int, error) {
+	if len(l.events) == 0 {
+		return 0, errors.New("i/o timeout")
+	}
+	e := l.events[0]
+	l.events = l.events[1:]
+	return copy(b, (*[unsafe.Sizeof(e)]byte)(unsafe.Pointer(&e))[:]), nil
+}
+
+func (l *fakeLine) Wait(timeout time.Duration) bool {
+	return len(l.events) != 0
+}
+
+func (l *fakeLine) SetConfig(c *lineConfig) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.cfg = *c
+	for i := uint32(0); i < c.numAttrs; i++ {
+		if a := c.attrs[i]; a.attr.id == lineAttrOutputValues {
+			l.value = a.attr.value&1 != 0
+		}
+	}
+	return nil
+}
+
+func (l *fakeLine) GetValues(v *lineValues) error {
+	if l.err != nil {
+		return l.err
+	}
+	v.bits = 0
+	if l.value {
+		v.bits = 1
+	}
+	return nil
+}
+
+func (l *fakeLine) SetValues(v *lineValues) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.value = v.bits&1 != 0
+	return nil
+}
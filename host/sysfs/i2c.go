@@ -5,6 +5,7 @@
 package sysfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -16,10 +17,12 @@ import (
 	"unsafe"
 
 	"periph.io/x/periph"
+	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/conn/i2c"
 	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/smbus"
 )
 
 // SetSpeedHook can be set by a driver to enable changing the I²C buses speed.
@@ -45,6 +48,7 @@ type I2C struct {
 
 	mu  sync.Mutex // In theory the kernel probably has an internal lock but not taking any chance.
 	fn  functionality
+	pec bool // Current I2C_PEC setting of f.
 	scl gpio.PinIO
 	sda gpio.PinIO
 }
@@ -106,42 +110,85 @@ func (i *I2C) String() string {
 }
 
 // Tx execute a transaction as a single operation unit.
+//
+// When the adapter only supports SMBus, the transaction is converted to the
+// matching SMBus transaction type, if any.
 func (i *I2C) Tx(addr uint16, w, r []byte) error {
-	if addr >= 0x400 || (addr >= 0x80 && i.fn&func10BitAddr == 0) {
-		return errors.New("sysfs-i2c: invalid address")
+	return i.TxContext(context.Background(), addr, w, r)
+}
+
+// TxContext implements i2c.TxContexter.
+//
+// ctx is only checked before the transaction is handed to the kernel,
+// including after waiting for a concurrent transaction to complete. The
+// kernel call itself cannot be interrupted and is bound by the I²C adapter's
+// timeout; its result is returned even if ctx is done in the meantime.
+func (i *I2C) TxContext(ctx context.Context, addr uint16, w, r []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := i.checkAddr(addr); err != nil {
+		return err
 	}
 	if len(w) == 0 && len(r) == 0 {
 		return nil
 	}
+	if i.fn&funcI2C == 0 {
+		return i.smbusFromTx(ctx, addr, w, r)
+	}
 
 	// Convert the messages to the internal format.
 	var buf [2]i2cMsg
 	msgs := buf[0:0]
 	if len(w) != 0 {
 		msgs = buf[:1]
-		buf[0].addr = addr
-		buf[0].length = uint16(len(w))
-		buf[0].buf = uintptr(unsafe.Pointer(&w[0]))
+		buf[0].set(addr, 0, w)
 	}
 	if len(r) != 0 {
 		l := len(msgs)
 		msgs = msgs[:l+1] // extend the slice by one
-		buf[l].addr = addr
-		buf[l].flags = flagRD
-		buf[l].length = uint16(len(r))
-		buf[l].buf = uintptr(unsafe.Pointer(&r[0]))
+		buf[l].set(addr, flagRD, r)
 	}
-	p := rdwrIoctlData{
-		msgs:  uintptr(unsafe.Pointer(&msgs[0])),
-		nmsgs: uint32(len(msgs)),
+	return i.rdwr(ctx, msgs)
+}
+
+// TxMessages implements i2c.TxMessager.
+//
+// All the segments are sent as a single I2C_RDWR kernel call.
+func (i *I2C) TxMessages(msgs []i2c.Msg) error {
+	if len(msgs) == 0 {
+		return errors.New("sysfs-i2c: no message")
 	}
-	pp := uintptr(unsafe.Pointer(&p))
-	i.mu.Lock()
-	defer i.mu.Unlock()
-	if err := i.f.Ioctl(ioctlRdwr, pp); err != nil {
-		return fmt.Errorf("sysfs-i2c: %v", err)
+	if len(msgs) > i2cRdwrMaxMsgs {
+		return fmt.Errorf("sysfs-i2c: maximum of %d messages, got %d", i2cRdwrMaxMsgs, len(msgs))
 	}
-	return nil
+	if i.fn&funcI2C == 0 {
+		return &conn.Error{Kind: conn.ErrUnsupported, Msg: "sysfs-i2c: adapter only supports SMBus"}
+	}
+	buf := make([]i2cMsg, len(msgs))
+	for x := range msgs {
+		if err := i.checkAddr(msgs[x].Addr); err != nil {
+			return err
+		}
+		if len(msgs[x].W) != 0 && len(msgs[x].R) != 0 {
+			return fmt.Errorf("sysfs-i2c: message #%d has both W and R set", x)
+		}
+		if len(msgs[x].R) != 0 {
+			buf[x].set(msgs[x].Addr, flagRD, msgs[x].R)
+		} else {
+			buf[x].set(msgs[x].Addr, 0, msgs[x].W)
+		}
+	}
+	return i.rdwr(context.Background(), buf)
+}
+
+// SMBusTx implements smbus.Bus.
+//
+// It uses the kernel's SMBus support, which either uses the adapter's native
+// SMBus capability or emulates it over I²C. The adapter must support the
+// transaction type requested.
+func (i *I2C) SMBusTx(addr uint16, read bool, cmd byte, p smbus.Protocol, data []byte, pec bool) error {
+	return i.smbusTx(context.Background(), addr, read, cmd, p, data, pec)
 }
 
 // SetSpeed implements i2c.Bus.
@@ -154,7 +201,7 @@ func (i *I2C) SetSpeed(hz int64) error {
 	if setSpeed != nil {
 		return setSpeed(hz)
 	}
-	return errors.New("sysfs-i2c: not supported")
+	return &conn.Error{Kind: conn.ErrUnsupported, Msg: "sysfs-i2c: not supported"}
 }
 
 // SCL implements i2c.Pins.
@@ -171,6 +218,135 @@ func (i *I2C) SDA() gpio.PinIO {
 
 // Private details.
 
+// rdwr sends the messages as a single I2C_RDWR transaction.
+func (i *I2C) rdwr(ctx context.Context, msgs []i2cMsg) error {
+	p := rdwrIoctlData{
+		msgs:  uintptr(unsafe.Pointer(&msgs[0])),
+		nmsgs: uint32(len(msgs)),
+	}
+	pp := uintptr(unsafe.Pointer(&p))
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := i.f.Ioctl(ioctlRdwr, pp); err != nil {
+		return wrapErr("sysfs-i2c", err)
+	}
+	return nil
+}
+
+func (i *I2C) checkAddr(addr uint16) error {
+	if addr >= 0x400 || (addr >= 0x80 && i.fn&func10BitAddr == 0) {
+		return errors.New("sysfs-i2c: invalid address")
+	}
+	return nil
+}
+
+// smbusFromTx converts a plain I²C transaction into the equivalent SMBus
+// transaction for adapters that only support SMBus.
+func (i *I2C) smbusFromTx(ctx context.Context, addr uint16, w, r []byte) error {
+	lw, lr := len(w), len(r)
+	switch {
+	case lw == 1 && lr == 0:
+		return i.smbusTx(ctx, addr, false, 0, smbus.Byte, w, false)
+	case lw == 0 && lr == 1:
+		return i.smbusTx(ctx, addr, true, 0, smbus.Byte, r, false)
+	case lw == 2 && lr == 0:
+		return i.smbusTx(ctx, addr, false, w[0], smbus.ByteData, w[1:], false)
+	case lw == 3 && lr == 0:
+		return i.smbusTx(ctx, addr, false, w[0], smbus.WordData, w[1:], false)
+	case lw == 1 && lr == 1:
+		return i.smbusTx(ctx, addr, true, w[0], smbus.ByteData, r, false)
+	case lw == 1 && lr == 2:
+		return i.smbusTx(ctx, addr, true, w[0], smbus.WordData, r, false)
+	case lw == 3 && lr == 2:
+		data := []byte{w[1], w[2]}
+		if err := i.smbusTx(ctx, addr, false, w[0], smbus.ProcCall, data, false); err != nil {
+			return err
+		}
+		copy(r, data)
+		return nil
+	case lw == 1 && lr <= smbus.MaxBlockSize:
+		data := make([]byte, 1+lr)
+		data[0] = byte(lr)
+		if err := i.smbusTx(ctx, addr, true, w[0], smbus.I2CBlockData, data, false); err != nil {
+			return err
+		}
+		copy(r, data[1:])
+		return nil
+	case lw > 3 && lw <= smbus.MaxBlockSize+1 && lr == 0:
+		data := append([]byte{byte(lw - 1)}, w[1:]...)
+		return i.smbusTx(ctx, addr, false, w[0], smbus.I2CBlockData, data, false)
+	default:
+		return &conn.Error{Kind: conn.ErrUnsupported, Msg: fmt.Sprintf("sysfs-i2c: adapter only supports SMBus; can't write %d bytes and read %d bytes", lw, lr)}
+	}
+}
+
+func (i *I2C) smbusTx(ctx context.Context, addr uint16, read bool, cmd byte, p smbus.Protocol, data []byte, pec bool) error {
+	if addr >= 0x80 {
+		return errors.New("sysfs-i2c: invalid SMBus address")
+	}
+	size, f, ok := smbusSize(p, read)
+	if !ok {
+		return fmt.Errorf("sysfs-i2c: invalid SMBus protocol %s", p)
+	}
+	if i.fn&f == 0 {
+		return &conn.Error{Kind: conn.ErrUnsupported, Msg: fmt.Sprintf("sysfs-i2c: SMBus %s is not supported by the adapter (%s)", p, i.fn)}
+	}
+	if pec && i.fn&funcSMBusPEC == 0 {
+		return &conn.Error{Kind: conn.ErrUnsupported, Msg: "sysfs-i2c: SMBus PEC is not supported by the adapter"}
+	}
+	if len(data) > len(smbusData{}) {
+		return fmt.Errorf("sysfs-i2c: SMBus data too large: %d bytes", len(data))
+	}
+	var buf smbusData
+	d := smbusIoctlData{command: cmd, size: size}
+	if read {
+		d.readWrite = 1
+	}
+	switch {
+	case p == smbus.Quick:
+	case p == smbus.Byte && !read:
+		// The byte sent is passed as the command.
+		if len(data) != 1 {
+			return errors.New("sysfs-i2c: SMBus Byte requires 1 byte")
+		}
+		d.command = data[0]
+	default:
+		copy(buf[:], data)
+		d.data = uintptr(unsafe.Pointer(&buf[0]))
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := i.f.Ioctl(ioctlSlave, uintptr(addr)); err != nil {
+		return wrapKind("sysfs-i2c", addrErrKind(err), err)
+	}
+	if pec != i.pec {
+		v := uintptr(0)
+		if pec {
+			v = 1
+		}
+		if err := i.f.Ioctl(ioctlPEC, v); err != nil {
+			return wrapErr("sysfs-i2c", err)
+		}
+		i.pec = pec
+	}
+	if err := i.f.Ioctl(ioctlSMBus, uintptr(unsafe.Pointer(&d))); err != nil {
+		return wrapErr("sysfs-i2c", err)
+	}
+	if read || p == smbus.ProcCall || p == smbus.BlockProcCall {
+		if (p == smbus.BlockData || p == smbus.BlockProcCall) && int(buf[0]) >= len(data) {
+			return fmt.Errorf("sysfs-i2c: SMBus block of %d bytes doesn't fit in %d bytes", buf[0], len(data)-1)
+		}
+		copy(data, buf[:])
+	}
+	return nil
+}
+
 func (i *I2C) initPins() {
 	i.mu.Lock()
 	if i.scl == nil {
@@ -195,6 +371,8 @@ const (
 	ioctlTenBits = 0x704 // TODO(maruel): Expose this but the header says it's broken (!?)
 	ioctlFuncs   = 0x705
 	ioctlRdwr    = 0x707
+	ioctlPEC     = 0x708
+	ioctlSMBus   = 0x720
 )
 
 // flags
@@ -292,6 +470,69 @@ func (f functionality) String() string {
 	return strings.Join(out, "|")
 }
 
+// smbusSize returns the kernel's transaction size and the functionality bit
+// needed for the SMBus protocol p.
+func smbusSize(p smbus.Protocol, read bool) (uint32, functionality, bool) {
+	switch p {
+	case smbus.Quick:
+		return smbusQuick, funcSMBusQuick, true
+	case smbus.Byte:
+		if read {
+			return smbusByte, funcSMBusReadByte, true
+		}
+		return smbusByte, funcSMBusWriteByte, true
+	case smbus.ByteData:
+		if read {
+			return smbusByteData, funcSMBusReadByteData, true
+		}
+		return smbusByteData, funcSMBusWriteByteData, true
+	case smbus.WordData:
+		if read {
+			return smbusWordData, funcSMBusReadWordData, true
+		}
+		return smbusWordData, funcSMBusWriteWordData, true
+	case smbus.ProcCall:
+		return smbusProcCall, funcSMBusProcCall, true
+	case smbus.BlockData:
+		if read {
+			return smbusBlockData, funcSMBusReadBlockData, true
+		}
+		return smbusBlockData, funcSMBusWriteBlockData, true
+	case smbus.BlockProcCall:
+		return smbusBlockProcCall, funcSMBusBlockProcCall, true
+	case smbus.I2CBlockData:
+		if read {
+			return smbusI2CBlockData, funcSMBusReadI2CBlock, true
+		}
+		return smbusI2CBlockData, funcSMBusWriteI2CBlock, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// SMBus transaction sizes, as the size member of i2c_smbus_ioctl_data.
+const (
+	smbusQuick         = 0
+	smbusByte          = 1
+	smbusByteData      = 2
+	smbusWordData      = 3
+	smbusProcCall      = 4
+	smbusBlockData     = 5
+	smbusBlockProcCall = 7
+	smbusI2CBlockData  = 8
+)
+
+// smbusIoctlData is i2c_smbus_ioctl_data in linux/i2c-dev.h.
+type smbusIoctlData struct {
+	readWrite uint8
+	command   uint8
+	size      uint32
+	data      uintptr // Pointer to smbusData
+}
+
+// smbusData is union i2c_smbus_data in linux/i2c.h.
+type smbusData [smbus.MaxBlockSize + 2]byte
+
 type rdwrIoctlData struct {
 	msgs  uintptr // Pointer to i2cMsg
 	nmsgs uint32
@@ -304,6 +545,22 @@ type i2cMsg struct {
 	buf    uintptr
 }
 
+// set initializes the message to transfer b to or from addr.
+func (m *i2cMsg) set(addr, flags uint16, b []byte) {
+	m.addr = addr
+	m.flags = flags
+	if addr >= 0x80 {
+		m.flags |= flagTEN
+	}
+	m.length = uint16(len(b))
+	if len(b) != 0 {
+		m.buf = uintptr(unsafe.Pointer(&b[0]))
+	}
+}
+
+// i2cRdwrMaxMsgs is I2C_RDWR_IOCTL_MAX_MSGS in linux/i2c-dev.h.
+const i2cRdwrMaxMsgs = 42
+
 var (
 	i2cMu    sync.Mutex
 	setSpeed func(hz int64) error
@@ -369,4 +626,7 @@ func init() {
 }
 
 var _ i2c.Bus = &I2C{}
+var _ i2c.TxContexter = &I2C{}
+var _ smbus.Bus = &I2C{}
+var _ i2c.TxMessager = &I2C{}
 var _ fmt.Stringer = &I2C{}
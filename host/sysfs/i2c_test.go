@@ -5,10 +5,15 @@
 package sysfs
 
 import (
+	"context"
 	"log"
+	"syscall"
 	"testing"
 
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/i2c"
 	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/smbus"
 )
 
 func ExampleNewI2C() {
@@ -52,6 +57,113 @@ func TestI2C_faked(t *testing.T) {
 	}
 }
 
+func TestI2C_TxContext(t *testing.T) {
+	bus := I2C{f: &ioctlClose{}, busNumber: 24, fn: funcI2C}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := bus.TxContext(ctx, 1, []byte{0}, nil); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := bus.TxContext(ctx, 1, []byte{0}, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+}
+
+func TestI2C_TxMessages(t *testing.T) {
+	f := &ioctlRecord{}
+	bus := I2C{f: f, busNumber: 24, fn: funcI2C}
+	msgs := []i2c.Msg{{Addr: 0x10, W: []byte{1}}, {Addr: 0x11, W: []byte{2}}, {Addr: 0x10, R: []byte{0}}}
+	if err := bus.TxMessages(msgs); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.ops) != 1 || f.ops[0] != ioctlRdwr {
+		t.Fatal(f.ops)
+	}
+	if bus.TxMessages(nil) == nil {
+		t.Fatal("no message")
+	}
+	if bus.TxMessages(make([]i2c.Msg, i2cRdwrMaxMsgs+1)) == nil {
+		t.Fatal("too many messages")
+	}
+	if bus.TxMessages([]i2c.Msg{{Addr: 0x80}}) == nil {
+		t.Fatal("10 bits address is not supported")
+	}
+	bus.fn = funcSMBusQuick
+	if bus.TxMessages(msgs) == nil {
+		t.Fatal("SMBus only")
+	}
+}
+
+func TestI2C_SMBusTx(t *testing.T) {
+	f := &ioctlRecord{}
+	bus := I2C{f: f, busNumber: 24, fn: funcSMBusReadWordData | funcSMBusWriteByte | funcSMBusPEC}
+	if err := bus.SMBusTx(0x10, true, 0x04, smbus.WordData, make([]byte, 2), true); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.ops) != 3 || f.ops[0] != ioctlSlave || f.ops[1] != ioctlPEC || f.ops[2] != ioctlSMBus {
+		t.Fatal(f.ops)
+	}
+	if !bus.pec {
+		t.Fatal("PEC must be enabled")
+	}
+	// Tx is converted to SMBus when the adapter doesn't support I²C.
+	if err := bus.Tx(0x10, []byte{0x42}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.ops) != 6 || f.ops[4] != ioctlPEC {
+		t.Fatal(f.ops)
+	}
+	if bus.Tx(0x10, []byte{0x42, 0x43}, nil) == nil {
+		t.Fatal("WriteByteData is not supported")
+	}
+	if bus.Tx(0x10, make([]byte, 40), nil) == nil {
+		t.Fatal("can't be converted to SMBus")
+	}
+	if bus.SMBusTx(0x10, false, 0x04, smbus.WordData, make([]byte, 2), false) == nil {
+		t.Fatal("WriteWordData is not supported")
+	}
+	if bus.SMBusTx(0x80, true, 0x04, smbus.WordData, make([]byte, 2), false) == nil {
+		t.Fatal("invalid address")
+	}
+	if bus.SMBusTx(0x10, true, 0x04, smbus.Protocol(100), nil, false) == nil {
+		t.Fatal("invalid protocol")
+	}
+}
+
+func TestI2C_errors(t *testing.T) {
+	if !isLinux {
+		t.Skip("errno mapping is only done on linux")
+	}
+	bus := I2C{f: &ioctlRecord{err: syscall.ENXIO}, busNumber: 24, fn: funcI2C}
+	err := bus.Tx(0x10, []byte{0}, nil)
+	if e, ok := err.(*conn.Error); !ok || e.Kind != conn.ErrNotAcknowledged || e.Err != syscall.ENXIO {
+		t.Fatal(err)
+	}
+	if s := err.Error(); s != "sysfs-i2c: "+syscall.ENXIO.Error() {
+		t.Fatal(s)
+	}
+	bus = I2C{f: &ioctlRecord{err: syscall.EAGAIN}, busNumber: 24, fn: funcSMBusQuick}
+	if err := bus.SMBusTx(0x10, false, 0, smbus.Quick, nil, false); !conn.IsKind(err, conn.ErrBusBusy) {
+		t.Fatal(err)
+	}
+	// The address is bound to a kernel driver.
+	busy := I2C{f: &ioctlRecord{err: syscall.EBUSY}, busNumber: 24, fn: funcSMBusQuick}
+	if err := busy.SMBusTx(0x10, false, 0, smbus.Quick, nil, false); !conn.IsKind(err, conn.ErrInUse) || conn.IsTransient(err) {
+		t.Fatal(err)
+	}
+	if err := bus.SMBusTx(0x10, false, 0, smbus.Quick, nil, true); !conn.IsKind(err, conn.ErrUnsupported) {
+		t.Fatal(err)
+	}
+	if err := bus.Tx(0x10, make([]byte, 40), nil); !conn.IsKind(err, conn.ErrUnsupported) {
+		t.Fatal(err)
+	}
+	bus = I2C{f: &ioctlRecord{err: syscall.EIO}, busNumber: 24, fn: funcI2C}
+	err = bus.Tx(0x10, []byte{0}, nil)
+	if _, ok := err.(*conn.Error); err == nil || ok {
+		t.Fatal(err)
+	}
+}
+
 func TestI2C_functionality(t *testing.T) {
 	expected := "I2C|10BIT_ADDR|PROTOCOL_MANGLING|SMBUS_PEC|NOSTART|SMBUS_BLOCK_PROC_CALL|SMBUS_QUICK|SMBUS_READ_BYTE|SMBUS_WRITE_BYTE|SMBUS_READ_BYTE_DATA|SMBUS_WRITE_BYTE_DATA|SMBUS_READ_WORD_DATA|SMBUS_WRITE_WORD_DATA|SMBUS_PROC_CALL|SMBUS_READ_BLOCK_DATA|SMBUS_WRITE_BLOCK_DATA|SMBUS_READ_I2C_BLOCK|SMBUS_WRITE_I2C_BLOCK"
 	if s := functionality(0xFFFFFFFF).String(); s != expected {
@@ -99,3 +211,20 @@ func TestDriver_Init(t *testing.T) {
 		t.Fatal("second SetSpeedHook must fail")
 	}
 }
+
+//
+
+// ioctlRecord records the ioctl operations done.
+type ioctlRecord struct {
+	ops []uint
+	err error
+}
+
+func (i *ioctlRecord) Ioctl(op uint, data uintptr) error {
+	i.ops = append(i.ops, op)
+	return i.err
+}
+
+func (i *ioctlRecord) Close() error {
+	return nil
+}
@@ -0,0 +1,390 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/pin"
+	"periph.io/x/periph/conn/pin/pinreg"
+)
+
+// PWMs is all the PWM channels discovered on this host via sysfs.
+//
+// The channels are sorted by chip then by channel number. They are also
+// registered in pinreg as the header "PWM", one channel per row. This global
+// variable is initialized once at driver initialization and isn't mutated
+// afterward. Do not modify it.
+var PWMs []*PWM
+
+// PWMByName returns a *PWM for the channel name, like "PWM0_1" for the
+// channel 1 of /sys/class/pwm/pwmchip0.
+func PWMByName(name string) (*PWM, error) {
+	for _, p := range PWMs {
+		if p.name == name {
+			return p, nil
+		}
+	}
+	return nil, errors.New("sysfs-pwm: invalid PWM name")
+}
+
+// PWM represents one channel of a PWM chip, as found by sysfs.
+//
+// The channel is exported on first use and is never unexported.
+type PWM struct {
+	number  int
+	name    string
+	chip    int
+	channel int
+	root    string // Something like /sys/class/pwm/pwmchip0/
+
+	mu        sync.Mutex
+	fEnable   fileIO // handle to pwm*/enable; never closed
+	fPeriod   fileIO // handle to pwm*/period; never closed
+	fDuty     fileIO // handle to pwm*/duty_cycle; never closed
+	fPolarity fileIO // handle to pwm*/polarity; never closed
+	buf       [24]byte
+}
+
+func (p *PWM) String() string {
+	return fmt.Sprintf("%s(%d)", p.name, p.number)
+}
+
+// Name implements pin.Pin.
+func (p *PWM) Name() string {
+	return p.name
+}
+
+// Number implements pin.Pin.
+//
+// It is the index of the channel in PWMs.
+func (p *PWM) Number() int {
+	return p.number
+}
+
+// Function implements pin.Pin.
+func (p *PWM) Function() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.open(); err != nil {
+		return "ERR"
+	}
+	v, err := p.readInt(p.fEnable)
+	if err != nil {
+		return "ERR"
+	}
+	if v != 0 {
+		return "PWM/On"
+	}
+	return "PWM/Off"
+}
+
+// Chip returns the number of the pwmchip the channel belongs to.
+func (p *PWM) Chip() int {
+	return p.chip
+}
+
+// Channel returns the channel number in its pwmchip.
+func (p *PWM) Channel() int {
+	return p.channel
+}
+
+// Halt implements conn.Resource.
+//
+// It disables the output.
+func (p *PWM) Halt() error {
+	return p.Enable(false)
+}
+
+// PWM implements gpio.PinPWM.
+//
+// Using 0 as period keeps the current period, or selects 1ms if no period was
+// set. The output is enabled.
+func (p *PWM) PWM(duty gpio.Duty, period time.Duration) error {
+	if !duty.Valid() {
+		return fmt.Errorf("sysfs-pwm: invalid duty %d", duty)
+	}
+	if period < 0 {
+		return fmt.Errorf("sysfs-pwm: invalid period %s", period)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.open(); err != nil {
+		return err
+	}
+	cur, err := p.readInt(p.fPeriod)
+	if err != nil {
+		return p.wrap(err)
+	}
+	if period == 0 {
+		period = time.Duration(cur)
+		if period == 0 {
+			period = defaultPWMPeriod
+		}
+	}
+	ns := int64(period)
+	d := ns * int64(duty) / int64(gpio.DutyMax)
+	// The driver rejects a duty cycle longer than the period, so the order of
+	// the writes depends on whether the period grows or shrinks.
+	if ns < cur {
+		err = p.writeInt(p.fDuty, d)
+		if err == nil {
+			err = p.writeInt(p.fPeriod, ns)
+		}
+	} else {
+		err = p.writeInt(p.fPeriod, ns)
+		if err == nil {
+			err = p.writeInt(p.fDuty, d)
+		}
+	}
+	if err == nil {
+		err = p.writeInt(p.fEnable, 1)
+	}
+	if err != nil {
+		return p.wrap(err)
+	}
+	return nil
+}
+
+// Duty returns the current duty cycle and period.
+//
+// It returns 0, 0 if they cannot be read.
+func (p *PWM) Duty() (gpio.Duty, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.open(); err != nil {
+		return 0, 0
+	}
+	period, err := p.readInt(p.fPeriod)
+	if err != nil || period == 0 {
+		return 0, 0
+	}
+	d, err := p.readInt(p.fDuty)
+	if err != nil {
+		return 0, 0
+	}
+	return gpio.Duty((d*int64(gpio.DutyMax) + period/2) / period), time.Duration(period)
+}
+
+// Enable enables or disables the output.
+//
+// A period has to be set with PWM() before the output can be enabled.
+func (p *PWM) Enable(enable bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.open(); err != nil {
+		return err
+	}
+	var v int64
+	if enable {
+		v = 1
+	}
+	if err := p.writeInt(p.fEnable, v); err != nil {
+		return p.wrap(err)
+	}
+	return nil
+}
+
+// SetInverted sets the polarity of the output. When inverted, the output is
+// low during the duty cycle.
+//
+// Many drivers only accept a change of polarity while the output is disabled.
+func (p *PWM) SetInverted(inverted bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.open(); err != nil {
+		return err
+	}
+	b := bNormal
+	if inverted {
+		b = bInversed
+	}
+	if err := seekWrite(p.fPolarity, b); err != nil {
+		return p.wrap(err)
+	}
+	return nil
+}
+
+// Inverted returns true if the polarity of the output is inverted.
+func (p *PWM) Inverted() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.open(); err != nil {
+		return false
+	}
+	n, err := seekRead(p.fPolarity, p.buf[:])
+	return err == nil && strings.TrimSpace(string(p.buf[:n])) == string(bInversed)
+}
+
+//
+
+const defaultPWMPeriod = time.Millisecond
+
+var (
+	bNormal   = []byte("normal")
+	bInversed = []byte("inversed")
+)
+
+// pwmRoot is where the pwm chips are found; it is overridden in unit tests.
+var pwmRoot = "/sys/class/pwm/"
+
+// open exports the channel if needed and opens its files.
+//
+// lock must be held.
+func (p *PWM) open() error {
+	if p.fEnable != nil {
+		return nil
+	}
+	base := fmt.Sprintf("%spwm%d/", p.root, p.channel)
+	if _, err := os.Stat(base); os.IsNotExist(err) {
+		f, err := fileIOOpen(p.root+"export", os.O_WRONLY)
+		if err != nil {
+			if os.IsPermission(err) {
+				return fmt.Errorf("need more access, try as root or setup udev rules: %v", err)
+			}
+			return p.wrap(err)
+		}
+		_, err = f.Write([]byte(strconv.Itoa(p.channel)))
+		f.Close()
+		if err != nil && !isErrBusy(err) {
+			return p.wrap(err)
+		}
+	}
+	// Like for GPIO, udev may still be updating the permissions of the files
+	// right after the export.
+	var err error
+	timeout := 5 * time.Second
+	for start := time.Now(); time.Since(start) < timeout; {
+		if p.fEnable, err = fileIOOpen(base+"enable", os.O_RDWR); err == nil || !os.IsPermission(err) {
+			break
+		}
+	}
+	if err != nil {
+		p.fEnable = nil
+		return p.wrap(err)
+	}
+	if p.fPeriod, err = fileIOOpen(base+"period", os.O_RDWR); err == nil {
+		if p.fDuty, err = fileIOOpen(base+"duty_cycle", os.O_RDWR); err == nil {
+			if p.fPolarity, err = fileIOOpen(base+"polarity", os.O_RDWR); err == nil {
+				return nil
+			}
+			p.fDuty.Close()
+			p.fDuty = nil
+		}
+		p.fPeriod.Close()
+		p.fPeriod = nil
+	}
+	p.fEnable.Close()
+	p.fEnable = nil
+	return p.wrap(err)
+}
+
+// readInt reads a number from one of the channel files.
+//
+// lock must be held.
+func (p *PWM) readInt(f fileIO) (int64, error) {
+	n, err := seekRead(f, p.buf[:])
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(p.buf[:n])), 10, 64)
+}
+
+// writeInt writes a number to one of the channel files.
+//
+// lock must be held.
+func (p *PWM) writeInt(f fileIO, v int64) error {
+	return seekWrite(f, strconv.AppendInt(p.buf[:0], v, 10))
+}
+
+func (p *PWM) wrap(err error) error {
+	return fmt.Errorf("sysfs-pwm (%s): %v", p, err)
+}
+
+// driverPWM implements periph.Driver.
+type driverPWM struct {
+}
+
+func (d *driverPWM) String() string {
+	return "sysfs-pwm"
+}
+
+func (d *driverPWM) Prerequisites() []string {
+	return nil
+}
+
+// Init initializes PWM sysfs handling code.
+//
+// Uses pwm sysfs as described at
+// https://www.kernel.org/doc/Documentation/pwm.txt
+func (d *driverPWM) Init() (bool, error) {
+	items, err := filepath.Glob(pwmRoot + "pwmchip*")
+	if err != nil {
+		return true, err
+	}
+	if len(items) == 0 {
+		return false, errors.New("no PWM chip found")
+	}
+	// This make the channels in deterministic order.
+	chips := make([]int, 0, len(items))
+	for _, item := range items {
+		n, err := strconv.Atoi(filepath.Base(item)[len("pwmchip"):])
+		if err != nil {
+			continue
+		}
+		chips = append(chips, n)
+	}
+	sort.Ints(chips)
+	var pwms []*PWM
+	for _, chip := range chips {
+		root := fmt.Sprintf("%spwmchip%d/", pwmRoot, chip)
+		n, err := readInt(root + "npwm")
+		if err != nil {
+			return true, fmt.Errorf("sysfs-pwm: %v", err)
+		}
+		for i := 0; i < n; i++ {
+			pwms = append(pwms, &PWM{
+				number:  len(pwms),
+				name:    fmt.Sprintf("PWM%d_%d", chip, i),
+				chip:    chip,
+				channel: i,
+				root:    root,
+			})
+		}
+	}
+	if len(pwms) != 0 {
+		rows := make([][]pin.Pin, 0, len(pwms))
+		for _, p := range pwms {
+			rows = append(rows, []pin.Pin{p})
+		}
+		if err := pinreg.Register("PWM", rows); err != nil {
+			return true, fmt.Errorf("sysfs-pwm: %v", err)
+		}
+	}
+	PWMs = pwms
+	return true, nil
+}
+
+func init() {
+	if isLinux {
+		periph.MustRegister(&driverPWM{})
+	}
+}
+
+var _ conn.Resource = &PWM{}
+var _ gpio.PinPWM = &PWM{}
+var _ pin.Pin = &PWM{}
+var _ fmt.Stringer = &PWM{}
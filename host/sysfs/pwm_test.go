@@ -0,0 +1,266 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/pin/pinreg"
+	"periph.io/x/periph/host/fs"
+)
+
+func TestPWMByName(t *testing.T) {
+	if _, err := PWMByName("FOO"); err == nil {
+		t.Fatal("expected failure")
+	}
+}
+
+func TestPWMDriver(t *testing.T) {
+	root := fakePWMRoot(t)
+	defer resetPWM(root)
+	if ok, err := (&driverPWM{}).Init(); !ok || err != nil {
+		t.Fatal(ok, err)
+	}
+	if len(PWMs) != 3 {
+		t.Fatal(PWMs)
+	}
+	for i, name := range []string{"PWM0_0", "PWM0_1", "PWM2_0"} {
+		p, err := PWMByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != PWMs[i] || p.Number() != i || p.Name() != name {
+			t.Fatal(p)
+		}
+	}
+	if p := PWMs[2]; p.Chip() != 2 || p.Channel() != 0 || p.String() != "PWM2_0(2)" {
+		t.Fatal(p)
+	}
+	if hdr := pinreg.All()["PWM"]; len(hdr) != 3 || hdr[1][0] != PWMs[1] {
+		t.Fatal(hdr)
+	}
+	if !pinreg.IsConnected(PWMs[2]) {
+		t.Fatal("PWM2_0 should be on the PWM header")
+	}
+
+	pwmRoot = filepath.Join(root, "none") + "/"
+	if ok, err := (&driverPWM{}).Init(); ok || err == nil {
+		t.Fatal("expected no PWM chip")
+	}
+}
+
+func TestPWM(t *testing.T) {
+	root := fakePWMRoot(t)
+	defer resetPWM(root)
+	if _, err := (&driverPWM{}).Init(); err != nil {
+		t.Fatal(err)
+	}
+	p := PWMs[1]
+	// The channel is exported on first use.
+	if s := p.Function(); s != "PWM/Off" {
+		t.Fatal(s)
+	}
+	if s := readFile(t, root, "pwmchip0/export"); s != "1" {
+		t.Fatal(s)
+	}
+	if err := p.PWM(gpio.DutyHalf, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if s := readFile(t, root, "pwmchip0/pwm1/period"); s != "1000000" {
+		t.Fatal(s)
+	}
+	if s := readFile(t, root, "pwmchip0/pwm1/duty_cycle"); s != "499992" {
+		t.Fatal(s)
+	}
+	if s := p.Function(); s != "PWM/On" {
+		t.Fatal(s)
+	}
+	if d, period := p.Duty(); d != gpio.DutyHalf || period != time.Millisecond {
+		t.Fatal(d, period)
+	}
+	// 0 keeps the current period.
+	if err := p.PWM(gpio.DutyMax, 0); err != nil {
+		t.Fatal(err)
+	}
+	if d, period := p.Duty(); d != gpio.DutyMax || period != time.Millisecond {
+		t.Fatal(d, period)
+	}
+	if err := p.PWM(gpio.DutyMax+1, 0); err == nil {
+		t.Fatal("invalid duty")
+	}
+	if err := p.PWM(0, -1); err == nil {
+		t.Fatal("invalid period")
+	}
+
+	if p.Inverted() {
+		t.Fatal("unexpected polarity")
+	}
+	if err := p.SetInverted(true); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Inverted() {
+		t.Fatal("expected inversed polarity")
+	}
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if s := readFile(t, root, "pwmchip0/pwm1/enable"); s != "0" {
+		t.Fatal(s)
+	}
+}
+
+func TestPWM_defaultPeriod(t *testing.T) {
+	root := fakePWMRoot(t)
+	defer resetPWM(root)
+	if _, err := (&driverPWM{}).Init(); err != nil {
+		t.Fatal(err)
+	}
+	p := PWMs[0]
+	if d, period := p.Duty(); d != 0 || period != 0 {
+		t.Fatal(d, period)
+	}
+	if err := p.PWM(gpio.DutyMax/4, 0); err != nil {
+		t.Fatal(err)
+	}
+	if s := readFile(t, root, "pwmchip0/pwm0/period"); s != "1000000" {
+		t.Fatal(s)
+	}
+	// Shrinking the period writes the duty cycle first.
+	if err := p.PWM(gpio.DutyMax/4, time.Microsecond); err != nil {
+		t.Fatal(err)
+	}
+	if s := readFile(t, root, "pwmchip0/pwm0/duty_cycle"); s != "249" {
+		t.Fatal(s)
+	}
+}
+
+func TestPWM_fail(t *testing.T) {
+	defer reset()
+	p := PWM{name: "PWM0_0", root: "/tmp/pwm/priv/"}
+	if s := p.Function(); s != "ERR" {
+		t.Fatal(s)
+	}
+	if err := p.PWM(gpio.DutyHalf, 0); err == nil {
+		t.Fatal("expected failure")
+	}
+	if err := p.Enable(true); err == nil {
+		t.Fatal("expected failure")
+	}
+	if err := p.SetInverted(true); err == nil {
+		t.Fatal("expected failure")
+	}
+	if p.Inverted() {
+		t.Fatal("expected failure")
+	}
+	if d, period := p.Duty(); d != 0 || period != 0 {
+		t.Fatal(d, period)
+	}
+}
+
+//
+
+// fakePWMRoot creates a sysfs tree with two chips and uses it as pwmRoot.
+//
+// Writing to export creates the channel directory, like the kernel does.
+func fakePWMRoot(t *testing.T) string {
+	root, err := ioutil.TempDir("", "periph_pwm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		"pwmchip0/npwm":   "2\n",
+		"pwmchip0/export": "",
+		"pwmchip2/npwm":   "1\n",
+		"pwmchip2/export": "",
+	} {
+		writeFile(t, root, path, content)
+	}
+	pwmRoot = root + "/"
+	fileIOOpen = func(path string, flag int) (fileIO, error) {
+		f, err := os.OpenFile(path, flag, 0600)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Base(path) == "export" {
+			return &fakeExport{fakeSysfsFile{&fs.File{File: f}}, t}, nil
+		}
+		return &fakeSysfsFile{&fs.File{File: f}}, nil
+	}
+	return root
+}
+
+func resetPWM(root string) {
+	reset()
+	if PWMs != nil {
+		pinreg.Unregister("PWM")
+	}
+	PWMs = nil
+	pwmRoot = "/sys/class/pwm/"
+	os.RemoveAll(root)
+}
+
+func writeFile(t *testing.T, root, path, content string) {
+	p := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, root, path string) string {
+	b, err := ioutil.ReadFile(filepath.Join(root, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// fakeSysfsFile replaces the content of the file on each write, like a sysfs
+// attribute.
+type fakeSysfsFile struct {
+	*fs.File
+}
+
+func (f *fakeSysfsFile) Write(b []byte) (int, error) {
+	if err := f.Truncate(0); err != nil {
+		return 0, err
+	}
+	return f.WriteAt(b, 0)
+}
+
+// fakeExport creates the files of the channel written to it.
+type fakeExport struct {
+	fakeSysfsFile
+	t *testing.T
+}
+
+func (f *fakeExport) Write(b []byte) (int, error) {
+	n, err := f.fakeSysfsFile.Write(b)
+	if err != nil {
+		return n, err
+	}
+	if _, err := strconv.Atoi(string(b)); err != nil {
+		f.t.Fatal(err)
+	}
+	dir := filepath.Join(filepath.Dir(f.Name()), "pwm"+string(b))
+	for name, content := range map[string]string{
+		"enable":     "0\n",
+		"period":     "0\n",
+		"duty_cycle": "0\n",
+		"polarity":   "normal\n",
+	} {
+		writeFile(f.t, dir, name, content)
+	}
+	return n, nil
+}
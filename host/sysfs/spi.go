@@ -5,6 +5,7 @@
 package sysfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -59,6 +60,8 @@ type SPI struct {
 	mosi        gpio.PinOut
 	miso        gpio.PinIn
 	cs          gpio.PinOut
+	csClaim     gpio.PinIO // chip select pin acquired in gpioreg, if any
+	closed      bool
 }
 
 func newSPI(busNumber, chipSelect int) (*SPI, error) {
@@ -73,14 +76,27 @@ func newSPI(busNumber, chipSelect int) (*SPI, error) {
 	if err != nil {
 		return nil, fmt.Errorf("sysfs-spi: %v", err)
 	}
-	return &SPI{f: f, busNumber: busNumber, chipSelect: chipSelect}, nil
+	s := &SPI{f: f, busNumber: busNumber, chipSelect: chipSelect}
+	if err := s.acquireCS(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
-// Close closes the handle to the SPI driver. It is not a requirement to close
-// before process termination.
+// Close closes the handle to the SPI driver. The chip select pin is released
+// once all the handles to the port are closed.
+//
+// It is not a requirement to close before process termination. Calling Close
+// more than once is a no-op.
 func (s *SPI) Close() error {
 	s.Lock()
 	defer s.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.releaseCS()
 	if err := s.f.Close(); err != nil {
 		return fmt.Errorf("sysfs-spi: %v", err)
 	}
@@ -140,7 +156,7 @@ func (s *SPI) Connect(maxHz int64, mode spi.Mode, bits int) (spi.Conn, error) {
 	// Only the first 8 bits are used. This only works because the system is
 	// running in little endian.
 	if err := s.setFlag(spiIOCMode, uint64(m)); err != nil {
-		return nil, fmt.Errorf("sysfs-spi: setting mode %v failed: %v", mode, err)
+		return nil, wrapErr(fmt.Sprintf("sysfs-spi: setting mode %v failed", mode), err)
 	}
 	return &spiConn{s}, nil
 }
@@ -187,7 +203,51 @@ func (s *SPI) CS() gpio.PinOut {
 
 // Private details.
 
-func (s *SPI) txInternal(w, r []byte) (int, error) {
+// acquireCS marks the chip select pin as used in gpioreg while the port is
+// open. The kernel drives it, so it cannot be used as a GPIO in the
+// meantime. The other pins may be shared with the other devices on the bus.
+//
+// The claim is shared by all the handles to the same port, so a port can
+// still be opened more than once.
+func (s *SPI) acquireCS() error {
+	cs := gpioreg.ByName(fmt.Sprintf("SPI%d_CS%d", s.busNumber, s.chipSelect))
+	if cs == nil {
+		return nil
+	}
+	spiCSMu.Lock()
+	defer spiCSMu.Unlock()
+	name := s.String()
+	if spiCSOpen[name] == 0 {
+		if err := gpioreg.Acquire(name, cs); err != nil {
+			return fmt.Errorf("sysfs-spi: %v", err)
+		}
+	}
+	spiCSOpen[name]++
+	s.csClaim = cs
+	return nil
+}
+
+// releaseCS releases the chip select pin when the last handle to the port is
+// closed, if the port still owns it.
+//
+// lock must be held.
+func (s *SPI) releaseCS() {
+	if s.csClaim == nil {
+		return
+	}
+	spiCSMu.Lock()
+	defer spiCSMu.Unlock()
+	name := s.String()
+	if spiCSOpen[name]--; spiCSOpen[name] <= 0 {
+		delete(spiCSOpen, name)
+		if gpioreg.Owner(s.csClaim) == name {
+			gpioreg.Release(s.csClaim)
+		}
+	}
+	s.csClaim = nil
+}
+
+func (s *SPI) txInternal(ctx context.Context, w, r []byte) (int, error) {
 	l := len(w)
 	if l == 0 {
 		l = len(r)
@@ -201,6 +261,9 @@ func (s *SPI) txInternal(w, r []byte) (int, error) {
 	if !s.initialized {
 		return 0, errors.New("sysfs-spi: Connect wasn't called")
 	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
 	if len(w) != 0 && len(r) != 0 && s.halfDuplex {
 		return 0, errors.New("sysfs-spi: can only specify one of w or r when in half duplex")
 	}
@@ -220,12 +283,12 @@ func (s *SPI) txInternal(w, r []byte) (int, error) {
 		m.length = uint32(l)
 	}
 	if err := s.f.Ioctl(spiIOCTx(1), uintptr(unsafe.Pointer(&m))); err != nil {
-		return 0, fmt.Errorf("sysfs-spi: I/O failed: %v", err)
+		return 0, wrapErr("sysfs-spi: I/O failed", err)
 	}
 	return l, nil
 }
 
-func (s *SPI) txPackets(p []spi.Packet) error {
+func (s *SPI) txPackets(ctx context.Context, p []spi.Packet) error {
 	total := 0
 	for i := range p {
 		lW := len(p[i].W)
@@ -253,6 +316,9 @@ func (s *SPI) txPackets(p []spi.Packet) error {
 	if !s.initialized {
 		return errors.New("sysfs-spi: Connect wasn't called")
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	// Convert the packets.
 	speed := s.maxHzPort
 	if s.maxHzDev != 0 && (s.maxHzPort == 0 || s.maxHzDev < s.maxHzPort) {
@@ -282,7 +348,7 @@ func (s *SPI) txPackets(p []spi.Packet) error {
 		}
 	}
 	if err := s.f.Ioctl(spiIOCTx(len(m)), uintptr(unsafe.Pointer(&m[0]))); err != nil {
-		return fmt.Errorf("sysfs-spi: TxPackets(%d) packets failed: %v", len(m), err)
+		return wrapErr(fmt.Sprintf("sysfs-spi: TxPackets(%d) packets failed", len(m)), err)
 	}
 	return nil
 }
@@ -353,7 +419,7 @@ func (s *spiConn) Read(b []byte) (int, error) {
 	if len(b) == 0 {
 		return 0, errors.New("sysfs-spi: Read() with empty buffer")
 	}
-	return s.s.txInternal(nil, b)
+	return s.s.txInternal(context.Background(), nil, b)
 }
 
 // Write implements io.Writer.
@@ -361,7 +427,7 @@ func (s *spiConn) Write(b []byte) (int, error) {
 	if len(b) == 0 {
 		return 0, errors.New("sysfs-spi: Write() with empty buffer")
 	}
-	return s.s.txInternal(b, nil)
+	return s.s.txInternal(context.Background(), b, nil)
 }
 
 // Tx sends and receives data simultaneously.
@@ -372,6 +438,16 @@ func (s *spiConn) Write(b []byte) (int, error) {
 // 4096 bytes. See the platform documentation to learn how to increase the
 // limit.
 func (s *spiConn) Tx(w, r []byte) error {
+	return s.TxContext(context.Background(), w, r)
+}
+
+// TxContext implements spi.TxContexter.
+//
+// ctx is only checked before the transaction is handed to the kernel,
+// including after waiting for a concurrent transaction to complete. The
+// kernel call itself cannot be interrupted; its result is returned even if
+// ctx is done in the meantime.
+func (s *spiConn) TxContext(ctx context.Context, w, r []byte) error {
 	if len(w) == 0 {
 		if len(r) == 0 {
 			return errors.New("sysfs-spi: Tx with empty buffers")
@@ -381,7 +457,10 @@ func (s *spiConn) Tx(w, r []byte) error {
 			return errors.New("sysfs-spi: Tx with zero or non-equal length w&r slices")
 		}
 	}
-	_, err := s.s.txInternal(w, r)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := s.s.txInternal(ctx, w, r)
 	return err
 }
 
@@ -391,7 +470,18 @@ func (s *spiConn) Tx(w, r []byte) error {
 // 4096 bytes. See the platform documentation to learn how to increase the
 // limit.
 func (s *spiConn) TxPackets(p []spi.Packet) error {
-	return s.s.txPackets(p)
+	return s.s.txPackets(context.Background(), p)
+}
+
+// TxPacketsContext implements spi.TxContexter.
+//
+// Like TxContext(), ctx is only checked before the packets are handed to the
+// kernel.
+func (s *spiConn) TxPacketsContext(ctx context.Context, p []spi.Packet) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.s.txPackets(ctx, p)
 }
 
 func (s *spiConn) Duplex() conn.Duplex {
@@ -489,6 +579,13 @@ type spiIOCTransfer struct {
 // spiBufSize is the maximum number of bytes allowed per I/O on the SPI port.
 var spiBufSize = 0
 
+var (
+	spiCSMu sync.Mutex
+	// spiCSOpen is the number of open handles per port that share the claim on
+	// the chip select pin.
+	spiCSOpen = map[string]int{}
+)
+
 //
 
 // driverSPI implements periph.Driver.
@@ -575,6 +672,7 @@ var _ conn.Limits = &spiConn{}
 var _ io.Reader = &spiConn{}
 var _ io.Writer = &spiConn{}
 var _ spi.Conn = &spiConn{}
+var _ spi.TxContexter = &spiConn{}
 var _ spi.Pins = &SPI{}
 var _ spi.Pins = &spiConn{}
 var _ fmt.Stringer = &SPI{}
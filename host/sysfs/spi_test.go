@@ -5,12 +5,16 @@
 package sysfs
 
 import (
+	"context"
 	"io"
 	"log"
+	"syscall"
 	"testing"
 
 	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpiotest"
 	"periph.io/x/periph/conn/spi"
 )
 
@@ -42,6 +46,65 @@ func TestNewSPI(t *testing.T) {
 	}
 }
 
+func TestSPI_CS(t *testing.T) {
+	defer reset()
+	ioctlOpen = func(path string, flag int) (ioctlCloser, error) {
+		return &ioctlClose{}, nil
+	}
+	cs := &gpiotest.Pin{N: "GPIO1024", Num: 1024}
+	if err := gpioreg.Register(cs, false); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Unregister("GPIO1024")
+	if err := gpioreg.RegisterAlias("SPI24_CS0", "GPIO1024"); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Unregister("SPI24_CS0")
+
+	// The port can be opened twice; the claim is kept until both are closed.
+	a, err := newSPI(24, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newSPI(24, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := gpioreg.Owner(cs); o != "SPI24.0" {
+		t.Fatal(o)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if o := gpioreg.Owner(cs); o != "SPI24.0" {
+		t.Fatal(o)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if o := gpioreg.Owner(cs); o != "" {
+		t.Fatal(o)
+	}
+
+	// A claim taken by someone else after Close is kept.
+	if err := gpioreg.Acquire("other", cs); err != nil {
+		t.Fatal(err)
+	}
+	defer gpioreg.Release(cs)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if o := gpioreg.Owner(cs); o != "other" {
+		t.Fatal(o)
+	}
+	if _, err := newSPI(24, 0); err == nil {
+		t.Fatal("chip select is used")
+	}
+}
+
 func TestSPI_IO(t *testing.T) {
 	port := SPI{f: &ioctlClose{}, busNumber: 24}
 	c, err := port.Connect(1, spi.Mode3, 8)
@@ -109,14 +172,40 @@ func TestSPI_IO(t *testing.T) {
 
 func TestSPI_IO_not_initialized(t *testing.T) {
 	port := SPI{f: &ioctlClose{}, busNumber: 24}
-	if _, err := port.txInternal([]byte{0}, []byte{0}); err == nil {
+	if _, err := port.txInternal(context.Background(), []byte{0}, []byte{0}); err == nil {
 		t.Fatal("not initialized")
 	}
-	if port.txPackets([]spi.Packet{{W: []byte{0}}}) == nil {
+	if port.txPackets(context.Background(), []spi.Packet{{W: []byte{0}}}) == nil {
 		t.Fatal("not initialized")
 	}
 }
 
+func TestSPI_IO_canceled(t *testing.T) {
+	port := SPI{f: &ioctlClose{}, busNumber: 24}
+	c, err := port.Connect(0, spi.Mode3, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := spi.TxContext(ctx, c, []byte{0}, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := spi.TxPacketsContext(ctx, c, []spi.Packet{{W: []byte{0}}}); err != context.Canceled {
+		t.Fatal(err)
+	}
+}
+
+func TestSPI_errors(t *testing.T) {
+	if !isLinux {
+		t.Skip("errno mapping is only done on linux")
+	}
+	port := SPI{f: &ioctlRecord{err: syscall.ETIMEDOUT}, busNumber: 24}
+	if _, err := port.Connect(1, spi.Mode3, 8); !conn.IsKind(err, conn.ErrTimeout) {
+		t.Fatal(err)
+	}
+}
+
 func TestSPI_pins(t *testing.T) {
 	port := SPI{f: &ioctlClose{}, busNumber: 24}
 	if p := port.CLK(); p != gpio.INVALID {
@@ -5,11 +5,28 @@
 package sysfs
 
 import (
+	"fmt"
 	"io"
 
+	"periph.io/x/periph/conn"
 	"periph.io/x/periph/host/fs"
 )
 
+// wrapErr prefixes err with msg. If err is a known kind of failure, it is
+// returned as a *conn.Error.
+func wrapErr(msg string, err error) error {
+	return wrapKind(msg, errKind(err), err)
+}
+
+// wrapKind prefixes err with msg. If kind is not nil, it is returned as a
+// *conn.Error.
+func wrapKind(msg string, kind, err error) error {
+	if kind != nil {
+		return &conn.Error{Kind: kind, Msg: msg, Err: err}
+	}
+	return fmt.Errorf("%s: %v", msg, err)
+}
+
 var ioctlOpen = ioctlOpenDefault
 
 func ioctlOpenDefault(path string, flag int) (ioctlCloser, error) {
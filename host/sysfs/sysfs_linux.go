@@ -7,6 +7,11 @@ package sysfs
 import (
 	"os"
 	"syscall"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/host/fs"
 )
 
 const isLinux = true
@@ -15,3 +20,122 @@ func isErrBusy(err error) bool {
 	e, ok := err.(*os.PathError)
 	return ok && e.Err == syscall.EBUSY
 }
+
+// errKind returns the kind of failure reported by an ioctl, or nil if it is
+// not recognized.
+//
+// See Documentation/i2c/fault-codes in the kernel source tree.
+func errKind(err error) error {
+	switch err {
+	case syscall.ENXIO, syscall.EREMOTEIO:
+		return conn.ErrNotAcknowledged
+	case syscall.ETIMEDOUT:
+		return conn.ErrTimeout
+	case syscall.EAGAIN, syscall.EBUSY:
+		return conn.ErrBusBusy
+	case syscall.EOPNOTSUPP:
+		return conn.ErrUnsupported
+	}
+	return nil
+}
+
+// addrErrKind returns the kind of failure reported by the I2C_SLAVE ioctl,
+// or nil if it is not recognized.
+func addrErrKind(err error) error {
+	if err == syscall.EBUSY {
+		// A kernel driver is bound to the address; retrying won't help.
+		return conn.ErrInUse
+	}
+	return errKind(err)
+}
+
+// monotonicNow returns the current time of CLOCK_MONOTONIC, the clock used to
+// timestamp the GPIO line events.
+func monotonicNow() time.Duration {
+	var ts syscall.Timespec
+	// CLOCK_MONOTONIC is 1.
+	syscall.Syscall(syscall.SYS_CLOCK_GETTIME, 1, uintptr(unsafe.Pointer(&ts)), 0)
+	return time.Duration(ts.Nano())
+}
+
+// newLineDev returns the line request file descriptor fd returned by the GPIO
+// character device.
+func newLineDev(fd int32) (lineFile, error) {
+	// Make it non-blocking so Read() returns immediately when no event is
+	// queued; Wait() is used to wait for one.
+	if err := syscall.SetNonblock(int(fd), true); err != nil {
+		syscall.Close(int(fd))
+		return nil, err
+	}
+	l := &lineDev{fd: uintptr(fd)}
+	if err := l.event.MakeReadEvent(l.fd); err != nil {
+		syscall.Close(int(fd))
+		return nil, err
+	}
+	return l, nil
+}
+
+// lineDev implements lineFile with the ioctls of a GPIO line request.
+type lineDev struct {
+	fd    uintptr
+	event fs.Event
+}
+
+func (l *lineDev) Close() error {
+	err := l.event.Close()
+	if err2 := syscall.Close(int(l.fd)); err2 != nil {
+		return err2
+	}
+	return err
+}
+
+func (l *lineDev) Read(b []byte) (int, error) {
+	n, err := syscall.Read(int(l.fd), b)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (l *lineDev) Wait(timeout time.Duration) bool {
+	ms := -1
+	if timeout >= 0 {
+		// Round up so a short timeout doesn't become a poll.
+		ms = int((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+	start := time.Now()
+	for {
+		nr, err := l.event.Wait(ms)
+		if nr == 1 {
+			return true
+		}
+		if err != syscall.EINTR {
+			return false
+		}
+		// A signal occurred.
+		if timeout >= 0 {
+			if ms = int((timeout - time.Since(start)) / time.Millisecond); ms <= 0 {
+				return false
+			}
+		}
+	}
+}
+
+func (l *lineDev) SetConfig(c *lineConfig) error {
+	return l.ioctl(ioctlLineSetConfig, uintptr(unsafe.Pointer(c)))
+}
+
+func (l *lineDev) GetValues(v *lineValues) error {
+	return l.ioctl(ioctlLineGetValues, uintptr(unsafe.Pointer(v)))
+}
+
+func (l *lineDev) SetValues(v *lineValues) error {
+	return l.ioctl(ioctlLineSetValues, uintptr(unsafe.Pointer(v)))
+}
+
+func (l *lineDev) ioctl(op uint, data uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, l.fd, uintptr(op), data); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -6,9 +6,34 @@
 
 package sysfs
 
+import (
+	"errors"
+	"time"
+)
+
 const isLinux = false
 
 func isErrBusy(err error) bool {
 	// This function is not used on non-linux.
 	return false
 }
+
+func errKind(err error) error {
+	// This function is not used on non-linux.
+	return nil
+}
+
+func addrErrKind(err error) error {
+	// This function is not used on non-linux.
+	return nil
+}
+
+func monotonicNow() time.Duration {
+	// This function is not used on non-linux.
+	return 0
+}
+
+func newLineDev(fd int32) (lineFile, error) {
+	// This function is not used on non-linux.
+	return nil, errors.New("sysfs-gpiochip: not supported on this platform")
+}
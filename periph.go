@@ -79,6 +79,18 @@ type Driver interface {
 	Init() (bool, error)
 }
 
+// DriverAfter is an optional interface a Driver can implement to be loaded
+// after other drivers.
+//
+// Unlike Prerequisites(), the driver is still loaded when the drivers listed
+// are not registered, skipped or failed to load. This permits a driver to
+// act as a fallback of another one.
+type DriverAfter interface {
+	// After returns a list of drivers that must be attempted first before
+	// attempting to load this driver.
+	After() []string
+}
+
 // DriverFailure is a driver that wasn't loaded, either because it was skipped
 // or because it failed to load.
 type DriverFailure struct {
@@ -225,6 +237,13 @@ func explodeStages(drvs []Driver) ([][]Driver, error) {
 			// driver. As an example, allwinner->R8, allwinner->A64, etc.
 			dependencies[name][depName] = struct{}{}
 		}
+		if a, ok := d.(DriverAfter); ok {
+			for _, depName := range a.After() {
+				if _, ok := byName[depName]; ok {
+					dependencies[name][depName] = struct{}{}
+				}
+			}
+		}
 	}
 
 	var stages [][]Driver
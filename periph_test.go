@@ -163,6 +163,32 @@ func TestDependencySkipped(t *testing.T) {
 	}
 }
 
+func TestDependencyAfter(t *testing.T) {
+	defer reset()
+	registerDrivers([]Driver{
+		&driverAfter{
+			driver: driver{
+				name: "Fallback",
+				ok:   true,
+			},
+			after: []string{"Native", "Missing"},
+		},
+		&driver{
+			name: "Native",
+			ok:   false,
+			err:  errors.New("skipped"),
+		},
+	})
+	stages, err := explodeStages(allDrivers)
+	if len(stages) != 2 || len(stages[0]) != 1 || stages[0][0].String() != "Native" || err != nil {
+		t.Fatal(stages, err)
+	}
+	state, err := Init()
+	if err != nil || len(state.Skipped) != 1 || len(state.Loaded) != 1 || state.Loaded[0].String() != "Fallback" {
+		t.Fatal(state, err)
+	}
+}
+
 func TestRegisterLate(t *testing.T) {
 	defer reset()
 	if _, err := Init(); err != nil {
@@ -381,3 +407,12 @@ func (d *driver) Prerequisites() []string {
 func (d *driver) Init() (bool, error) {
 	return d.ok, d.err
 }
+
+type driverAfter struct {
+	driver
+	after []string
+}
+
+func (d *driverAfter) After() []string {
+	return d.after
+}